@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"medical_app/config"
+	"medical_app/db"
+	"os"
+)
+
+// runCheckConfig loads the configuration, shows it with secrets redacted, connects to the
+// database and reports the schema version. Invalid settings and an unreachable database
+// end the command with an error, as they would stop the server.
+func runCheckConfig(args []string) error {
+	if err := newFlagSet("check-config").Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Configuration: ok")
+	fmt.Println()
+	if err := cfg.Dump(os.Stdout); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	db, err := database.Open(cfg.DatabaseURL, cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Database: connected (%s)\n", db.Dialector.Name())
+	if cfg.DatabaseReplicaURL != "" {
+		replica, err := database.Open(cfg.DatabaseReplicaURL, cfg)
+		if err != nil {
+			return fmt.Errorf("read replica: %w", err)
+		}
+		fmt.Printf("Read replica: connected (%s)\n", replica.Dialector.Name())
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema: version %d of %d\n", version, migrator.Latest())
+	return migrator.CheckSchema()
+}
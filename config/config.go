@@ -3,43 +3,73 @@ package config
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-// Config holds the application configuration
+// Config holds the application configuration. Each setting has a key in the config file,
+// an environment variable and a default, given by the tags of its field; secret settings
+// can also be read from a file (see Load).
 type Config struct {
-	DatabaseURL string
-	Port        string
-	JWTSecret   string
-}
+	// HTTP server
+	Port                  string        `key:"server.port" env:"PORT" default:"8080"`
+	ServerReadTimeout     time.Duration `key:"server.read_timeout" env:"SERVER_READ_TIMEOUT" default:"15s"`         // for reading a whole request
+	ServerWriteTimeout    time.Duration `key:"server.write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"30s"`       // for writing a response, from the end of the request
+	ServerIdleTimeout     time.Duration `key:"server.idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"2m"`          // keep-alive connections are closed after this long
+	ServerShutdownTimeout time.Duration `key:"server.shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"15s"` // requests in progress may finish within this on shutdown
+	CORSAllowedOrigins    []string      `key:"server.cors_allowed_origins" env:"CORS_ALLOWED_ORIGINS" default:"*"`  // origins of browser clients, or * for any
+	LogLevel              string        `key:"log.level" env:"LOG_LEVEL" default:"info"`                            // debug, info, warn or error
 
-// LoadConfig loads configuration from environment variables or .env file
-func LoadConfig() *Config {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("No .env file found, relying on environment variables.")
-	}
+	// Database connections
+	DatabaseURL        string        `key:"database.url" env:"DATABASE_URL" secret:"password"`
+	DatabaseReplicaURL string        `key:"database.replica_url" env:"DATABASE_REPLICA_URL" secret:"password"`    // optional read replica for patient and audit log listings and searches
+	DBMaxOpenConns     int           `key:"database.max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25"`         // connections open at most, in use or idle
+	DBMaxIdleConns     int           `key:"database.max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"5"`          // idle connections kept for reuse
+	DBConnMaxLifetime  time.Duration `key:"database.conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"30m"`  // connections are replaced after this long
+	DBConnMaxIdleTime  time.Duration `key:"database.conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" default:"5m"` // idle connections are closed after this long
+	DBConnectTimeout   time.Duration `key:"database.connect_timeout" env:"DB_CONNECT_TIMEOUT" default:"5s"`       // how long one connection attempt at startup may take
+	DBConnectAttempts  int           `key:"database.connect_attempts" env:"DB_CONNECT_ATTEMPTS" default:"5"`      // connection attempts at startup before giving up
+	DBConnectBackoff   time.Duration `key:"database.connect_backoff" env:"DB_CONNECT_BACKOFF" default:"1s"`       // delay after the first failed attempt, doubled after each further one
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL environment variable not set")
-	}
+	// Tokens
+	JWTSecret       string        `key:"auth.jwt_secret" env:"JWT_SECRET" secret:"true"`
+	AccessTokenTTL  time.Duration `key:"auth.access_token_ttl" env:"ACCESS_TOKEN_TTL" default:"15m"`
+	RefreshTokenTTL time.Duration `key:"auth.refresh_token_ttl" env:"REFRESH_TOKEN_TTL" default:"168h"`
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080" 
-	}
+	// Login brute-force protection
+	LoginMaxFailures     int           `key:"auth.login_max_failures" env:"LOGIN_MAX_FAILURES" default:"5"`           // failures per username before it is locked
+	LoginMaxIPFailures   int           `key:"auth.login_max_ip_failures" env:"LOGIN_MAX_IP_FAILURES" default:"50"`    // failures per client IP before it is locked
+	LoginLockoutDuration time.Duration `key:"auth.login_lockout_duration" env:"LOGIN_LOCKOUT_DURATION" default:"15m"` // how long a lockout lasts and how long failures are remembered
+	LoginBackoffBase     time.Duration `key:"auth.login_backoff_base" env:"LOGIN_BACKOFF_BASE" default:"1s"`          // delay after the first failures, doubled on each further failure
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable not set")
-	}
+	// Password policy
+	PasswordMinLength    int  `key:"password.min_length" env:"PASSWORD_MIN_LENGTH" default:"12"`         // minimum number of characters
+	PasswordMinClasses   int  `key:"password.min_classes" env:"PASSWORD_MIN_CLASSES" default:"3"`        // minimum character classes (lower, upper, digit, symbol)
+	PasswordRejectCommon bool `key:"password.reject_common" env:"PASSWORD_REJECT_COMMON" default:"true"` // reject passwords on the bundled common password list
+	PasswordHistory      int  `key:"password.history" env:"PASSWORD_HISTORY" default:"5"`                // how many previous passwords cannot be reused
 
-	return &Config{
-		DatabaseURL: dbURL,
-		Port:        port,
-		JWTSecret:   jwtSecret,
-	}
+	// Deleted patients are purged for good once they have been deleted this long
+	PatientRetention time.Duration `key:"patients.retention" env:"PATIENT_RETENTION" default:"2160h"`
+
+	// Initial admin account, created by the server when no user can manage users
+	AdminUsername string `key:"admin.username" env:"ADMIN_USERNAME" default:"admin"`
+	AdminPassword string `key:"admin.password" env:"ADMIN_PASSWORD" secret:"true"` // generated and logged once when empty
+
+	// Feature flags
+	FeatureBootstrapAdmin bool `key:"features.bootstrap_admin" env:"FEATURE_BOOTSTRAP_ADMIN" default:"true"` // create the initial admin account
+	FeaturePatientPurge   bool `key:"features.patient_purge" env:"FEATURE_PATIENT_PURGE" default:"true"`     // purge patients after the retention period
+
+	// sources records where each setting was taken from, by key
+	sources map[string]string
 }
 
+// LoadConfig loads the configuration from the file named by CONFIG_FILE (if set), then
+// from environment variables or .env file, which take precedence
+func LoadConfig() (*Config, error) {
+	err := godotenv.Load()
+	if err != nil {
+		log.Println("No .env file found, relying on environment variables.")
+	}
+	return Load(os.Getenv("CONFIG_FILE"))
+}
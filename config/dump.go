@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// redacted replaces a secret in the settings shown by Settings
+const redacted = "REDACTED"
+
+// passwordParam matches a password in a key=value connection string or a URL query
+var passwordParam = regexp.MustCompile(`(?i)(password=)('[^']*'|[^&\s]+)`)
+
+// Setting is a configuration value as shown for diagnostics
+type Setting struct {
+	Key    string
+	Env    string
+	Value  string // secrets are redacted; empty when not set
+	Source string // "default", "file <path>" or "env <variable>"
+}
+
+// Settings lists every setting with its value and where it came from. Secrets are
+// redacted, so the list can be logged or shared.
+func (c *Config) Settings() []Setting {
+	var list []Setting
+	for _, s := range c.settings() {
+		value := ""
+		switch v := s.field.Interface().(type) {
+		case []string:
+			value = strings.Join(v, ",")
+		default:
+			value = fmt.Sprint(v)
+		}
+		switch {
+		case value == "":
+		case s.Secret == "password":
+			value = redactPassword(value)
+		case s.Secret != "":
+			value = redacted
+		}
+
+		source := c.sources[s.Key]
+		if source == "" {
+			source = "code" // a Config not made by Load
+		}
+		list = append(list, Setting{Key: s.Key, Env: s.Env, Value: value, Source: source})
+	}
+	return list
+}
+
+// Dump writes the settings as a table
+func (c *Config) Dump(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "SETTING\tVALUE\tSOURCE")
+	for _, s := range c.Settings() {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+	}
+	return table.Flush()
+}
+
+// redactPassword hides the password of a database URL or connection string
+func redactPassword(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			dsn = u.String()
+		}
+	}
+	return passwordParam.ReplaceAllString(dsn, "${1}"+redacted)
+}
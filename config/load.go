@@ -0,0 +1,268 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError lists every problem found while loading the configuration, so they can
+// all be fixed at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// setting is a field of Config, described by its tags
+type setting struct {
+	Key     string // in the config file, e.g. "server.port"
+	Env     string // environment variable
+	Default string
+	Secret  string // "true" to hide the value, "password" to hide the password in a URL
+	field   reflect.Value
+}
+
+// settings returns the settings of c, in the order of the fields
+func (c *Config) settings() []setting {
+	value := reflect.ValueOf(c).Elem()
+	var settings []setting
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if key := field.Tag.Get("key"); key != "" {
+			settings = append(settings, setting{
+				Key:     key,
+				Env:     field.Tag.Get("env"),
+				Default: field.Tag.Get("default"),
+				Secret:  field.Tag.Get("secret"),
+				field:   value.Field(i),
+			})
+		}
+	}
+	return settings
+}
+
+// label names a setting in messages by both its key and its environment variable
+func (s setting) label() string {
+	return fmt.Sprintf("%s (%s)", s.Key, s.Env)
+}
+
+// Load reads the configuration. Each setting starts at its default, is overridden by the
+// config file (YAML or TOML, by extension; none when file is empty), and then by its
+// environment variable. A secret setting can be read from a file instead, named by
+// <ENV>_FILE or by <key>_file in the config file, e.g. JWT_SECRET_FILE=/run/secrets/jwt.
+//
+// Every problem, from unknown keys in the file to invalid values, is reported together
+// in a *ValidationError.
+func Load(file string) (*Config, error) {
+	c := &Config{sources: map[string]string{}}
+	settings := c.settings()
+	for _, s := range settings {
+		if err := s.set(s.Default); err != nil {
+			panic(fmt.Sprintf("config: default of %s: %v", s.Key, err))
+		}
+		c.sources[s.Key] = "default"
+	}
+
+	var problems []string
+	if file != "" {
+		values, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, c.applyFile(file, values, settings)...)
+	}
+	problems = append(problems, c.applyEnv(settings)...)
+	if err := c.Validate(); err != nil {
+		problems = append(problems, err.(*ValidationError).Problems...)
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return c, nil
+}
+
+// readFile reads a config file into a flat map from dotted keys to values
+func readFile(file string) (map[string]any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	tree := map[string]any{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unknown format, use .yaml, .yml or .toml", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", file, err)
+	}
+
+	values := map[string]any{}
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]any, values map[string]any) {
+	for name, value := range tree {
+		if sub, ok := value.(map[string]any); ok {
+			flatten(prefix+name+".", sub, values)
+		} else {
+			values[prefix+name] = value
+		}
+	}
+}
+
+// applyFile sets the settings given in the config file and returns the problems with them
+func (c *Config) applyFile(file string, values map[string]any, settings []setting) []string {
+	var problems []string
+	known := map[string]bool{}
+	for _, s := range settings {
+		known[s.Key] = true
+		value, hasValue := values[s.Key]
+		var path any
+		hasPath := false
+		if s.Secret != "" {
+			known[s.Key+"_file"] = true
+			path, hasPath = values[s.Key+"_file"]
+		}
+
+		switch {
+		case !hasValue && !hasPath:
+			continue
+		case hasValue && hasPath:
+			problems = append(problems, fmt.Sprintf("%s: set %s or %s_file in %s, not both", s.label(), s.Key, s.Key, file))
+			continue
+		case hasPath:
+			secret, err := readSecret(fmt.Sprint(path))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", s.label(), err))
+				continue
+			}
+			value = secret
+		}
+
+		var err error
+		if list, ok := value.([]any); ok && s.field.Kind() == reflect.Slice {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = fmt.Sprint(item)
+			}
+			s.field.Set(reflect.ValueOf(items))
+		} else if _, ok := value.([]any); ok {
+			err = fmt.Errorf("must be a single value, not a list")
+		} else {
+			err = s.set(fmt.Sprint(value))
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s in %s: %v", s.Key, file, err))
+			continue
+		}
+		c.sources[s.Key] = "file " + file
+	}
+
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Sprintf("%s in %s: unknown setting", key, file))
+	}
+	return problems
+}
+
+// applyEnv sets the settings given in the environment and returns the problems with them.
+// An empty variable counts as unset.
+func (c *Config) applyEnv(settings []setting) []string {
+	var problems []string
+	for _, s := range settings {
+		value, source := os.Getenv(s.Env), s.Env
+		if s.Secret != "" {
+			if path := os.Getenv(s.Env + "_FILE"); path != "" {
+				if value != "" {
+					problems = append(problems, fmt.Sprintf("%s: set %s or %s_FILE, not both", s.label(), s.Env, s.Env))
+					continue
+				}
+				secret, err := readSecret(path)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %v", s.label(), err))
+					continue
+				}
+				value, source = secret, s.Env+"_FILE"
+			}
+		}
+		if value == "" {
+			continue
+		}
+		if err := s.set(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", source, err))
+			continue
+		}
+		c.sources[s.Key] = "env " + source
+	}
+	return problems
+}
+
+// readSecret reads a secret from a file, such as a Docker or Kubernetes secret, without
+// the line break at its end
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// set parses value into the setting's field. A list is separated by commas.
+func (s setting) set(value string) error {
+	switch s.field.Interface().(type) {
+	case string:
+		s.field.SetString(value)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", value)
+		}
+		s.field.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", value)
+		}
+		s.field.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 15m or 24h, got %q", value)
+		}
+		s.field.SetInt(int64(d))
+	case []string:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", s.field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// LogLevels are the accepted values of LogLevel
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// Validate checks the settings and returns a *ValidationError listing every problem
+func (c *Config) Validate() error {
+	var problems []string
+	settings := map[string]setting{}
+	for _, s := range c.settings() {
+		settings[s.Key] = s
+		switch value := s.field.Interface().(type) {
+		case int:
+			if value <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be a positive integer, got %d", s.label(), value))
+			}
+		case time.Duration:
+			if value <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be a positive duration, got %s", s.label(), value))
+			}
+		}
+	}
+	problem := func(key, format string, args ...any) {
+		problems = append(problems, settings[key].label()+": "+fmt.Sprintf(format, args...))
+	}
+
+	if c.DatabaseURL == "" {
+		problem("database.url", "required")
+	}
+	if c.JWTSecret == "" {
+		problem("auth.jwt_secret", "required")
+	} else if len(c.JWTSecret) < 32 {
+		problem("auth.jwt_secret", "must be at least 32 characters, got %d", len(c.JWTSecret))
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problem("server.port", "must be a port number, got %q", c.Port)
+	}
+	if !slices.Contains(LogLevels, c.LogLevel) {
+		problem("log.level", "must be one of %v, got %q", LogLevels, c.LogLevel)
+	}
+	if len(c.CORSAllowedOrigins) == 0 {
+		problem("server.cors_allowed_origins", "required, use * to allow any origin")
+	}
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			if len(c.CORSAllowedOrigins) > 1 {
+				problem("server.cors_allowed_origins", "* cannot be combined with other origins")
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			problem("server.cors_allowed_origins", "%q is not an origin such as https://portal.example.com", origin)
+		}
+	}
+	if c.DBMaxIdleConns > c.DBMaxOpenConns {
+		problem("database.max_idle_conns", "must not exceed database.max_open_conns (%d), got %d", c.DBMaxOpenConns, c.DBMaxIdleConns)
+	}
+	if c.RefreshTokenTTL <= c.AccessTokenTTL {
+		problem("auth.refresh_token_ttl", "must be longer than auth.access_token_ttl (%s), got %s", c.AccessTokenTTL, c.RefreshTokenTTL)
+	}
+	if c.PasswordMinClasses > 4 {
+		problem("password.min_classes", "must be at most 4, got %d", c.PasswordMinClasses)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AppointmentController handles appointment scheduling requests
+type AppointmentController struct {
+	AppointmentService services.AppointmentService
+	UserService        services.UserService
+	FieldService       services.FieldVisibilityService
+	AuditService       services.AuditService // the patients embedded in responses are audited as reads
+}
+
+// NewAppointmentController creates a new AppointmentController instance
+func NewAppointmentController(appointmentSvc services.AppointmentService, userSvc services.UserService, fieldSvc services.FieldVisibilityService, auditSvc services.AuditService) *AppointmentController {
+	return &AppointmentController{
+		AppointmentService: appointmentSvc,
+		UserService:        userSvc,
+		FieldService:       fieldSvc,
+		AuditService:       auditSvc,
+	}
+}
+
+// BookAppointmentRequest defines the request body for booking an appointment
+type BookAppointmentRequest struct {
+	PatientID uint      `json:"patient_id" binding:"required"`
+	DoctorID  uint      `json:"doctor_id" binding:"required"`
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+	Type      string    `json:"type" binding:"required"`
+	Reason    string    `json:"reason"`
+}
+
+// BookAppointment handles booking a new appointment (Receptionist role)
+func (ctrl *AppointmentController) BookAppointment(c *gin.Context) {
+	var req BookAppointmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	appointment := &models.Appointment{
+		PatientID: req.PatientID,
+		DoctorID:  req.DoctorID,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Type:      req.Type,
+		Reason:    req.Reason,
+	}
+	if err := ctrl.AppointmentService.BookAppointment(appointment); err != nil {
+		respondError(c, err, "Failed to book appointment")
+		return
+	}
+
+	ctrl.respondAppointment(c, http.StatusCreated, "Appointment booked successfully", appointment)
+}
+
+// RescheduleAppointmentRequest defines the request body for moving an appointment
+type RescheduleAppointmentRequest struct {
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+}
+
+// RescheduleAppointment handles moving a booked appointment to another time (Receptionist role)
+func (ctrl *AppointmentController) RescheduleAppointment(c *gin.Context) {
+	id, ok := appointmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req RescheduleAppointmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	appointment, err := ctrl.AppointmentService.RescheduleAppointment(id, req.StartTime, req.EndTime)
+	if err != nil {
+		respondError(c, err, "Failed to reschedule appointment")
+		return
+	}
+	ctrl.respondAppointment(c, http.StatusOK, "Appointment rescheduled successfully", appointment)
+}
+
+// CancelAppointment handles cancelling an appointment (Receptionist role)
+func (ctrl *AppointmentController) CancelAppointment(c *gin.Context) {
+	id, ok := appointmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional, a cancellation without a reason is allowed
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	appointment, err := ctrl.AppointmentService.CancelAppointment(id, req.Reason)
+	if err != nil {
+		respondError(c, err, "Failed to cancel appointment")
+		return
+	}
+	ctrl.respondAppointment(c, http.StatusOK, "Appointment cancelled successfully", appointment)
+}
+
+// frontDeskStates and attendingStates are the appointment states that appointment:write
+// and appointment:attend allow to set directly
+var (
+	frontDeskStates = []string{models.AppointmentCheckedIn, models.AppointmentNoShow}
+	attendingStates = []string{models.AppointmentInProgress, models.AppointmentCompleted}
+)
+
+// UpdateAppointmentState handles moving an appointment to its next state.
+// Front desk staff (appointment:write) check patients in or mark no-shows, doctors
+// (appointment:attend) start and complete their own visits.
+func (ctrl *AppointmentController) UpdateAppointmentState(c *gin.Context) {
+	id, ok := appointmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		State string `json:"state" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	switch {
+	case hasPermission(c, models.PermAppointmentWrite) && slices.Contains(frontDeskStates, req.State):
+	case hasPermission(c, models.PermAppointmentAttend) && slices.Contains(attendingStates, req.State):
+		doctor, ok := currentUser(c, ctrl.UserService)
+		if !ok {
+			return
+		}
+		existing, err := ctrl.AppointmentService.GetAppointmentByID(id)
+		if err != nil {
+			respondError(c, err, "Failed to update appointment")
+			return
+		}
+		if existing.DoctorID != doctor.ID {
+			respondProblem(c, http.StatusForbidden, "not_your_appointment", "Appointment belongs to another doctor")
+			return
+		}
+	default:
+		respondProblem(c, http.StatusForbidden, "appointment_state_forbidden", "Your role may not set the appointment to "+req.State)
+		return
+	}
+
+	appointment, err := ctrl.AppointmentService.UpdateAppointmentState(id, req.State)
+	if err != nil {
+		respondError(c, err, "Failed to update appointment")
+		return
+	}
+	ctrl.respondAppointment(c, http.StatusOK, "Appointment updated successfully", appointment)
+}
+
+// GetDoctorSchedule handles listing a doctor's appointments for one day (Receptionist role).
+// Query parameters: doctor_id (required) and date (YYYY-MM-DD, defaults to today).
+func (ctrl *AppointmentController) GetDoctorSchedule(c *gin.Context) {
+	doctorID, err := strconv.ParseUint(c.Query("doctor_id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid or missing doctor_id")
+		return
+	}
+	ctrl.respondSchedule(c, uint(doctorID))
+}
+
+// GetMySchedule handles listing the logged in doctor's appointments for one day (Doctor role).
+// Query parameter: date (YYYY-MM-DD, defaults to today).
+func (ctrl *AppointmentController) GetMySchedule(c *gin.Context) {
+	doctor, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+	ctrl.respondSchedule(c, doctor.ID)
+}
+
+// respondSchedule writes the day's schedule of a doctor, using the date query parameter
+func (ctrl *AppointmentController) respondSchedule(c *gin.Context, doctorID uint) {
+	day := time.Now()
+	if value := c.Query("date"); value != "" {
+		parsed, err := time.ParseInLocation(dateLayout, value, time.Local)
+		if err != nil {
+			respondProblem(c, http.StatusBadRequest, "invalid_query", "Invalid date, expected YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 0, 1)
+
+	appointments, err := ctrl.AppointmentService.GetDoctorSchedule(doctorID, from, to)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve schedule")
+		return
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+	patientIDs := []uint{}
+	for _, appointment := range appointments {
+		if appointment.Patient != nil && !slices.Contains(patientIDs, appointment.PatientID) {
+			patientIDs = append(patientIDs, appointment.PatientID)
+		}
+	}
+	if !recordReads(c, ctrl.AuditService, patientIDs...) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"date": from.Format(dateLayout), "appointments": view.Appointments(appointments)})
+}
+
+// respondAppointment writes an appointment, filtering its patient by the caller's field visibility
+func (ctrl *AppointmentController) respondAppointment(c *gin.Context, status int, message string, appointment *models.Appointment) {
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+	if appointment.Patient != nil && !recordReads(c, ctrl.AuditService, appointment.PatientID) {
+		return
+	}
+	c.JSON(status, gin.H{"message": message, "appointment": view.Appointment(appointment)})
+}
+
+// appointmentIDParam parses the :id path parameter, writing a 400 response when it is invalid
+func appointmentIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid appointment ID")
+		return 0, false
+	}
+	return uint(id), true
+}
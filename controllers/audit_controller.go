@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditController handles audit log queries (Admin role)
+type AuditController struct {
+	AuditService services.AuditService
+	FieldService services.FieldVisibilityService // the changes of entries are filtered like patient fields
+}
+
+// NewAuditController creates a new AuditController instance
+func NewAuditController(auditSvc services.AuditService, fieldSvc services.FieldVisibilityService) *AuditController {
+	return &AuditController{
+		AuditService: auditSvc,
+		FieldService: fieldSvc,
+	}
+}
+
+// ListEntries handles searching the audit log, newest first.
+// Query parameters: actor, action, patient_id, from, to, page and page_size.
+func (ctrl *AuditController) ListEntries(c *gin.Context) {
+	query, err := parseAuditQuery(c)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+	entries, total, err := ctrl.AuditService.ListEntries(query)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve audit log")
+		return
+	}
+	for i := range entries {
+		entries[i].Changes = view.AuditChanges(entries[i].Changes)
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "total": total, "page": query.Page, "page_size": query.PageSize})
+}
+
+// ExportEntries handles downloading every matching audit entry, oldest first.
+// Accepts the same filters as ListEntries plus format=csv|json (JSON lines, the default).
+// As in ListEntries the changes are filtered by the caller's field visibility, so the
+// hashes of entries with filtered changes cannot be recomputed from the export.
+func (ctrl *AuditController) ExportEntries(c *gin.Context) {
+	query, err := parseAuditQuery(c)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		respondProblem(c, http.StatusBadRequest, "invalid_query", "Invalid format. Must be 'json' or 'csv'")
+		return
+	}
+
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102T150405Z"), map[string]string{"json": "jsonl", "csv": "csv"}[format])
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var write func([]models.AuditLog) error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv")
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"id", "created_at", "actor", "role", "ip", "method", "route", "action", "patient_id", "changes", "prev_hash", "hash"})
+		write = func(entries []models.AuditLog) error {
+			for _, e := range entries {
+				patientID := ""
+				if e.PatientID != nil {
+					patientID = strconv.FormatUint(uint64(*e.PatientID), 10)
+				}
+				w.Write([]string{strconv.FormatUint(uint64(e.ID), 10), e.CreatedAt.UTC().Format(time.RFC3339Nano), e.Actor, e.Role, e.IP, e.Method, e.Route, e.Action, patientID, view.AuditChanges(e.Changes), e.PrevHash, e.Hash})
+			}
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(c.Writer)
+		write = func(entries []models.AuditLog) error {
+			for _, e := range entries {
+				e.Changes = view.AuditChanges(e.Changes)
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	c.Status(http.StatusOK)
+	if err := ctrl.AuditService.ExportEntries(query, write); err != nil {
+		// Headers are already sent, so the export can only be cut short
+		log.Printf("Failed to export audit log: %v", err)
+	}
+}
+
+// VerifyChain handles checking that no audit entry has been altered or removed
+func (ctrl *AuditController) VerifyChain(c *gin.Context) {
+	report, err := ctrl.AuditService.VerifyChain()
+	if err != nil {
+		log.Printf("Failed to verify audit chain: %v", err)
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to verify audit log")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// parseAuditQuery reads the audit filters from the query string
+func parseAuditQuery(c *gin.Context) (services.AuditQuery, error) {
+	query := services.AuditQuery{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+	}
+
+	var err error
+	if query.Page, err = intQuery(c, "page", 1); err != nil {
+		return query, err
+	}
+	if query.PageSize, err = intQuery(c, "page_size", services.DefaultPatientPageSize); err != nil {
+		return query, err
+	}
+	if value := c.Query("patient_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return query, fmt.Errorf("invalid patient_id")
+		}
+		patientID := uint(id)
+		query.PatientID = &patientID
+	}
+	if value := c.Query("from"); value != "" {
+		t, err := parseTimeBound(value, false)
+		if err != nil {
+			return query, fmt.Errorf("invalid from, expected YYYY-MM-DD or RFC 3339 timestamp")
+		}
+		query.From = &t
+	}
+	if value := c.Query("to"); value != "" {
+		t, err := parseTimeBound(value, true)
+		if err != nil {
+			return query, fmt.Errorf("invalid to, expected YYYY-MM-DD or RFC 3339 timestamp")
+		}
+		query.To = &t
+	}
+	return query, nil
+}
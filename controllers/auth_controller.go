@@ -7,22 +7,27 @@ import (
 	"medical_app/services"
 	"medical_app/utils"
 	"net/http"
+	"time"
 	"github.com/gin-gonic/gin"
 )
 
 // AuthController handles authentication requests
 type AuthController struct {
-	AuthService *services.AuthServiceImpl
-	UserService *services.UserServiceImpl
-	Config      *config.Config
+	AuthService  services.AuthService
+	UserService  services.UserService
+	TokenService services.TokenService
+	MFAService   services.MFAService
+	Config       *config.Config
 }
 
 // NewAuthController creates a new AuthController instance
-func NewAuthController(authSvc *services.AuthServiceImpl, userSvc *services.UserServiceImpl, cfg *config.Config) *AuthController {
+func NewAuthController(authSvc services.AuthService, userSvc services.UserService, tokenSvc services.TokenService, mfaSvc services.MFAService, cfg *config.Config) *AuthController {
 	return &AuthController{
-		AuthService: authSvc,
-		UserService: userSvc,
-		Config:      cfg,
+		AuthService:  authSvc,
+		UserService:  userSvc,
+		TokenService: tokenSvc,
+		MFAService:   mfaSvc,
+		Config:       cfg,
 	}
 }
 
@@ -36,71 +41,260 @@ type LoginRequest struct {
 func (ctrl *AuthController) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	user, err := ctrl.AuthService.Login(req.Username, req.Password)
+	user, err := ctrl.AuthService.Login(req.Username, req.Password, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err, "Failed to log in")
 		return
 	}
 
-	token, err := utils.GenerateJWT(user.Username, user.Role, ctrl.Config)
+	// A second factor is needed before any access token is handed out
+	step, err := ctrl.MFAService.LoginStep(user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		log.Printf("Failed to check MFA for %s: %v", user.Username, err)
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to log in")
+		return
+	}
+	if step != services.LoginComplete {
+		mfaToken, err := utils.GenerateMFAToken(user.Username, step, ctrl.Config)
+		if err != nil {
+			respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to generate token")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":                 "Two-factor authentication required",
+			"mfa_required":            step == services.LoginNeedsMFA,
+			"mfa_enrollment_required": step == services.LoginNeedsEnrollment,
+			"mfa_token":               mfaToken,
+		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
-		"user": gin.H{
-			"username": user.Username,
-			"role":     user.Role,
-		},
+	ctrl.completeLogin(c, user, nil)
+}
+
+// MFALoginRequest defines the request body for the second step of a login
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+// LoginMFA handles the second login step for users with MFA enabled
+func (ctrl *AuthController) LoginMFA(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	claims, ok := ctrl.mfaClaims(c, req.MFAToken, utils.PurposeMFAVerify)
+	if !ok {
+		return
+	}
+
+	user, err := ctrl.AuthService.SecondFactor(claims.Username, c.ClientIP(), func(user *models.User) error {
+		return ctrl.MFAService.Verify(user, req.Code)
 	})
+	if err != nil {
+		respondError(c, err, "Failed to log in")
+		return
+	}
+	ctrl.completeLogin(c, user, nil)
 }
 
-// RegisterUserRequest defines the request body for user registration
-type RegisterUserRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
-	Role     string `json:"role" binding:"required"` // "receptionist" or "doctor"
+// LoginMFAEnroll starts MFA enrollment for a user whose role requires it but who has not set it up
+func (ctrl *AuthController) LoginMFAEnroll(c *gin.Context) {
+	var req struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	claims, ok := ctrl.mfaClaims(c, req.MFAToken, utils.PurposeMFAEnroll)
+	if !ok {
+		return
+	}
+
+	var enrollment *services.MFAEnrollment
+	_, err := ctrl.AuthService.SecondFactor(claims.Username, c.ClientIP(), func(user *models.User) error {
+		var err error
+		enrollment, err = ctrl.MFAService.BeginEnrollment(user)
+		return err
+	})
+	if err != nil {
+		respondError(c, err, "Failed to log in")
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// LoginMFAConfirm finishes enrollment during login and logs the user in
+func (ctrl *AuthController) LoginMFAConfirm(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	claims, ok := ctrl.mfaClaims(c, req.MFAToken, utils.PurposeMFAEnroll)
+	if !ok {
+		return
+	}
+
+	var recoveryCodes []string
+	user, err := ctrl.AuthService.SecondFactor(claims.Username, c.ClientIP(), func(user *models.User) error {
+		var err error
+		recoveryCodes, err = ctrl.MFAService.ConfirmEnrollment(user, req.Code)
+		return err
+	})
+	if err != nil {
+		respondError(c, err, "Failed to log in")
+		return
+	}
+	ctrl.completeLogin(c, user, recoveryCodes)
 }
 
-// RegisterUser handles new user registration
-func (ctrl *AuthController) RegisterUser(c *gin.Context) {
-	var req RegisterUserRequest
+// completeLogin issues the access and refresh tokens once every login step passed
+func (ctrl *AuthController) completeLogin(c *gin.Context, user *models.User, recoveryCodes []string) {
+	tokens, err := ctrl.TokenService.IssueTokens(user)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to generate token")
+		return
+	}
+
+	resp := tokenResponse("Login successful", tokens, user)
+	if recoveryCodes != nil {
+		resp["recovery_codes"] = recoveryCodes
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// mfaClaims validates an MFA token or writes a 401
+func (ctrl *AuthController) mfaClaims(c *gin.Context, token, purpose string) (*utils.Claims, bool) {
+	claims, err := utils.ValidateMFAToken(token, purpose, ctrl.Config)
+	if err != nil {
+		respondProblem(c, http.StatusUnauthorized, "invalid_mfa_token", "Invalid or expired MFA token")
+		return nil, false
+	}
+	return claims, true
+}
+
+
+// RefreshTokenRequest defines the request body for refreshing and for logging out
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken handles exchanging a refresh token for a new access and refresh token
+func (ctrl *AuthController) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
+		return
+	}
+
+	tokens, user, err := ctrl.TokenService.Refresh(req.RefreshToken)
+	if err != nil {
+		respondError(c, err, "Failed to refresh token")
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse("Token refreshed", tokens, user))
+}
+
+// Logout handles revoking the caller's access token and, when sent, their refresh token family.
+// With ?all=true every session of the user is ended.
+func (ctrl *AuthController) Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// The body is optional, logging out with only the access token is allowed
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	expiresAt, _ := c.Get("token_expires_at")
+	accessExpiresAt, _ := expiresAt.(time.Time)
+	if err := ctrl.TokenService.Logout(c.GetString("jti"), accessExpiresAt, req.RefreshToken); err != nil {
+		log.Printf("Failed to log out %s: %v", c.GetString("username"), err)
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to log out")
 		return
 	}
 
-	// Basic validation for role
-	if req.Role != "receptionist" && req.Role != "doctor" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'receptionist' or 'doctor'"})
+	if c.Query("all") == "true" {
+		user, ok := currentUser(c, ctrl.UserService)
+		if !ok {
+			return
+		}
+		if err := ctrl.TokenService.RevokeAllForUser(user.ID); err != nil {
+			log.Printf("Failed to revoke sessions of %s: %v", user.Username, err)
+			respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to log out other sessions")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// ChangePasswordRequest defines the request body for changing one's own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword handles a user changing their own password. Every other session of the
+// user is ended and the caller gets a fresh token pair.
+func (ctrl *AuthController) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	caller, ok := currentUser(c, ctrl.UserService)
+	if !ok {
 		return
 	}
 
-	// Check if user already exists
-	_, err := ctrl.UserService.GetUserByUsername(req.Username)
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+	user, err := ctrl.UserService.ChangePassword(caller.ID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		respondError(c, err, "Failed to change password")
 		return
 	}
 
-	user := &models.User{
-		Username: req.Username,
-		Password: req.Password, // Password will be hashed in service layer
-		Role:     req.Role,
+	if err := ctrl.TokenService.RevokeAllForUser(user.ID); err != nil {
+		log.Printf("Failed to revoke sessions of %s: %v", user.Username, err)
+	}
+	expiresAt, _ := c.Get("token_expires_at")
+	accessExpiresAt, _ := expiresAt.(time.Time)
+	if err := ctrl.TokenService.Logout(c.GetString("jti"), accessExpiresAt, ""); err != nil {
+		log.Printf("Failed to revoke access token of %s: %v", user.Username, err)
 	}
 
-	if err := ctrl.UserService.CreateUser(user); err != nil {
-		log.Printf("Failed to register user: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+	tokens, err := ctrl.TokenService.IssueTokens(user)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to generate token")
 		return
 	}
+	c.JSON(http.StatusOK, tokenResponse("Password changed", tokens, user))
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "username": user.Username, "role": user.Role})
+// tokenResponse builds the body returned after a login or refresh
+func tokenResponse(message string, tokens *services.TokenPair, user *models.User) gin.H {
+	return gin.H{
+		"message":            message,
+		"token":              tokens.AccessToken,
+		"expires_in":         int(tokens.AccessExpiresIn.Seconds()),
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+		"user": gin.H{
+			"username":             user.Username,
+			"role":                 user.Role,
+			"must_change_password": user.MustChangePassword,
+		},
+	}
 }
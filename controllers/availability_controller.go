@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AvailabilityController handles doctor working hours and free slot requests
+type AvailabilityController struct {
+	AvailabilityService services.AvailabilityService
+	UserService         services.UserService
+}
+
+// NewAvailabilityController creates a new AvailabilityController instance
+func NewAvailabilityController(availabilitySvc services.AvailabilityService, userSvc services.UserService) *AvailabilityController {
+	return &AvailabilityController{
+		AvailabilityService: availabilitySvc,
+		UserService:         userSvc,
+	}
+}
+
+// ListDoctors handles listing the doctors patients can be booked with (Receptionist & Doctor roles)
+func (ctrl *AvailabilityController) ListDoctors(c *gin.Context) {
+	doctors, err := ctrl.UserService.ListUsersWithPermission(models.PermAppointmentAttend)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve doctors")
+		return
+	}
+
+	result := make([]gin.H, 0, len(doctors))
+	for _, doctor := range doctors {
+		result = append(result, gin.H{"id": doctor.ID, "username": doctor.Username})
+	}
+	c.JSON(http.StatusOK, gin.H{"doctors": result})
+}
+
+// AvailabilityBlock is one recurring block of working hours in requests and responses
+type AvailabilityBlock struct {
+	Weekday     int    `json:"weekday"` // 0 = Sunday ... 6 = Saturday
+	StartTime   string `json:"start_time" binding:"required"`
+	EndTime     string `json:"end_time" binding:"required"`
+	SlotMinutes int    `json:"slot_minutes"`
+}
+
+// GetMyAvailability handles reading the logged in doctor's weekly working hours (Doctor role)
+func (ctrl *AvailabilityController) GetMyAvailability(c *gin.Context) {
+	doctor, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	availability, err := ctrl.AvailabilityService.GetWeeklyAvailability(doctor.ID)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve availability")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"availability": toAvailabilityBlocks(availability)})
+}
+
+// SetMyAvailability handles replacing the logged in doctor's weekly working hours (Doctor role)
+func (ctrl *AvailabilityController) SetMyAvailability(c *gin.Context) {
+	doctor, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Availability []AvailabilityBlock `json:"availability" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	availability := make([]models.DoctorAvailability, 0, len(req.Availability))
+	for _, block := range req.Availability {
+		availability = append(availability, models.DoctorAvailability{
+			Weekday:     block.Weekday,
+			StartTime:   block.StartTime,
+			EndTime:     block.EndTime,
+			SlotMinutes: block.SlotMinutes,
+		})
+	}
+
+	if err := ctrl.AvailabilityService.SetWeeklyAvailability(doctor.ID, availability); err != nil {
+		respondError(c, err, "Failed to save availability")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Availability updated successfully", "availability": toAvailabilityBlocks(availability)})
+}
+
+// ListMyExceptions handles listing the logged in doctor's upcoming leave and holidays (Doctor role).
+// Query parameters: from and to (YYYY-MM-DD or RFC 3339), defaulting to the next 90 days.
+func (ctrl *AvailabilityController) ListMyExceptions(c *gin.Context) {
+	doctor, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	from, to, ok := rangeQuery(c, time.Now(), 90)
+	if !ok {
+		return
+	}
+	exceptions, err := ctrl.AvailabilityService.ListExceptions(doctor.ID, from, to)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve exceptions")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"exceptions": exceptions})
+}
+
+// AddExceptionRequest defines the request body for blocking out a doctor's time
+type AddExceptionRequest struct {
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+	Reason    string    `json:"reason"`
+}
+
+// AddMyException handles blocking out time in the logged in doctor's calendar (Doctor role)
+func (ctrl *AvailabilityController) AddMyException(c *gin.Context) {
+	doctor, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	var req AddExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	exception := &models.AvailabilityException{
+		DoctorID:  doctor.ID,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Reason:    req.Reason,
+	}
+	if err := ctrl.AvailabilityService.AddException(exception); err != nil {
+		respondError(c, err, "Failed to add exception")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Exception added successfully", "exception": exception})
+}
+
+// DeleteMyException handles removing one of the logged in doctor's exceptions (Doctor role)
+func (ctrl *AvailabilityController) DeleteMyException(c *gin.Context) {
+	doctor, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid exception ID")
+		return
+	}
+	if err := ctrl.AvailabilityService.DeleteException(doctor.ID, uint(id)); err != nil {
+		respondError(c, err, "Failed to delete exception")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Exception deleted successfully"})
+}
+
+// GetFreeSlots handles computing a doctor's free slots (Receptionist & Doctor roles).
+// Query parameters: from and to (YYYY-MM-DD or RFC 3339), defaulting to the next 7 days.
+func (ctrl *AvailabilityController) GetFreeSlots(c *gin.Context) {
+	doctorID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid doctor ID")
+		return
+	}
+
+	now := time.Now()
+	from, to, ok := rangeQuery(c, now, 7)
+	if !ok {
+		return
+	}
+	// Slots that have already started can no longer be booked
+	if from.Before(now) {
+		from = now
+	}
+
+	slots, err := ctrl.AvailabilityService.FreeSlots(uint(doctorID), from, to)
+	if err != nil {
+		respondError(c, err, "Failed to compute free slots")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"doctor_id": doctorID, "from": from, "to": to, "slots": slots})
+}
+
+// rangeQuery reads the from/to query parameters in server local time, defaulting to
+// days days starting at def. It writes a 400 response when a value is malformed.
+func rangeQuery(c *gin.Context, def time.Time, days int) (time.Time, time.Time, bool) {
+	from := def
+	if value := c.Query("from"); value != "" {
+		t, err := parseLocalTimeBound(value, false)
+		if err != nil {
+			respondProblem(c, http.StatusBadRequest, "invalid_query", "Invalid from, expected YYYY-MM-DD or RFC 3339 timestamp")
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	to := from.AddDate(0, 0, days)
+	if value := c.Query("to"); value != "" {
+		t, err := parseLocalTimeBound(value, true)
+		if err != nil {
+			respondProblem(c, http.StatusBadRequest, "invalid_query", "Invalid to, expected YYYY-MM-DD or RFC 3339 timestamp")
+			return time.Time{}, time.Time{}, false
+		}
+		to = t
+	}
+	return from, to, true
+}
+
+// parseLocalTimeBound is parseTimeBound with date-only values taken in the server's time zone,
+// which is the zone weekly working hours are expressed in
+func parseLocalTimeBound(value string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Local(), nil
+	}
+	t, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// toAvailabilityBlocks converts stored working hours into their API representation
+func toAvailabilityBlocks(availability []models.DoctorAvailability) []AvailabilityBlock {
+	blocks := make([]AvailabilityBlock, 0, len(availability))
+	for _, a := range availability {
+		blocks = append(blocks, AvailabilityBlock{
+			Weekday:     a.Weekday,
+			StartTime:   a.StartTime,
+			EndTime:     a.EndTime,
+			SlotMinutes: a.SlotMinutes,
+		})
+	}
+	return blocks
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EncounterNoteController handles structured clinical note requests
+type EncounterNoteController struct {
+	UnitOfWork   services.UnitOfWork // changes and their audit entries are committed together
+	NoteService  services.EncounterNoteService
+	UserService  services.UserService
+	AuditService services.AuditService
+}
+
+// NewEncounterNoteController creates a new EncounterNoteController instance
+func NewEncounterNoteController(uow services.UnitOfWork, noteSvc services.EncounterNoteService, userSvc services.UserService, auditSvc services.AuditService) *EncounterNoteController {
+	return &EncounterNoteController{
+		UnitOfWork:   uow,
+		NoteService:  noteSvc,
+		UserService:  userSvc,
+		AuditService: auditSvc,
+	}
+}
+
+// NoteRequest defines the request body for writing or editing an encounter note
+type NoteRequest struct {
+	Subjective    string `json:"subjective"`
+	Objective     string `json:"objective"`
+	Assessment    string `json:"assessment"`
+	Plan          string `json:"plan"`
+	AppointmentID *uint  `json:"appointment_id"`
+	Sign          bool   `json:"sign"` // Sign immediately instead of saving a draft
+}
+
+// content returns the SOAP sections of the request
+func (r NoteRequest) content() services.SOAPContent {
+	return services.SOAPContent{
+		Subjective: r.Subjective,
+		Objective:  r.Objective,
+		Assessment: r.Assessment,
+		Plan:       r.Plan,
+	}
+}
+
+// CreateNote handles writing a new encounter note for a patient (Doctor role)
+func (ctrl *EncounterNoteController) CreateNote(c *gin.Context) {
+	patientID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+	author, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	var req NoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	var note *models.EncounterNote
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		if note, err = tx.Notes.CreateNote(uint(patientID), author, req.content(), req.AppointmentID, req.Sign); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditCreate, note.PatientID, nil, note)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create encounter note")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Encounter note created successfully", "note": note})
+}
+
+// ListNotes handles listing a patient's encounter notes, newest first (Doctor role)
+func (ctrl *EncounterNoteController) ListNotes(c *gin.Context) {
+	patientID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+	viewer, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	notes, err := ctrl.NoteService.ListNotes(uint(patientID), viewer.ID)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve encounter notes")
+		return
+	}
+	if !recordReads(c, ctrl.AuditService, uint(patientID)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"notes": notes})
+}
+
+// GetNote handles reading a single encounter note (Doctor role)
+func (ctrl *EncounterNoteController) GetNote(c *gin.Context) {
+	id, ok := noteIDParam(c)
+	if !ok {
+		return
+	}
+	viewer, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	note, err := ctrl.NoteService.GetNote(id, viewer.ID)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve encounter note")
+		return
+	}
+	if !recordReads(c, ctrl.AuditService, note.PatientID) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"note": note})
+}
+
+// UpdateDraft handles editing one of your own unsigned notes (Doctor role)
+func (ctrl *EncounterNoteController) UpdateDraft(c *gin.Context) {
+	id, ok := noteIDParam(c)
+	if !ok {
+		return
+	}
+	author, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	var req NoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// Saving the draft and signing it succeed or fail together
+	var note *models.EncounterNote
+	err := ctrl.UnitOfWork.Do(func(tx *services.Services) error {
+		before, err := tx.Notes.GetNote(id, author.ID)
+		if err != nil {
+			return err
+		}
+		if note, err = tx.Notes.UpdateDraft(id, author, req.content()); err != nil {
+			return err
+		}
+		if req.Sign {
+			if note, err = tx.Notes.SignNote(id, author); err != nil {
+				return err
+			}
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditUpdate, note.PatientID, before, note)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to update encounter note")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Encounter note updated successfully", "note": note})
+}
+
+// SignNote handles signing one of your own drafts (Doctor role)
+func (ctrl *EncounterNoteController) SignNote(c *gin.Context) {
+	id, ok := noteIDParam(c)
+	if !ok {
+		return
+	}
+	author, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	var note *models.EncounterNote
+	err := ctrl.UnitOfWork.Do(func(tx *services.Services) error {
+		before, err := tx.Notes.GetNote(id, author.ID)
+		if err != nil {
+			return err
+		}
+		if note, err = tx.Notes.SignNote(id, author); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditUpdate, note.PatientID, before, note)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to sign encounter note")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Encounter note signed successfully", "note": note})
+}
+
+// AmendNoteRequest defines the request body for amending a signed note
+type AmendNoteRequest struct {
+	NoteRequest
+	Reason string `json:"reason" binding:"required"`
+}
+
+// AmendNote handles correcting a signed note. The original stays as it was and the
+// amendment is stored as a new note linked to it (Doctor role).
+func (ctrl *EncounterNoteController) AmendNote(c *gin.Context) {
+	id, ok := noteIDParam(c)
+	if !ok {
+		return
+	}
+	author, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	var req AmendNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	var note *models.EncounterNote
+	err := ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		if note, err = tx.Notes.AmendNote(id, author, req.content(), req.Reason, req.Sign); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditCreate, note.PatientID, nil, note)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to amend encounter note")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Encounter note amended successfully", "note": note})
+}
+
+// noteIDParam parses the :id path parameter, writing a 400 response when it is invalid
+func noteIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid note ID")
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"medical_app/services"
+	"medical_app/utils"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// problemStatus is the response status of each kind of service error; other kinds are 500
+var problemStatus = map[services.ErrorKind]int{
+	services.KindValidation:   http.StatusBadRequest,
+	services.KindUnauthorized: http.StatusUnauthorized,
+	services.KindForbidden:    http.StatusForbidden,
+	services.KindNotFound:     http.StatusNotFound,
+	services.KindConflict:     http.StatusConflict,
+	services.KindStale:        http.StatusPreconditionFailed,
+	services.KindRateLimited:  http.StatusTooManyRequests,
+}
+
+func init() {
+	// Report invalid request fields by their JSON names, as clients send them
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// respondProblem writes an RFC 7807 problem details response for an error the handler
+// found itself, such as an invalid path parameter
+func respondProblem(c *gin.Context, status int, code, detail string) {
+	utils.AbortWithProblem(c, status, code, detail, nil)
+}
+
+// respondError maps a service error to a problem details response. Errors of the services
+// layer (services.Error) are reported with their code and message, field errors with a
+// "fields" object. Anything else is logged and reported as a 500 with the fallback message.
+func respondError(c *gin.Context, err error, fallback string) {
+	var fieldErrors services.FieldErrors
+	var transitionErr *services.PatientTransitionError
+	var throttled *services.LoginThrottledError
+	var weak *utils.PasswordPolicyError
+	extensions := gin.H{}
+	detail := err.Error()
+
+	switch {
+	case errors.As(err, &fieldErrors):
+		extensions["fields"] = fieldErrors
+	case errors.As(err, &transitionErr):
+		detail = "Cannot change patient status from " + transitionErr.From + " to " + transitionErr.To
+		extensions["from"] = transitionErr.From
+		extensions["to"] = transitionErr.To
+		extensions["allowed"] = transitionErr.Allowed
+	case errors.As(err, &throttled):
+		retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		extensions["locked"] = throttled.Locked
+		extensions["retry_after"] = retryAfter
+	case errors.As(err, &weak):
+		utils.AbortWithProblem(c, http.StatusBadRequest, "weak_password", detail, gin.H{"problems": weak.Problems})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondProblem(c, http.StatusNotFound, "not_found", "Record not found")
+		return
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		respondProblem(c, http.StatusConflict, "duplicate", "A record with the same unique values already exists")
+		return
+	}
+
+	domainErr := services.AsError(err)
+	status, known := 0, false
+	if domainErr != nil {
+		status, known = problemStatus[domainErr.Kind]
+	}
+	if !known {
+		log.Printf("%s: %v", fallback, err)
+		respondProblem(c, http.StatusInternalServerError, "internal_error", fallback)
+		return
+	}
+	utils.AbortWithProblem(c, status, domainErr.Code, detail, extensions)
+}
+
+// respondBindError reports a request body that could not be read into the request struct.
+// Missing or invalid fields are listed in "fields" under their JSON names.
+func respondBindError(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	fields := services.FieldErrors{}
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fieldErr := range validationErrs {
+			fields[fieldPath(fieldErr)] = validationMessage(fieldErr)
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		fields[typeErr.Field] = "must be a " + jsonTypeName(typeErr.Type)
+	case errors.Is(err, io.EOF):
+		respondProblem(c, http.StatusBadRequest, "invalid_body", "Request body is required")
+		return
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		respondProblem(c, http.StatusBadRequest, "invalid_body", "Request body is not valid JSON")
+		return
+	default:
+		respondProblem(c, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	respondError(c, fields, "Invalid request body")
+}
+
+// fieldPath is the JSON path of a struct field, without the name of the request struct
+func fieldPath(fieldErr validator.FieldError) string {
+	_, path, found := strings.Cut(fieldErr.Namespace(), ".")
+	if !found {
+		return fieldErr.Field()
+	}
+	return path
+}
+
+// validationMessage explains a failed binding tag
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")
+	case "min":
+		return "must be at least " + fieldErr.Param()
+	case "max":
+		return "must be at most " + fieldErr.Param()
+	case "email":
+		return "must be a valid email address"
+	}
+	return "is invalid (" + fieldErr.Tag() + ")"
+}
+
+// jsonTypeName names a Go type the way JSON documents call it
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	}
+	return "object"
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser loads the account of the authenticated caller, writing a 403 response when it no longer exists
+func currentUser(c *gin.Context, userSvc services.UserService) (*models.User, bool) {
+	user, err := userSvc.GetUserByUsername(c.GetString("username"))
+	if err != nil {
+		respondProblem(c, http.StatusForbidden, "account_not_found", "User account not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// hasPermission reports whether the caller's role grants the permission
+func hasPermission(c *gin.Context, permission string) bool {
+	return slices.Contains(c.GetStringSlice("permissions"), permission)
+}
+
+// patientView loads the caller's patient field visibility, writing a 500 response when it fails
+func patientView(c *gin.Context, fieldSvc services.FieldVisibilityService) (*services.PatientView, bool) {
+	view, err := fieldSvc.PatientView(c.GetString("role"))
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to load field visibility")
+		return nil, false
+	}
+	return view, true
+}
+
+// auditActor describes the authenticated caller and the current request for the audit log
+func auditActor(c *gin.Context) services.AuditActor {
+	return services.AuditActor{
+		Username: c.GetString("username"),
+		Role:     c.GetString("role"),
+		IP:       c.ClientIP(),
+		Method:   c.Request.Method,
+		Route:    c.FullPath(),
+	}
+}
+
+// recordReads audits that the given patients were read. Patient data must never leave
+// unaudited, so a failure writes a 500 response and returns false.
+func recordReads(c *gin.Context, auditSvc services.AuditService, patientIDs ...uint) bool {
+	if err := auditSvc.RecordReads(auditActor(c), patientIDs); err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to write audit log")
+		return false
+	}
+	return true
+}
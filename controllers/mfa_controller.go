@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"medical_app/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAController handles two-factor authentication setup for the logged in user and the admin MFA policy
+type MFAController struct {
+	MFAService  services.MFAService
+	UserService services.UserService
+}
+
+// NewMFAController creates a new MFAController instance
+func NewMFAController(mfaSvc services.MFAService, userSvc services.UserService) *MFAController {
+	return &MFAController{
+		MFAService:  mfaSvc,
+		UserService: userSvc,
+	}
+}
+
+// MFACodeRequest defines the request body for actions confirmed with an authentication code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+// BeginEnrollment handles creating a TOTP secret for the caller
+func (ctrl *MFAController) BeginEnrollment(c *gin.Context) {
+	user, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	enrollment, err := ctrl.MFAService.BeginEnrollment(user)
+	if err != nil {
+		respondMFAError(c, err, "Failed to start MFA enrollment")
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmEnrollment handles turning MFA on with a code from the authenticator app
+func (ctrl *MFAController) ConfirmEnrollment(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	user, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	codes, err := ctrl.MFAService.ConfirmEnrollment(user, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "Failed to enable MFA")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// Disable handles turning MFA off for the caller
+func (ctrl *MFAController) Disable(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	user, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.MFAService.Disable(user, req.Code); err != nil {
+		respondMFAError(c, err, "Failed to disable MFA")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes handles replacing the caller's recovery codes
+func (ctrl *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	user, ok := currentUser(c, ctrl.UserService)
+	if !ok {
+		return
+	}
+
+	codes, err := ctrl.MFAService.RegenerateRecoveryCodes(user, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ListPolicies handles reading the MFA policy of every role (Admin role)
+func (ctrl *MFAController) ListPolicies(c *gin.Context) {
+	policies, err := ctrl.MFAService.ListPolicies()
+	if err != nil {
+		respondMFAError(c, err, "Failed to retrieve MFA policy")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// SetPolicy handles requiring MFA for a role (Admin role)
+func (ctrl *MFAController) SetPolicy(c *gin.Context) {
+	var req struct {
+		Role     string `json:"role" binding:"required"`
+		Required *bool  `json:"required" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := ctrl.MFAService.SetPolicy(req.Role, *req.Required); err != nil {
+		respondMFAError(c, err, "Failed to update MFA policy")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "MFA policy updated", "role": req.Role, "required": *req.Required})
+}
+
+// ResetUserMFA handles removing a user's MFA setup, e.g. after a lost phone (Admin role)
+func (ctrl *MFAController) ResetUserMFA(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	user, err := ctrl.UserService.GetUserByID(id)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve user")
+		return
+	}
+
+	if err := ctrl.MFAService.Reset(user.ID); err != nil {
+		respondMFAError(c, err, "Failed to reset MFA")
+		return
+	}
+	log.Printf("MFA of %s reset by %s", user.Username, c.GetString("username"))
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// respondMFAError maps MFA service errors to problem details responses
+func respondMFAError(c *gin.Context, err error, fallback string) {
+	if errors.Is(err, services.ErrInvalidMFACode) {
+		// Not a 401: the caller's session is fine, only the code is wrong
+		respondProblem(c, http.StatusBadRequest, services.ErrInvalidMFACode.Code, err.Error())
+		return
+	}
+	respondError(c, err, fallback)
+}
@@ -1,77 +1,340 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"medical_app/models"
 	"medical_app/services"
+	"medical_app/utils"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 // PatientController handles patient-related requests
 type PatientController struct {
-	PatientService *services.PatientServiceImpl
+	UnitOfWork     services.UnitOfWork // changes and their audit entries are committed together
+	PatientService services.PatientService
+	AuditService   services.AuditService
+	FieldService   services.FieldVisibilityService
 }
 
 // NewPatientController creates a new PatientController instance
-func NewPatientController(patientSvc *services.PatientServiceImpl) *PatientController {
+func NewPatientController(uow services.UnitOfWork, patientSvc services.PatientService, auditSvc services.AuditService, fieldSvc services.FieldVisibilityService) *PatientController {
 	return &PatientController{
+		UnitOfWork:     uow,
 		PatientService: patientSvc,
+		AuditService:   auditSvc,
+		FieldService:   fieldSvc,
 	}
 }
 
-// CreatePatientRequest defines the request body for creating a patient
+// CreatePatientRequest defines the request body for creating a patient. A phone number or
+// an email address is required.
 type CreatePatientRequest struct {
-	FirstName string `json:"first_name" binding:"required"`
-	LastName  string `json:"last_name" binding:"required"`
-	DOB       string `json:"dob"`
-	Gender    string `json:"gender"`
-	Contact   string `json:"contact" binding:"required"`
-	Address   string `json:"address"`
+	FirstName         string                    `json:"first_name" binding:"required"`
+	LastName          string                    `json:"last_name" binding:"required"`
+	DOB               string                    `json:"dob"`    // YYYY-MM-DD
+	Gender            string                    `json:"gender"` // female, male, other or unknown
+	Phone             string                    `json:"phone"`
+	SecondaryPhone    string                    `json:"secondary_phone"`
+	Email             string                    `json:"email"`
+	Address           AddressRequest            `json:"address"`
+	EmergencyContacts []EmergencyContactRequest `json:"emergency_contacts"`
+
+	AllowDuplicate bool `json:"allow_duplicate"` // register even if possible duplicates were found
+}
+
+// AddressRequest is a structured address in patient requests
+type AddressRequest struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+// EmergencyContactRequest is an emergency contact in patient requests. A phone number or
+// an email address is required.
+type EmergencyContactRequest struct {
+	Name         string `json:"name"`
+	Relationship string `json:"relationship"`
+	Phone        string `json:"phone"`
+	Email        string `json:"email"`
+}
+
+// emergencyContacts converts emergency contacts from a request into models
+func emergencyContacts(requests []EmergencyContactRequest) []models.EmergencyContact {
+	contacts := make([]models.EmergencyContact, 0, len(requests))
+	for _, req := range requests {
+		contacts = append(contacts, models.EmergencyContact{
+			Name:         req.Name,
+			Relationship: req.Relationship,
+			Phone:        req.Phone,
+			Email:        req.Email,
+		})
+	}
+	return contacts
 }
 
-// CreatePatient handles creating a new patient (Receptionist role)
+// CreatePatient handles creating a new patient (Receptionist role). When the patient looks
+// like an existing one the request fails with 409 and the possible duplicates, unless
+// allow_duplicate is set.
 func (ctrl *PatientController) CreatePatient(c *gin.Context) {
 	var req CreatePatientRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
+		return
+	}
+	dob, err := services.ParsePatientDOB(strings.TrimSpace(req.DOB))
+	if err != nil {
+		respondError(c, services.FieldErrors{"dob": err.Error()}, "Failed to create patient")
+		return
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
 		return
 	}
 
 	patient := &models.Patient{
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		DOB:       req.DOB,
-		Gender:    req.Gender,
-		Contact:   req.Contact,
-		Address:   req.Address,
-		Status:    "active", // Default status
-	}
-
-	if err := ctrl.PatientService.CreatePatient(patient); err != nil {
-		// Check for unique constraint violation (e.g., contact)
-		if err.Error() == "UNIQUE constraint failed: patients.contact" { // This error message might vary based on DB driver/GORM version
-			c.JSON(http.StatusConflict, gin.H{"error": "Patient with this contact already exists"})
+		FirstName:         req.FirstName,
+		LastName:          req.LastName,
+		DOB:               dob,
+		Gender:            req.Gender,
+		Phone:             req.Phone,
+		SecondaryPhone:    req.SecondaryPhone,
+		Email:             req.Email,
+		Address:           models.Address(req.Address),
+		EmergencyContacts: emergencyContacts(req.EmergencyContacts),
+		Status:            models.PatientRegistered, // Every patient starts the status workflow here
+	}
+	if err := services.NormalizePatient(patient); err != nil {
+		respondError(c, err, "Failed to create patient")
+		return
+	}
+
+	// The duplicate check, the insert and its audit entry run in one transaction
+	var matches []services.DuplicateMatch
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		if !req.AllowDuplicate {
+			if matches, err = tx.Patients.FindDuplicates(patient); err != nil || len(matches) > 0 {
+				return err
+			}
+		}
+		if err := tx.Patients.CreatePatient(patient); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditCreate, patient.ID, nil, patient)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create patient")
+		return
+	}
+	if len(matches) > 0 {
+		response, ok := ctrl.duplicatesResponse(c, view, matches)
+		if !ok {
 			return
 		}
-		log.Printf("Failed to create patient: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create patient"})
+		utils.AbortWithProblem(c, http.StatusConflict, "possible_duplicates",
+			"Possible duplicate patients found. Check them, then resend with allow_duplicate set to register anyway.",
+			gin.H{"duplicates": response})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Patient created successfully", "patient": patient})
+	c.Header("ETag", patientETag(patient))
+	c.JSON(http.StatusCreated, gin.H{"message": "Patient created successfully", "patient": view.Patient(patient)})
 }
 
-// GetAllPatients handles retrieving all patient records (Receptionist & Doctor roles)
+// GetAllPatients handles listing patient records page by page (Receptionist & Doctor roles).
+// Supported query parameters: page, page_size, status, gender, dob_from, dob_to,
+// created_from, created_to, updated_from, updated_to and sort.
 func (ctrl *PatientController) GetAllPatients(c *gin.Context) {
-	patients, err := ctrl.PatientService.GetAllPatients()
+	opts, err := parsePatientListOptions(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve patients"})
+		respondProblem(c, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	page, err := ctrl.PatientService.ListPatients(opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPatientSort) {
+			respondProblem(c, http.StatusBadRequest, services.ErrInvalidPatientSort.Code, "Invalid sort. Must be one of last_name, -last_name, created_at, -created_at")
+			return
+		}
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve patients")
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"patients": patients})
+
+	ids := make([]uint, 0, len(page.Patients))
+	for _, patient := range page.Patients {
+		ids = append(ids, patient.ID)
+	}
+	if !recordReads(c, ctrl.AuditService, ids...) {
+		return
+	}
+
+	var next, prev interface{}
+	if page.HasNext() {
+		next = pageLink(c, page.Page+1)
+	}
+	if page.Page > 1 {
+		prev = pageLink(c, page.Page-1)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"patients":    view.Patients(page.Patients),
+		"total":       page.Total,
+		"page":        page.Page,
+		"page_size":   page.PageSize,
+		"total_pages": page.TotalPages(),
+		"next":        next,
+		"prev":        prev,
+	})
+}
+
+// parsePatientListOptions reads the listing filters from the query string
+func parsePatientListOptions(c *gin.Context) (services.PatientListOptions, error) {
+	opts := services.PatientListOptions{
+		Status: c.Query("status"),
+		Gender: c.Query("gender"),
+		Sort:   c.Query("sort"),
+	}
+
+	var err error
+	if opts.Page, err = intQuery(c, "page", 1); err != nil {
+		return opts, err
+	}
+	if opts.PageSize, err = intQuery(c, "page_size", services.DefaultPatientPageSize); err != nil {
+		return opts, err
+	}
+
+	if opts.DOBFrom, err = dateQuery(c, "dob_from"); err != nil {
+		return opts, err
+	}
+	if opts.DOBTo, err = dateQuery(c, "dob_to"); err != nil {
+		return opts, err
+	}
+
+	ranges := []struct {
+		param string
+		dst   **time.Time
+		upper bool
+	}{
+		{"created_from", &opts.CreatedFrom, false},
+		{"created_to", &opts.CreatedTo, true},
+		{"updated_from", &opts.UpdatedFrom, false},
+		{"updated_to", &opts.UpdatedTo, true},
+	}
+	for _, r := range ranges {
+		value := c.Query(r.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeBound(value, r.upper)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s, expected YYYY-MM-DD or RFC 3339 timestamp", r.param)
+		}
+		*r.dst = &t
+	}
+	return opts, nil
+}
+
+// dateLayout is the format used for date-only values such as DOB
+const dateLayout = models.DateLayout
+
+// parseTimeBound parses a date or RFC 3339 timestamp. A date used as an upper
+// bound is moved to the start of the following day so the whole day is included.
+func parseTimeBound(value string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// intQuery reads a positive integer query parameter, falling back to def when absent
+func intQuery(c *gin.Context, param string, def int) (int, error) {
+	value := c.Query(param)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s, must be a positive integer", param)
+	}
+	return n, nil
+}
+
+// dateQuery reads an optional "YYYY-MM-DD" query parameter
+func dateQuery(c *gin.Context, param string) (string, error) {
+	value := c.Query(param)
+	if value == "" {
+		return "", nil
+	}
+	if _, err := time.Parse(dateLayout, value); err != nil {
+		return "", fmt.Errorf("invalid %s, expected YYYY-MM-DD", param)
+	}
+	return value, nil
+}
+
+// pageLink rebuilds the current request URL pointing at another page
+func pageLink(c *gin.Context, page int) string {
+	query := c.Request.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	return c.Request.URL.Path + "?" + query.Encode()
+}
+
+// SearchPatients handles searching patients by partial name, phone, email, address or MRN (Receptionist & Doctor roles).
+// Query parameters: q (required, at least 2 characters) and limit.
+func (ctrl *PatientController) SearchPatients(c *gin.Context) {
+	term := strings.TrimSpace(c.Query("q"))
+	if len([]rune(term)) < 2 {
+		respondProblem(c, http.StatusBadRequest, "invalid_query", "Search query q must be at least 2 characters")
+		return
+	}
+
+	limit, err := intQuery(c, "limit", services.DefaultPatientSearchLimit)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	results, err := ctrl.PatientService.SearchPatients(term, limit)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to search patients")
+		return
+	}
+
+	ids := make([]uint, 0, len(results))
+	response := make([]gin.H, 0, len(results))
+	for i := range results {
+		ids = append(ids, results[i].Patient.ID)
+		response = append(response, gin.H{"patient": view.Patient(&results[i].Patient), "score": results[i].Score})
+	}
+	if !recordReads(c, ctrl.AuditService, ids...) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": response})
 }
 
 // GetPatientByID handles retrieving a single patient record by ID (Receptionist & Doctor roles)
@@ -79,139 +342,337 @@ func (ctrl *PatientController) GetPatientByID(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
 		return
 	}
 
 	patient, err := ctrl.PatientService.GetPatientByID(uint(id))
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+			respondProblem(c, http.StatusNotFound, services.ErrPatientNotFound.Code, "Patient not found")
 			return
 		}
 		log.Printf("Failed to get patient by ID %d: %v", id, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve patient"})
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve patient")
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"patient": patient})
+	if !recordReads(c, ctrl.AuditService, patient.ID) {
+		return
+	}
+	c.Header("ETag", patientETag(patient))
+	c.JSON(http.StatusOK, gin.H{"patient": view.Patient(patient)})
 }
 
-// UpdatePatientRequest defines the request body for updating a patient
-type UpdatePatientRequest struct {
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	DOB         string `json:"dob"`
-	Gender      string `json:"gender"`
-	Contact     string `json:"contact"`
-	Address     string `json:"address"`
-	DoctorNotes string `json:"doctor_notes"`
-	Status      string `json:"status"`
+// patientETag is the entity tag of a patient's current version
+func patientETag(patient *models.Patient) string {
+	return fmt.Sprintf(`"%d"`, patient.Version)
 }
 
-// UpdatePatient handles updating a patient record (Receptionist role for most fields, Doctor for notes/status)
-func (ctrl *PatientController) UpdatePatient(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseUint(idParam, 10, 64)
+// ifMatchVersion reads the patient version the caller last saw from the If-Match header.
+// "*" matches any version and yields 0. A missing header is answered with 428 and a
+// malformed one with 400.
+func ifMatchVersion(c *gin.Context) (uint, bool) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" {
+		respondProblem(c, http.StatusPreconditionRequired, "if_match_required", "If-Match header with the patient's ETag is required")
+		return 0, false
+	}
+	if value == "*" {
+		return 0, true
+	}
+	tag := strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.ParseUint(tag, 10, 64)
+	if err != nil || version == 0 {
+		respondProblem(c, http.StatusBadRequest, "invalid_if_match", "Invalid If-Match header, expected a patient ETag")
+		return 0, false
+	}
+	return uint(version), true
+}
+
+// respondVersionConflict answers 412 with the patient as it is now stored, so the
+// client can merge its changes and retry with the new ETag
+func (ctrl *PatientController) respondVersionConflict(c *gin.Context, id uint, view *services.PatientView) {
+	current, err := ctrl.PatientService.GetPatientByID(id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = services.ErrPatientNotFound
+		}
+		respondError(c, err, "Failed to update patient")
+		return
+	}
+	if !recordReads(c, ctrl.AuditService, current.ID) {
 		return
 	}
+	c.Header("ETag", patientETag(current))
+	utils.AbortWithProblem(c, http.StatusPreconditionFailed, services.ErrPatientVersionConflict.Code,
+		"Patient was modified by someone else. Review the current record and retry.",
+		gin.H{"patient": view.Patient(current)})
+}
 
-	var req UpdatePatientRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// PatchPatient handles changing a patient with JSON Merge Patch semantics (RFC 7396), for
+// PATCH /patients/:id and the older PUT /receptionist/patients/:id. Sent fields are set,
+// fields sent as null are cleared and absent fields are kept. Each field needs the
+// permission in models.PatientFieldWritePermissions; forbidden fields fail with 403.
+func (ctrl *PatientController) PatchPatient(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
 		return
 	}
 
-	patient, err := ctrl.PatientService.GetPatientByID(uint(id))
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	body, err := c.GetRawData()
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
-			return
+		respondProblem(c, http.StatusBadRequest, "invalid_body", "Failed to read request body")
+		return
+	}
+	patch, err := services.ParsePatientPatch(body)
+	if err != nil {
+		respondError(c, err, "Failed to update patient")
+		return
+	}
+
+	forbidden := services.FieldErrors{}
+	for _, field := range patch.Fields() {
+		if !hasPermission(c, models.PatientFieldWritePermissions[field]) {
+			forbidden[field] = "your role may not change this field"
 		}
-		log.Printf("Failed to get patient for update by ID %d: %v", id, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve patient for update"})
+	}
+	if len(forbidden) > 0 {
+		utils.AbortWithProblem(c, http.StatusForbidden, "forbidden_fields", "Some fields may not be changed by your role", gin.H{"fields": forbidden})
 		return
 	}
 
-	// Update fields selectively based on role if necessary, or just update all for now
-	// For simplicity, receptionist can update all fields except doctor_notes and status
-	// Doctor can update all and doctor_notes/status
-	// This can be refined with more granular ACLs if needed.
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
 
-	// General patient info update (receptionist)
-	if req.FirstName != "" {
-		patient.FirstName = req.FirstName
+	var before, patient *models.Patient
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		if before, patient, err = tx.Patients.PatchPatient(uint(id), version, patch); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditUpdate, patient.ID, before, patient)
+	})
+	if errors.Is(err, services.ErrPatientVersionConflict) {
+		ctrl.respondVersionConflict(c, uint(id), view)
+		return
 	}
-	if req.LastName != "" {
-		patient.LastName = req.LastName
+	if err != nil {
+		respondError(c, err, "Failed to update patient")
+		return
 	}
-	if req.DOB != "" {
-		patient.DOB = req.DOB
+
+	c.Header("ETag", patientETag(patient))
+	c.JSON(http.StatusOK, gin.H{"message": "Patient updated successfully", "patient": view.Patient(patient)})
+}
+
+// ReplaceEmergencyContacts handles replacing all emergency contacts of a patient
+// (Receptionist role). Requires If-Match with the patient's ETag.
+func (ctrl *PatientController) ReplaceEmergencyContacts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
 	}
-	if req.Gender != "" {
-		patient.Gender = req.Gender
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
 	}
-	if req.Contact != "" {
-		patient.Contact = req.Contact
+
+	var req struct {
+		EmergencyContacts []EmergencyContactRequest `json:"emergency_contacts" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
 	}
-	if req.Address != "" {
-		patient.Address = req.Address
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
 	}
 
-	// Doctor-specific updates (can also be done by receptionist in this simplified version if they have full update access)
-	if req.DoctorNotes != "" {
-		patient.DoctorNotes = req.DoctorNotes
+	var before, patient *models.Patient
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		if before, patient, err = tx.Patients.ReplaceEmergencyContacts(uint(id), version, emergencyContacts(req.EmergencyContacts)); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditUpdate, patient.ID, before, patient)
+	})
+	if errors.Is(err, services.ErrPatientVersionConflict) {
+		ctrl.respondVersionConflict(c, uint(id), view)
+		return
 	}
-	if req.Status != "" {
-		patient.Status = req.Status
+	if err != nil {
+		respondError(c, err, "Failed to update emergency contacts")
+		return
 	}
 
+	c.Header("ETag", patientETag(patient))
+	c.JSON(http.StatusOK, gin.H{"message": "Emergency contacts updated successfully", "patient": view.Patient(patient)})
+}
 
-	if err := ctrl.PatientService.UpdatePatient(patient); err != nil {
-		log.Printf("Failed to update patient ID %d: %v", id, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update patient"})
+// ChangeStatus handles moving a patient through the status workflow. Whether a transition
+// is allowed depends on the current status and the caller's permissions; some transitions
+// need a reason or a discharge summary.
+func (ctrl *PatientController) ChangeStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Status           string `json:"status" binding:"required"`
+		Reason           string `json:"reason"`
+		DischargeSummary string `json:"discharge_summary"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	var before, patient *models.Patient
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		if before, patient, err = tx.Patients.ChangePatientStatus(uint(id), version, statusChange(c, req.Status, req.Reason, req.DischargeSummary)); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditUpdate, patient.ID, before, patient)
+	})
+	if errors.Is(err, services.ErrPatientVersionConflict) {
+		ctrl.respondVersionConflict(c, uint(id), view)
+		return
+	}
+	if err != nil {
+		respondError(c, err, "Failed to change patient status")
+		return
+	}
+
+	c.Header("ETag", patientETag(patient))
+	c.JSON(http.StatusOK, gin.H{"message": "Patient status changed successfully", "patient": view.Patient(patient)})
+}
+
+// GetStatusHistory handles listing a patient's status changes together with the statuses
+// the caller may move the patient to next. Discharge summaries are only shown to clinicians.
+func (ctrl *PatientController) GetStatusHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+	if view.Access("status") == models.FieldHidden {
+		respondProblem(c, http.StatusForbidden, "permission_denied", "Your role may not see patient status")
+		return
+	}
+
+	patient, history, err := ctrl.PatientService.StatusHistory(uint(id))
+	if err != nil {
+		respondError(c, err, "Failed to load status history")
+		return
+	}
+	if !hasPermission(c, models.PermPatientClinical) {
+		for i := range history {
+			history[i].DischargeSummary = ""
+		}
+	}
+	if !recordReads(c, ctrl.AuditService, patient.ID) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Patient updated successfully", "patient": patient})
+	c.JSON(http.StatusOK, gin.H{
+		"status":      patient.Status,
+		"transitions": services.AllowedPatientTransitions(patient.Status, c.GetStringSlice("permissions")),
+		"history":     history,
+	})
 }
 
+// statusChange builds a status change request made by the caller
+func statusChange(c *gin.Context, status, reason, dischargeSummary string) services.StatusChange {
+	return services.StatusChange{
+		Status:           status,
+		Reason:           reason,
+		DischargeSummary: dischargeSummary,
+		ChangedBy:        c.GetString("username"),
+		Permissions:      c.GetStringSlice("permissions"),
+	}
+}
 
-// UpdatePatientDoctorNotes handles updating only doctor_notes and status (Doctor role)
+
+// UpdatePatientDoctorNotes handles updating only doctor_notes and status (Doctor role).
+// A status other than the current one must be an allowed workflow transition.
 func (ctrl *PatientController) UpdatePatientDoctorNotes(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
 
 	var req struct {
-		DoctorNotes string `json:"doctor_notes"`
-		Status      string `json:"status"`
+		DoctorNotes      string `json:"doctor_notes"`
+		Status           string `json:"status"`
+		Reason           string `json:"reason"`
+		DischargeSummary string `json:"discharge_summary"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	if req.DoctorNotes == "" && req.Status == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Either doctor_notes or status is required"})
+		respondProblem(c, http.StatusBadRequest, "invalid_body", "Either doctor_notes or status is required")
 		return
 	}
 
-	err = ctrl.PatientService.UpdatePatientDoctorNotes(uint(id), req.DoctorNotes, req.Status)
-	if err != nil {
-		if err.Error() == "patient not found" { // Custom error message from service
-			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
-			return
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	var before, after *models.Patient
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		if before, after, err = tx.Patients.UpdatePatientDoctorNotes(uint(id), version, req.DoctorNotes, statusChange(c, req.Status, req.Reason, req.DischargeSummary)); err != nil {
+			return err
 		}
-		log.Printf("Failed to update doctor notes for patient ID %d: %v", id, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update doctor notes"})
+		return tx.Audit.Record(auditActor(c), models.AuditUpdate, after.ID, before, after)
+	})
+	if errors.Is(err, services.ErrPatientVersionConflict) {
+		ctrl.respondVersionConflict(c, uint(id), view)
+		return
+	}
+	if err != nil {
+		respondError(c, err, "Failed to update doctor notes")
 		return
 	}
 
+	c.Header("ETag", patientETag(after))
 	c.JSON(http.StatusOK, gin.H{"message": "Doctor notes and status updated successfully"})
 }
 
@@ -221,19 +682,117 @@ func (ctrl *PatientController) DeletePatient(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
 		return
 	}
 
-	if err := ctrl.PatientService.DeletePatient(uint(id)); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
-			return
+	// The record kept in the audit entry is read in the same transaction as the deletion
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) error {
+		before, err := tx.Patients.GetPatientByID(uint(id))
+		if err != nil {
+			return err
+		}
+		if err := tx.Patients.DeletePatient(uint(id)); err != nil {
+			return err
 		}
-		log.Printf("Failed to delete patient ID %d: %v", id, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete patient"})
+		return tx.Audit.Record(auditActor(c), models.AuditDelete, before.ID, before, nil)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = services.ErrPatientNotFound
+	}
+	if err != nil {
+		respondError(c, err, "Failed to delete patient")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Patient deleted successfully"})
 }
 
+// ListDeletedPatients handles listing soft-deleted patients page by page (Admin).
+// Query parameters: page and page_size.
+func (ctrl *PatientController) ListDeletedPatients(c *gin.Context) {
+	page, err := intQuery(c, "page", 1)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	pageSize, err := intQuery(c, "page_size", services.DefaultPatientPageSize)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	result, err := ctrl.PatientService.ListDeletedPatients(page, pageSize)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve deleted patients")
+		return
+	}
+	ids := make([]uint, 0, len(result.Patients))
+	for _, patient := range result.Patients {
+		ids = append(ids, patient.ID)
+	}
+	if !recordReads(c, ctrl.AuditService, ids...) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"patients":    view.Patients(result.Patients),
+		"total":       result.Total,
+		"page":        result.Page,
+		"page_size":   result.PageSize,
+		"total_pages": result.TotalPages(),
+	})
+}
+
+// RestorePatient handles undoing the deletion of a patient (Admin)
+func (ctrl *PatientController) RestorePatient(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	var patient *models.Patient
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		if patient, err = tx.Patients.RestorePatient(uint(id)); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditRestore, patient.ID, nil, nil)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to restore patient")
+		return
+	}
+
+	c.Header("ETag", patientETag(patient))
+	c.JSON(http.StatusOK, gin.H{"message": "Patient restored successfully", "patient": view.Patient(patient)})
+}
+
+// PurgePatient handles permanently removing a deleted patient and their records (Admin).
+// The audit log keeps its entries about the patient.
+func (ctrl *PatientController) PurgePatient(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) error {
+		if err := tx.Patients.PurgePatient(uint(id)); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditPurge, uint(id), nil, nil)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to purge patient")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Patient purged permanently"})
+}
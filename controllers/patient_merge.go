@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckDuplicatesRequest defines the request body for looking up possible duplicates before registering a patient
+type CheckDuplicatesRequest struct {
+	FirstName string `json:"first_name" binding:"required"`
+	LastName  string `json:"last_name" binding:"required"`
+	DOB       string `json:"dob"`
+	Phone     string `json:"phone"`
+	Email     string `json:"email"`
+}
+
+// CheckDuplicates handles looking up patients that may be the same person as a patient
+// about to be registered (Receptionist role)
+func (ctrl *PatientController) CheckDuplicates(c *gin.Context) {
+	var req CheckDuplicatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	dob, err := services.ParsePatientDOB(strings.TrimSpace(req.DOB))
+	if err != nil {
+		respondError(c, services.FieldErrors{"dob": err.Error()}, "Failed to look for duplicate patients")
+		return
+	}
+	candidate := &models.Patient{FirstName: req.FirstName, LastName: req.LastName, DOB: dob, Phone: req.Phone, Email: req.Email}
+	ctrl.respondDuplicates(c, candidate)
+}
+
+// GetPatientDuplicates handles listing patients that may be the same person as an existing patient
+func (ctrl *PatientController) GetPatientDuplicates(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+	patient, err := ctrl.PatientService.GetPatientByID(uint(id))
+	if err != nil {
+		respondProblem(c, http.StatusNotFound, services.ErrPatientNotFound.Code, "Patient not found")
+		return
+	}
+	ctrl.respondDuplicates(c, patient)
+}
+
+// respondDuplicates writes the possible duplicates of candidate
+func (ctrl *PatientController) respondDuplicates(c *gin.Context, candidate *models.Patient) {
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+	matches, err := ctrl.PatientService.FindDuplicates(candidate)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to look for duplicate patients")
+		return
+	}
+	response, ok := ctrl.duplicatesResponse(c, view, matches)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"duplicates": response})
+}
+
+// duplicatesResponse audits the matched patients as read and renders them for the caller
+func (ctrl *PatientController) duplicatesResponse(c *gin.Context, view *services.PatientView, matches []services.DuplicateMatch) ([]gin.H, bool) {
+	ids := make([]uint, 0, len(matches))
+	response := make([]gin.H, 0, len(matches))
+	for i := range matches {
+		ids = append(ids, matches[i].Patient.ID)
+		response = append(response, gin.H{
+			"patient": view.Patient(&matches[i].Patient),
+			"score":   matches[i].Score,
+			"reasons": matches[i].Reasons,
+		})
+	}
+	if !recordReads(c, ctrl.AuditService, ids...) {
+		return nil, false
+	}
+	return response, true
+}
+
+// MergePatientsRequest defines the request body for merging a duplicate into a patient
+type MergePatientsRequest struct {
+	SourceID  uint     `json:"source_id" binding:"required"`
+	UseSource []string `json:"use_source"` // fields to take from the source even where the target has a value
+}
+
+// MergePatients handles merging the duplicate source_id into the patient in the URL (Admin).
+// Requires If-Match with the target patient's ETag.
+func (ctrl *PatientController) MergePatients(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient ID")
+		return
+	}
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+	var req MergePatientsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	var result *services.MergeResult
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		result, err = tx.Patients.MergePatients(uint(id), version, req.SourceID, services.MergeOptions{
+			UseSource: req.UseSource,
+			MergedBy:  c.GetString("username"),
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.Audit.Record(auditActor(c), models.AuditMerge, result.Target.ID, result.TargetBefore, result.Target); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditMerge, result.Source.ID, result.Source, nil)
+	})
+	if errors.Is(err, services.ErrPatientVersionConflict) {
+		ctrl.respondVersionConflict(c, uint(id), view)
+		return
+	}
+	if err != nil {
+		respondError(c, err, "Failed to merge patients")
+		return
+	}
+
+	c.Header("ETag", patientETag(result.Target))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Patients merged successfully",
+		"merge":   view.Merge(result.Merge),
+		"patient": view.Patient(result.Target),
+	})
+}
+
+// UnmergePatients handles undoing a merge, restoring the source patient (Admin)
+func (ctrl *PatientController) UnmergePatients(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid merge ID")
+		return
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	var result *services.MergeResult
+	err = ctrl.UnitOfWork.Do(func(tx *services.Services) (err error) {
+		if result, err = tx.Patients.UnmergePatients(uint(id), c.GetString("username")); err != nil {
+			return err
+		}
+		if err := tx.Audit.Record(auditActor(c), models.AuditUnmerge, result.Target.ID, result.TargetBefore, result.Target); err != nil {
+			return err
+		}
+		return tx.Audit.Record(auditActor(c), models.AuditUnmerge, result.Source.ID, nil, result.Source)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to undo merge")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Merge undone successfully",
+		"merge":   view.Merge(result.Merge),
+		"target":  view.Patient(result.Target),
+		"source":  view.Patient(result.Source),
+	})
+}
+
+// ListMerges handles listing patient merges, newest first (Admin). Query parameter: patient_id.
+func (ctrl *PatientController) ListMerges(c *gin.Context) {
+	var patientID uint64
+	if value := c.Query("patient_id"); value != "" {
+		var err error
+		if patientID, err = strconv.ParseUint(value, 10, 64); err != nil {
+			respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid patient_id")
+			return
+		}
+	}
+	view, ok := patientView(c, ctrl.FieldService)
+	if !ok {
+		return
+	}
+
+	merges, err := ctrl.PatientService.ListMerges(uint(patientID))
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to list merges")
+		return
+	}
+	response := make([]map[string]interface{}, 0, len(merges))
+	for i := range merges {
+		response = append(response, view.Merge(&merges[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"merges": response})
+}
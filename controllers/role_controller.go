@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleController handles managing roles and their permissions (user:admin permission)
+type RoleController struct {
+	RoleService  services.RoleService
+	FieldService services.FieldVisibilityService
+}
+
+// NewRoleController creates a new RoleController instance
+func NewRoleController(roleSvc services.RoleService, fieldSvc services.FieldVisibilityService) *RoleController {
+	return &RoleController{
+		RoleService:  roleSvc,
+		FieldService: fieldSvc,
+	}
+}
+
+// RoleRequest defines the request body for creating and updating a role
+type RoleRequest struct {
+	Name        string   `json:"name"` // only used when creating
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// ListPermissions handles listing every permission a role can be given
+func (ctrl *RoleController) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"permissions": models.Permissions})
+}
+
+// ListRoles handles listing every role with its permissions
+func (ctrl *RoleController) ListRoles(c *gin.Context) {
+	roles, err := ctrl.RoleService.ListRoles()
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve roles")
+		return
+	}
+
+	result := make([]gin.H, 0, len(roles))
+	for i := range roles {
+		result = append(result, roleResponse(&roles[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": result})
+}
+
+// GetRole handles reading a single role
+func (ctrl *RoleController) GetRole(c *gin.Context) {
+	role, err := ctrl.RoleService.GetRole(c.Param("name"))
+	if err != nil {
+		respondError(c, err, "Failed to retrieve role")
+		return
+	}
+	c.JSON(http.StatusOK, roleResponse(role))
+}
+
+// CreateRole handles adding a custom role, e.g. nurse or lab_technician
+func (ctrl *RoleController) CreateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	role, err := ctrl.RoleService.CreateRole(req.Name, req.Description, req.Permissions)
+	if err != nil {
+		respondError(c, err, "Failed to create role")
+		return
+	}
+	c.JSON(http.StatusCreated, roleResponse(role))
+}
+
+// UpdateRole handles replacing a role's description and permissions
+func (ctrl *RoleController) UpdateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	role, err := ctrl.RoleService.UpdateRole(c.Param("name"), req.Description, req.Permissions)
+	if err != nil {
+		respondError(c, err, "Failed to update role")
+		return
+	}
+	c.JSON(http.StatusOK, roleResponse(role))
+}
+
+// DeleteRole handles removing a custom role no user has
+func (ctrl *RoleController) DeleteRole(c *gin.Context) {
+	if err := ctrl.RoleService.DeleteRole(c.Param("name")); err != nil {
+		respondError(c, err, "Failed to delete role")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// GetPatientFields handles reading how each patient field is shown to a role
+func (ctrl *RoleController) GetPatientFields(c *gin.Context) {
+	role, err := ctrl.RoleService.GetRole(c.Param("name"))
+	if err != nil {
+		respondError(c, err, "Failed to retrieve role")
+		return
+	}
+	fields, err := ctrl.FieldService.Rules(role.Name)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve field visibility")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"role": role.Name, "fields": fields})
+}
+
+// SetPatientFields handles changing how patient fields are shown to a role.
+// Body: {"fields": {"doctor_notes": "hidden", "contact": "masked"}}; unlisted fields keep their setting.
+func (ctrl *RoleController) SetPatientFields(c *gin.Context) {
+	var req struct {
+		Fields map[string]string `json:"fields" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	name := c.Param("name")
+	if err := ctrl.FieldService.SetRules(name, req.Fields); err != nil {
+		if errors.Is(err, services.ErrInvalidRole) {
+			respondProblem(c, http.StatusNotFound, services.ErrRoleNotFound.Code, services.ErrRoleNotFound.Error())
+			return
+		}
+		respondError(c, err, "Failed to update field visibility")
+		return
+	}
+	fields, err := ctrl.FieldService.Rules(name)
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve field visibility")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"role": name, "fields": fields})
+}
+
+// roleResponse builds the JSON form of a role
+func roleResponse(role *models.Role) gin.H {
+	return gin.H{
+		"name":        role.Name,
+		"description": role.Description,
+		"built_in":    role.BuiltIn,
+		"permissions": role.PermissionNames(),
+	}
+}
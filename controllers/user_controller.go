@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"log"
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserController handles user management requests (Admin role)
+type UserController struct {
+	UserService  services.UserService
+	AuthService  services.AuthService
+	TokenService services.TokenService
+}
+
+// NewUserController creates a new UserController instance
+func NewUserController(userSvc services.UserService, authSvc services.AuthService, tokenSvc services.TokenService) *UserController {
+	return &UserController{
+		UserService:  userSvc,
+		AuthService:  authSvc,
+		TokenService: tokenSvc,
+	}
+}
+
+// RegisterUserRequest defines the request body for user registration
+type RegisterUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"` // name of a built-in or custom role
+}
+
+// RegisterUser handles new user registration
+func (ctrl *UserController) RegisterUser(c *gin.Context) {
+	var req RegisterUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user := &models.User{
+		Username: req.Username,
+		Password: req.Password, // Password will be hashed in service layer
+		Role:     req.Role,
+	}
+
+	if err := ctrl.UserService.RegisterUser(user); err != nil {
+		respondError(c, err, "Failed to register user")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "user": user})
+}
+
+// ListUsers handles listing every user account
+func (ctrl *UserController) ListUsers(c *gin.Context) {
+	users, err := ctrl.UserService.ListUsers()
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve users")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+// GetUser handles reading a single user account
+func (ctrl *UserController) GetUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := ctrl.UserService.GetUserByID(id)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve user")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// DisableUser handles disabling an account and ending its sessions
+func (ctrl *UserController) DisableUser(c *gin.Context) {
+	ctrl.setDisabled(c, true)
+}
+
+// EnableUser handles re-enabling a disabled account
+func (ctrl *UserController) EnableUser(c *gin.Context) {
+	ctrl.setDisabled(c, false)
+}
+
+// setDisabled updates the disabled flag of the user in the :id path parameter
+func (ctrl *UserController) setDisabled(c *gin.Context, disabled bool) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := ctrl.UserService.SetUserDisabled(id, disabled)
+	if err != nil {
+		respondError(c, err, "Failed to update user")
+		return
+	}
+	if disabled {
+		ctrl.endSessions(user)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": user})
+}
+
+// ChangeRole handles giving a user a different role
+func (ctrl *UserController) ChangeRole(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, err := ctrl.UserService.ChangeUserRole(id, req.Role)
+	if err != nil {
+		respondError(c, err, "Failed to change role")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role changed successfully", "user": user})
+}
+
+// ResetPassword handles setting a new password for a user and ending their sessions
+func (ctrl *UserController) ResetPassword(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, err := ctrl.UserService.ResetPassword(id, req.Password)
+	if err != nil {
+		respondError(c, err, "Failed to reset password")
+		return
+	}
+	ctrl.endSessions(user)
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully", "user": user})
+}
+
+// DeleteUser handles deleting a user account
+func (ctrl *UserController) DeleteUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := ctrl.UserService.GetUserByID(id)
+	if err != nil {
+		respondError(c, err, "Failed to delete user")
+		return
+	}
+	if err := ctrl.UserService.DeleteUser(id); err != nil {
+		respondError(c, err, "Failed to delete user")
+		return
+	}
+	ctrl.endSessions(user)
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// UnlockUser handles clearing a user's failed logins and lockout
+func (ctrl *UserController) UnlockUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := ctrl.UserService.GetUserByID(id)
+	if err != nil {
+		respondError(c, err, "Failed to unlock user")
+		return
+	}
+	if err := ctrl.AuthService.UnlockUser(user.Username); err != nil {
+		respondError(c, err, "Failed to unlock user")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// ListLockouts handles listing the usernames and client IPs that are currently locked out
+func (ctrl *UserController) ListLockouts(c *gin.Context) {
+	lockouts, err := ctrl.AuthService.ListLockouts()
+	if err != nil {
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to retrieve lockouts")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lockouts": lockouts})
+}
+
+// UnlockIP handles clearing a client IP's failed logins and lockout
+func (ctrl *UserController) UnlockIP(c *gin.Context) {
+	var req struct {
+		IP string `json:"ip" binding:"required,ip"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := ctrl.AuthService.UnlockIP(req.IP); err != nil {
+		log.Printf("Failed to unlock IP %s: %v", req.IP, err)
+		respondProblem(c, http.StatusInternalServerError, "internal_error", "Failed to unlock IP")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "IP unlocked successfully"})
+}
+
+// endSessions revokes the user's refresh tokens. Their access tokens are already
+// rejected by AuthMiddleware once the account is disabled or deleted.
+func (ctrl *UserController) endSessions(user *models.User) {
+	if err := ctrl.TokenService.RevokeAllForUser(user.ID); err != nil {
+		log.Printf("Failed to revoke sessions of user ID %d: %v", user.ID, err)
+	}
+}
+
+// userIDParam parses the :id path parameter, writing a 400 response when it is invalid
+func userIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "invalid_id", "Invalid user ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
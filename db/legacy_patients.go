@@ -0,0 +1,371 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"medical_app/models"
+	"medical_app/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// legacyBatchSize is how many patients the legacy migration reads per database round trip
+const legacyBatchSize = 500
+
+// legacyDateLayouts are the date of birth formats the migration understands besides
+// dd/mm/yyyy and mm/dd/yyyy, which are only used when the day gives the order away
+var legacyDateLayouts = []string{
+	models.DateLayout, "2006/01/02", "2006.01.02", "20060102", time.RFC3339,
+	"2 Jan 2006", "2 January 2006", "Jan 2, 2006", "January 2, 2006",
+}
+
+// legacyGenders maps free text genders to models.Genders
+var legacyGenders = map[string]string{
+	"f": models.GenderFemale, "female": models.GenderFemale, "woman": models.GenderFemale,
+	"m": models.GenderMale, "male": models.GenderMale, "man": models.GenderMale,
+	"o": models.GenderOther, "other": models.GenderOther, "x": models.GenderOther, "diverse": models.GenderOther,
+	"non-binary": models.GenderOther, "nonbinary": models.GenderOther,
+	"u": models.GenderUnknown, "unknown": models.GenderUnknown, "": models.GenderUnknown,
+}
+
+var (
+	numericDate    = regexp.MustCompile(`^(\d{1,2})[/.-](\d{1,2})[/.-](\d{4})$`)
+	statePostcode  = regexp.MustCompile(`^(.*?)\s*([A-Za-z]{0,2}\d[A-Za-z0-9 -]*)$`)
+	containsDigits = regexp.MustCompile(`\d`)
+)
+
+// legacyPatient is a patient row with the free text columns
+type legacyPatient struct {
+	ID      uint
+	DOB     *string
+	Contact *string
+	Address *string
+}
+
+// unparsedValue is a free text value the migration could not parse. They are kept in the
+// patient_legacy_values table to be fixed by hand.
+type unparsedValue struct {
+	PatientID uint
+	Field     string
+	Value     string
+}
+
+// migrateLegacyPatients is migration 4, which moves patients of the first version, with
+// free text dob, contact and address columns, to the typed fields. The SQL migrations
+// around it add the typed columns and drop the free text ones.
+//
+// It parses the free text columns of every patient: the date of birth is parsed, the
+// contact becomes the phone number or email address and the address is split into its
+// parts. Values that cannot be parsed are kept in patient_legacy_values and logged. It also
+// moves statuses from before the workflow (e.g. "active") to registered, maps free text
+// genders to models.Genders and gives every patient a medical record number.
+func migrateLegacyPatients(tx *gorm.DB) error {
+	query := tx.Table("patients").Select("id", "dob", "contact", "address").
+		Where("COALESCE(dob, '') <> '' OR COALESCE(contact, '') <> '' OR COALESCE(address, '') <> ''")
+
+	migrated, kept := 0, 0
+	var batch []legacyPatient
+	err := query.FindInBatches(&batch, legacyBatchSize, func(batchTx *gorm.DB, _ int) error {
+		for _, row := range batch {
+			updates, unparsed := parseLegacyDemographics(row)
+			for field, value := range unparsed {
+				log.Printf("Patient ID %d: could not parse legacy %s, kept in patient_legacy_values", row.ID, field)
+				if err := tx.Table("patient_legacy_values").Create(&unparsedValue{PatientID: row.ID, Field: field, Value: value}).Error; err != nil {
+					return err
+				}
+			}
+			if len(unparsed) > 0 {
+				kept++
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := tx.Table("patients").Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			migrated++
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+	if migrated > 0 || kept > 0 {
+		log.Printf("Migrated legacy demographics of %d patients, %d with values left to fix by hand", migrated, kept)
+	}
+
+	result := tx.Table("patients").Where("status NOT IN ? OR status IS NULL", models.PatientStatuses).Update("status", models.PatientRegistered)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Moved %d patients with a legacy status to %s", result.RowsAffected, models.PatientRegistered)
+	}
+	if err := normalizeLegacyGenders(tx); err != nil {
+		return err
+	}
+	return assignMissingMRNs(tx)
+}
+
+// restoreLegacyPatients fills the free text columns from the typed fields, for rolling
+// back to the first version, and puts back the values that could not be parsed. Statuses,
+// genders and medical record numbers are left as they are. Of patients sharing a contact,
+// which the first version did not allow, only the first gets it.
+func restoreLegacyPatients(tx *gorm.DB) error {
+	var unparsed []unparsedValue
+	if err := tx.Table("patient_legacy_values").Find(&unparsed).Error; err != nil {
+		return err
+	}
+	restored := map[uint]map[string]interface{}{}
+	for _, value := range unparsed {
+		if restored[value.PatientID] == nil {
+			restored[value.PatientID] = map[string]interface{}{}
+		}
+		restored[value.PatientID][value.Field] = value.Value
+	}
+
+	type typedPatient struct {
+		ID             uint
+		BirthDate      *models.Date
+		Phone          string
+		Email          string
+		models.Address `gorm:"embedded;embeddedPrefix:address_"`
+	}
+	contacts := map[string]bool{}
+	var batch []typedPatient
+	err := tx.Table("patients").Order("id").FindInBatches(&batch, legacyBatchSize, func(batchTx *gorm.DB, _ int) error {
+		for _, row := range batch {
+			updates := restored[row.ID]
+			if updates == nil {
+				updates = map[string]interface{}{}
+			}
+			if _, ok := updates["dob"]; !ok && row.BirthDate != nil {
+				updates["dob"] = row.BirthDate.String()
+			}
+			if _, ok := updates["contact"]; !ok {
+				contact := row.Phone
+				if contact == "" {
+					contact = row.Email
+				}
+				if contact != "" && !contacts[contact] {
+					updates["contact"] = contact
+				}
+			}
+			if contact, ok := updates["contact"].(string); ok {
+				contacts[contact] = true
+			}
+			if _, ok := updates["address"]; !ok && !row.Address.IsZero() {
+				updates["address"] = formatLegacyAddress(row.Address)
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := tx.Table("patients").Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Exec("DELETE FROM patient_legacy_values").Error
+}
+
+// formatLegacyAddress joins the parts of an address as parseLegacyAddress splits them
+func formatLegacyAddress(address models.Address) string {
+	var parts []string
+	stateCode := strings.TrimSpace(address.State + " " + address.PostalCode)
+	for _, part := range []string{address.Line1, address.Line2, address.City, stateCode, address.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseLegacyDemographics returns the column updates for one legacy row and the legacy
+// values that could not be parsed, by column
+func parseLegacyDemographics(row legacyPatient) (map[string]interface{}, map[string]string) {
+	updates := map[string]interface{}{}
+	unparsed := map[string]string{}
+
+	if value := legacyValue(row.DOB); value != "" {
+		if dob, ok := parseLegacyDate(value); ok {
+			updates["birth_date"] = dob
+		} else {
+			unparsed["dob"] = value
+		}
+	}
+	if value := legacyValue(row.Contact); value != "" {
+		if email, err := utils.NormalizeEmail(value); err == nil && strings.Contains(value, "@") {
+			updates["email"] = email
+		} else if phone, err := utils.NormalizePhone(value); err == nil {
+			updates["phone"] = phone
+		} else {
+			unparsed["contact"] = value
+		}
+	}
+	if value := legacyValue(row.Address); value != "" {
+		address := parseLegacyAddress(value)
+		updates["address_line1"] = address.Line1
+		updates["address_line2"] = address.Line2
+		updates["address_city"] = address.City
+		updates["address_state"] = address.State
+		updates["address_postal_code"] = address.PostalCode
+		updates["address_country"] = address.Country
+	}
+	return updates, unparsed
+}
+
+func legacyValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return strings.TrimSpace(*value)
+}
+
+// parseLegacyDate parses a free text date of birth. Day-first and month-first numeric
+// dates are only accepted when they cannot be read the other way round.
+func parseLegacyDate(value string) (models.Date, bool) {
+	var parsed time.Time
+	found := false
+	for _, layout := range legacyDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			parsed, found = t, true
+			break
+		}
+	}
+	if !found {
+		match := numericDate.FindStringSubmatch(value)
+		if match == nil {
+			return models.Date{}, false
+		}
+		a, _ := strconv.Atoi(match[1])
+		b, _ := strconv.Atoi(match[2])
+		year, _ := strconv.Atoi(match[3])
+		day, month := a, b
+		switch {
+		case a == b:
+		case a > 12 && b <= 12:
+		case b > 12 && a <= 12:
+			day, month = b, a
+		default:
+			return models.Date{}, false
+		}
+		parsed = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if parsed.Day() != day || parsed.Month() != time.Month(month) {
+			return models.Date{}, false
+		}
+	}
+
+	dob := models.NewDate(parsed.Year(), parsed.Month(), parsed.Day())
+	if dob.CheckBirthDate() != nil {
+		return models.Date{}, false
+	}
+	return dob, true
+}
+
+// parseLegacyAddress splits a comma separated address such as "12 Main St, Apt 4,
+// Springfield, IL 62704, USA". Whatever cannot be told apart ends up in the street lines.
+func parseLegacyAddress(value string) models.Address {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return models.Address{}
+	}
+
+	var address models.Address
+	if len(parts) >= 3 && !containsDigits.MatchString(parts[len(parts)-1]) {
+		address.Country = parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) >= 2 {
+		if match := statePostcode.FindStringSubmatch(parts[len(parts)-1]); match != nil {
+			address.State, address.PostalCode = strings.TrimSpace(match[1]), strings.TrimSpace(match[2])
+			parts = parts[:len(parts)-1]
+		}
+	}
+	if len(parts) >= 2 {
+		address.City = parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+	address.Line1 = parts[0]
+	address.Line2 = strings.Join(parts[1:], ", ")
+	return address
+}
+
+// normalizeLegacyGenders maps free text genders to models.Genders; unrecognized values
+// become unknown
+func normalizeLegacyGenders(tx *gorm.DB) error {
+	var genders []string
+	if err := tx.Table("patients").Distinct().Where("gender NOT IN ? OR gender IS NULL", models.Genders).Pluck("COALESCE(gender, '')", &genders).Error; err != nil {
+		return err
+	}
+	for _, gender := range genders {
+		mapped, ok := legacyGenders[strings.ToLower(strings.TrimSpace(gender))]
+		if !ok {
+			log.Printf("Unrecognized patient gender %q, set to %s", gender, models.GenderUnknown)
+			mapped = models.GenderUnknown
+		}
+		query := tx.Table("patients").Where("gender = ?", gender)
+		if gender == "" {
+			query = tx.Table("patients").Where("gender = '' OR gender IS NULL")
+		}
+		if err := query.UpdateColumn("gender", mapped).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyMRNAttempts is how often a random medical record number is drawn for a patient
+// before the migration gives up
+const legacyMRNAttempts = 5
+
+// assignMissingMRNs gives patients registered before medical record numbers existed one
+func assignMissingMRNs(tx *gorm.DB) error {
+	var ids []uint
+	if err := tx.Table("patients").Where("mrn IS NULL OR mrn = ''").Order("id").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		mrn, err := unusedMRN(tx)
+		if err != nil {
+			return err
+		}
+		if err := tx.Table("patients").Where("id = ?", id).UpdateColumn("mrn", mrn).Error; err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		log.Printf("Assigned medical record numbers to %d patients", len(ids))
+	}
+	return nil
+}
+
+// unusedMRN draws random medical record numbers until it finds one no patient has
+func unusedMRN(tx *gorm.DB) (string, error) {
+	for attempt := 0; attempt < legacyMRNAttempts; attempt++ {
+		mrn, err := utils.RandomMRN()
+		if err != nil {
+			return "", err
+		}
+		var used int64
+		if err := tx.Table("patients").Where("mrn = ?", mrn).Count(&used).Error; err != nil {
+			return "", err
+		}
+		if used == 0 {
+			return mrn, nil
+		}
+	}
+	return "", errors.New("could not generate a unique medical record number")
+}
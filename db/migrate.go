@@ -0,0 +1,352 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles holds the SQL of every migration, one directory per dialect. A migration
+// is a pair of files NNNN_name.up.sql and NNNN_name.down.sql; versions start at 1 and have
+// no gaps. The down file may be left out for a migration that cannot be undone.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// goMigrations are the migrations written in Go, for changes SQL cannot express such as
+// parsing free text, by version. There are no SQL files for their versions; they run for
+// every dialect.
+var goMigrations = map[int]Migration{
+	4: {Version: 4, Name: "migrate_legacy_patients", UpFunc: migrateLegacyPatients, DownFunc: restoreLegacyPatients},
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var (
+	// ErrSchemaBehind is returned by CheckSchema when migrations are pending
+	ErrSchemaBehind = errors.New("database schema is behind this version of the application")
+	// ErrSchemaAhead is returned by CheckSchema when the database has migrations this
+	// version of the application does not know, i.e. it was migrated by a newer version
+	ErrSchemaAhead = errors.New("database schema is newer than this version of the application")
+	// ErrIrreversible is returned when rolling back a migration without a down file
+	ErrIrreversible = errors.New("migration cannot be rolled back")
+	// ErrUnknownSchema is returned for a database with tables but no schema version table
+	// whose tables are not those of the first migration, so its version cannot be told
+	ErrUnknownSchema = errors.New("database schema has no version and is not that of the first version")
+)
+
+// Migration is one numbered change of the schema, written in SQL or in Go
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string // empty when the migration cannot be rolled back
+
+	UpFunc   func(tx *gorm.DB) error
+	DownFunc func(tx *gorm.DB) error // nil when the migration cannot be rolled back
+}
+
+func (m Migration) reversible() bool {
+	return m.Down != "" || m.DownFunc != nil
+}
+
+// MigrationStatus is a migration with the time it was applied, nil while it is pending
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of the schema version table, one per applied migration
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// baselineTable is a table of the first migration. A database that has it but no schema
+// version table was set up by AutoMigrate before migrations existed.
+const baselineTable = "users"
+
+// laterColumn is a column the first migration does not have. A database without a schema
+// version table that has it was set up by AutoMigrate of a development version, whose
+// schema cannot be told.
+var laterColumn = [2]string{"patients", "mrn"}
+
+// Migrator applies and rolls back the migrations of a database's dialect
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+}
+
+// NewMigrator loads the migrations for the dialect of db ("postgres" or "sqlite")
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := LoadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// LoadMigrations reads the migrations of a dialect, ordered by version
+func LoadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for database dialect %q", dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s: name must look like 0001_name.up.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		sql, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(sql)
+		} else {
+			migration.Down = string(sql)
+		}
+	}
+
+	for version, migration := range goMigrations {
+		if byVersion[version] != nil {
+			return nil, fmt.Errorf("migration %d is both SQL files and Go code", version)
+		}
+		byVersion[version] = &migration
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if migration.Up == "" && migration.UpFunc == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// Latest is the version the migrations bring the schema to
+func (m *Migrator) Latest() int {
+	return len(m.Migrations)
+}
+
+// Version is the version of the schema, 0 for an empty database
+func (m *Migrator) Version() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// Status lists every migration with the time it was applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(m.Migrations))
+	for i, migration := range m.Migrations {
+		statuses[i] = MigrationStatus{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &row.AppliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// CheckSchema reports whether the schema is at the latest version. The server refuses to
+// start otherwise, rather than run against tables it does not expect.
+func (m *Migrator) CheckSchema() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	switch {
+	case version < m.Latest():
+		return fmt.Errorf("%w: at version %d, needs %d", ErrSchemaBehind, version, m.Latest())
+	case version > m.Latest():
+		return fmt.Errorf("%w: at version %d, knows up to %d", ErrSchemaAhead, version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies every pending migration and returns the ones applied
+func (m *Migrator) Up() ([]Migration, error) {
+	return m.To(m.Latest())
+}
+
+// Down rolls back the last steps migrations and returns the ones rolled back
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	return m.To(max(version-steps, 0))
+}
+
+// To migrates up or down to version and returns the migrations applied or rolled back,
+// in the order they ran. Each migration runs in its own transaction together with the
+// update of the version table, so a failed migration leaves the schema at the version
+// before it.
+func (m *Migrator) To(version int) ([]Migration, error) {
+	if version < 0 || version > m.Latest() {
+		return nil, fmt.Errorf("unknown schema version %d, the latest is %d", version, m.Latest())
+	}
+	if err := m.prepare(); err != nil {
+		return nil, err
+	}
+	current, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if current > m.Latest() {
+		return nil, fmt.Errorf("%w: at version %d, knows up to %d", ErrSchemaAhead, current, m.Latest())
+	}
+
+	var ran []Migration
+	for current < version {
+		migration := m.Migrations[current]
+		if err := m.apply(migration); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migration)
+		current++
+	}
+	for current > version {
+		migration := m.Migrations[current-1]
+		if err := m.rollback(migration); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migration)
+		current--
+	}
+	return ran, nil
+}
+
+func (m *Migrator) apply(migration Migration) error {
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := run(tx, migration.Up, migration.UpFunc); err != nil {
+			return err
+		}
+		// The version is the primary key, so of two processes applying the same
+		// migration at once the second fails here and is rolled back
+		return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) rollback(migration Migration) error {
+	if !migration.reversible() {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, ErrIrreversible)
+	}
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := run(tx, migration.Down, migration.DownFunc); err != nil {
+			return err
+		}
+		return tx.Delete(&schemaMigration{}, migration.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("rolling back migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// run executes the SQL or the Go code of one direction of a migration
+func run(tx *gorm.DB, sql string, fn func(tx *gorm.DB) error) error {
+	if fn != nil {
+		return fn(tx)
+	}
+	return tx.Exec(sql).Error
+}
+
+// applied returns the rows of the version table by version; none when it does not exist yet
+func (m *Migrator) applied() (map[int]schemaMigration, error) {
+	applied := map[int]schemaMigration{}
+	if !m.DB.Migrator().HasTable(&schemaMigration{}) {
+		if m.DB.Migrator().HasTable(baselineTable) {
+			// Set up by AutoMigrate: the schema of the first migration, not yet recorded
+			if err := checkBaseline(m.DB); err != nil {
+				return nil, err
+			}
+			applied[1] = schemaMigration{Version: 1, Name: m.Migrations[0].Name}
+		}
+		return applied, nil
+	}
+	var rows []schemaMigration
+	if err := m.DB.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// prepare creates the version table. A database set up by AutoMigrate before migrations
+// existed is recorded at version 1, whose schema it already has.
+func (m *Migrator) prepare() error {
+	if m.DB.Migrator().HasTable(&schemaMigration{}) {
+		return nil
+	}
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&schemaMigration{}); err != nil {
+			return err
+		}
+		if !tx.Migrator().HasTable(baselineTable) {
+			return nil
+		}
+		if err := checkBaseline(tx); err != nil {
+			return err
+		}
+		log.Printf("Recording the existing schema as migration 1_%s", m.Migrations[0].Name)
+		return tx.Create(&schemaMigration{Version: 1, Name: m.Migrations[0].Name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// checkBaseline refuses a database without a schema version table whose tables are not
+// those of the first migration
+func checkBaseline(db *gorm.DB) error {
+	if db.Migrator().HasColumn(laterColumn[0], laterColumn[1]) {
+		return fmt.Errorf("%w: it has %s.%s; start from a backup of the first version or migrate an empty database", ErrUnknownSchema, laterColumn[0], laterColumn[1])
+	}
+	return nil
+}
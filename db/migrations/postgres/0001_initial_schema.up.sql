@@ -0,0 +1,33 @@
+-- The schema of the first version, whose tables were created by AutoMigrate at startup.
+-- Databases set up that way are recorded at this version by the first migrate up.
+
+CREATE TABLE "users" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "username" text NOT NULL,
+    "password" text NOT NULL,
+    "role" text NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_users_username" UNIQUE ("username")
+);
+CREATE INDEX "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE "patients" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "first_name" text NOT NULL,
+    "last_name" text NOT NULL,
+    "dob" text,
+    "gender" text,
+    "contact" text,
+    "address" text,
+    "doctor_notes" text,
+    "status" text DEFAULT 'active',
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_patients_contact" UNIQUE ("contact")
+);
+CREATE INDEX "idx_patients_deleted_at" ON "patients" ("deleted_at");
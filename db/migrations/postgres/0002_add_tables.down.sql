@@ -0,0 +1,17 @@
+DROP TABLE "emergency_contacts";
+DROP TABLE "patient_merges";
+DROP TABLE "patient_status_changes";
+DROP TABLE "field_visibilities";
+DROP TABLE "role_permissions";
+DROP TABLE "roles";
+DROP TABLE "password_histories";
+DROP TABLE "mfa_policies";
+DROP TABLE "recovery_codes";
+DROP TABLE "login_throttles";
+DROP TABLE "revoked_tokens";
+DROP TABLE "refresh_tokens";
+DROP TABLE "audit_logs";
+DROP TABLE "encounter_notes";
+DROP TABLE "availability_exceptions";
+DROP TABLE "doctor_availabilities";
+DROP TABLE "appointments";
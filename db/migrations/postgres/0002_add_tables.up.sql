@@ -0,0 +1,224 @@
+-- Tables added since the first version
+
+CREATE TABLE "appointments" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "patient_id" bigint NOT NULL,
+    "doctor_id" bigint NOT NULL,
+    "start_time" timestamptz NOT NULL,
+    "end_time" timestamptz NOT NULL,
+    "type" text NOT NULL,
+    "state" text NOT NULL DEFAULT 'booked',
+    "reason" text,
+    "cancel_reason" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_appointments_patient" FOREIGN KEY ("patient_id") REFERENCES "patients"("id")
+);
+CREATE INDEX "idx_appointments_doctor_start" ON "appointments" ("doctor_id","start_time");
+CREATE INDEX "idx_appointments_patient_id" ON "appointments" ("patient_id");
+CREATE INDEX "idx_appointments_deleted_at" ON "appointments" ("deleted_at");
+
+CREATE TABLE "doctor_availabilities" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "doctor_id" bigint NOT NULL,
+    "weekday" bigint NOT NULL,
+    "start_time" text NOT NULL,
+    "end_time" text NOT NULL,
+    "slot_minutes" bigint NOT NULL DEFAULT 30,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_doctor_availabilities_doctor_id" ON "doctor_availabilities" ("doctor_id");
+CREATE INDEX "idx_doctor_availabilities_deleted_at" ON "doctor_availabilities" ("deleted_at");
+
+CREATE TABLE "availability_exceptions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "doctor_id" bigint NOT NULL,
+    "start_time" timestamptz NOT NULL,
+    "end_time" timestamptz NOT NULL,
+    "reason" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_availability_exceptions_doctor_id" ON "availability_exceptions" ("doctor_id");
+CREATE INDEX "idx_availability_exceptions_deleted_at" ON "availability_exceptions" ("deleted_at");
+
+CREATE TABLE "encounter_notes" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "patient_id" bigint NOT NULL,
+    "appointment_id" bigint,
+    "author_id" bigint NOT NULL,
+    "author_username" text NOT NULL,
+    "subjective" text,
+    "objective" text,
+    "assessment" text,
+    "plan" text,
+    "state" text NOT NULL DEFAULT 'draft',
+    "signed_at" timestamptz,
+    "amends_id" bigint,
+    "amendment_reason" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_encounter_notes_amends_id" ON "encounter_notes" ("amends_id");
+CREATE INDEX "idx_encounter_notes_appointment_id" ON "encounter_notes" ("appointment_id");
+CREATE INDEX "idx_encounter_notes_patient_id" ON "encounter_notes" ("patient_id");
+CREATE INDEX "idx_encounter_notes_deleted_at" ON "encounter_notes" ("deleted_at");
+
+CREATE TABLE "audit_logs" (
+    "id" bigserial,
+    "created_at" timestamptz NOT NULL,
+    "actor" text NOT NULL,
+    "role" text,
+    "ip" text,
+    "method" text,
+    "route" text,
+    "action" text NOT NULL,
+    "patient_id" bigint,
+    "changes" text,
+    "prev_hash" text NOT NULL,
+    "hash" text NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_audit_logs_hash" ON "audit_logs" ("hash");
+CREATE INDEX "idx_audit_logs_patient_id" ON "audit_logs" ("patient_id");
+CREATE INDEX "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX "idx_audit_logs_actor" ON "audit_logs" ("actor");
+CREATE INDEX "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+
+CREATE TABLE "refresh_tokens" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "family_id" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "revoked_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+CREATE INDEX "idx_refresh_tokens_family_id" ON "refresh_tokens" ("family_id");
+CREATE INDEX "idx_refresh_tokens_user_id" ON "refresh_tokens" ("user_id");
+CREATE INDEX "idx_refresh_tokens_deleted_at" ON "refresh_tokens" ("deleted_at");
+
+CREATE TABLE "revoked_tokens" (
+    "jti" text,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("jti")
+);
+CREATE INDEX "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
+
+CREATE TABLE "login_throttles" (
+    "key" text,
+    "failures" bigint NOT NULL DEFAULT 0,
+    "last_failure" timestamptz,
+    "locked_until" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE "recovery_codes" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+CREATE INDEX "idx_recovery_codes_deleted_at" ON "recovery_codes" ("deleted_at");
+
+CREATE TABLE "mfa_policies" (
+    "role" text,
+    "required" boolean NOT NULL DEFAULT false,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("role")
+);
+
+CREATE TABLE "password_histories" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "hash" text NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_password_histories_user_id" ON "password_histories" ("user_id");
+
+CREATE TABLE "roles" (
+    "name" text,
+    "description" text,
+    "built_in" boolean NOT NULL DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("name")
+);
+
+CREATE TABLE "role_permissions" (
+    "role_name" text,
+    "permission" text,
+    PRIMARY KEY ("role_name","permission"),
+    CONSTRAINT "fk_roles_permissions" FOREIGN KEY ("role_name") REFERENCES "roles"("name") ON DELETE CASCADE
+);
+
+CREATE TABLE "field_visibilities" (
+    "role" text,
+    "field" text,
+    "access" text NOT NULL,
+    PRIMARY KEY ("role","field")
+);
+
+CREATE TABLE "patient_status_changes" (
+    "id" bigserial,
+    "patient_id" bigint NOT NULL,
+    "from_status" text NOT NULL,
+    "to_status" text NOT NULL,
+    "reason" text,
+    "discharge_summary" text,
+    "changed_by" text NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_patient_status_changes_created_at" ON "patient_status_changes" ("created_at");
+CREATE INDEX "idx_patient_status_changes_patient_id" ON "patient_status_changes" ("patient_id");
+
+CREATE TABLE "patient_merges" (
+    "id" bigserial,
+    "target_id" bigint NOT NULL,
+    "source_id" bigint NOT NULL,
+    "merged_by" text NOT NULL,
+    "created_at" timestamptz,
+    "field_changes" text,
+    "moved" text,
+    "unmerged_at" timestamptz,
+    "unmerged_by" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_patient_merges_created_at" ON "patient_merges" ("created_at");
+CREATE INDEX "idx_patient_merges_source_id" ON "patient_merges" ("source_id");
+CREATE INDEX "idx_patient_merges_target_id" ON "patient_merges" ("target_id");
+
+CREATE TABLE "emergency_contacts" (
+    "id" bigserial,
+    "patient_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "relationship" text,
+    "phone" text,
+    "email" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_patients_emergency_contacts" FOREIGN KEY ("patient_id") REFERENCES "patients"("id")
+);
+CREATE INDEX "idx_emergency_contacts_patient_id" ON "emergency_contacts" ("patient_id");
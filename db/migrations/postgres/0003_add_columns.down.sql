@@ -0,0 +1,25 @@
+DROP TABLE "patient_legacy_values";
+
+DROP INDEX "idx_patients_mrn";
+ALTER TABLE "patients"
+    DROP COLUMN "mrn",
+    DROP COLUMN "birth_date",
+    DROP COLUMN "phone",
+    DROP COLUMN "secondary_phone",
+    DROP COLUMN "email",
+    DROP COLUMN "address_line1",
+    DROP COLUMN "address_line2",
+    DROP COLUMN "address_city",
+    DROP COLUMN "address_state",
+    DROP COLUMN "address_postal_code",
+    DROP COLUMN "address_country",
+    DROP COLUMN "version",
+    ALTER COLUMN "gender" DROP DEFAULT,
+    ALTER COLUMN "status" SET DEFAULT 'active';
+
+ALTER TABLE "users"
+    DROP COLUMN "disabled",
+    DROP COLUMN "must_change_password",
+    DROP COLUMN "mfa_enabled",
+    DROP COLUMN "mfa_secret",
+    DROP COLUMN "mfa_last_step";
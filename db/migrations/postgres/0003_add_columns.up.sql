@@ -0,0 +1,35 @@
+-- Columns added to users and patients since the first version. The free text dob,
+-- contact and address columns of patients are moved to the typed ones by the next
+-- migration and dropped by the one after.
+
+ALTER TABLE "users"
+    ADD COLUMN "disabled" boolean NOT NULL DEFAULT false,
+    ADD COLUMN "must_change_password" boolean NOT NULL DEFAULT false,
+    ADD COLUMN "mfa_enabled" boolean NOT NULL DEFAULT false,
+    ADD COLUMN "mfa_secret" text,
+    ADD COLUMN "mfa_last_step" bigint;
+
+ALTER TABLE "patients"
+    ADD COLUMN "mrn" varchar(16),
+    ADD COLUMN "birth_date" date,
+    ADD COLUMN "phone" text,
+    ADD COLUMN "secondary_phone" text,
+    ADD COLUMN "email" text,
+    ADD COLUMN "address_line1" text,
+    ADD COLUMN "address_line2" text,
+    ADD COLUMN "address_city" text,
+    ADD COLUMN "address_state" text,
+    ADD COLUMN "address_postal_code" text,
+    ADD COLUMN "address_country" text,
+    ADD COLUMN "version" bigint NOT NULL DEFAULT 1,
+    ALTER COLUMN "gender" SET DEFAULT 'unknown',
+    ALTER COLUMN "status" SET DEFAULT 'registered';
+CREATE UNIQUE INDEX "idx_patients_mrn" ON "patients" ("mrn") WHERE mrn <> '';
+
+-- Legacy values the next migration cannot parse, kept to be fixed by hand
+CREATE TABLE "patient_legacy_values" (
+    "patient_id" bigint NOT NULL,
+    "field" text NOT NULL,
+    "value" text NOT NULL,
+    PRIMARY KEY ("patient_id","field")
+);
@@ -0,0 +1,5 @@
+ALTER TABLE "patients"
+    ADD COLUMN "dob" text,
+    ADD COLUMN "contact" text,
+    ADD COLUMN "address" text,
+    ADD CONSTRAINT "uni_patients_contact" UNIQUE ("contact");
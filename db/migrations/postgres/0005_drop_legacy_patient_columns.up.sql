@@ -0,0 +1,6 @@
+-- The free text columns of patients, moved to the typed ones by the previous migration
+
+ALTER TABLE "patients"
+    DROP COLUMN "dob",
+    DROP COLUMN "contact",
+    DROP COLUMN "address";
@@ -0,0 +1,3 @@
+-- The extension is left installed, other database objects may use it
+
+DROP INDEX "idx_patients_search_text";
@@ -0,0 +1,5 @@
+-- Trigram index for the LIKE filter of patient search (services.patientSearchText). The
+-- expression has to stay identical to the one in the query for the index to be used.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX "idx_patients_search_text" ON "patients" USING gin ((lower(coalesce(first_name, '') || ' ' || coalesce(last_name, '') || ' ' || coalesce(mrn, '') || ' ' || coalesce(phone, '') || ' ' || coalesce(secondary_phone, '') || ' ' || coalesce(email, '') || ' ' || coalesce(address_line1, '') || ' ' || coalesce(address_line2, '') || ' ' || coalesce(address_city, '') || ' ' || coalesce(address_state, '') || ' ' || coalesce(address_postal_code, '') || ' ' || coalesce(address_country, ''))) gin_trgm_ops);
@@ -0,0 +1,31 @@
+-- The schema of the first version, whose tables were created by AutoMigrate at startup.
+-- Databases set up that way are recorded at this version by the first migrate up.
+
+CREATE TABLE "users" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "username" text NOT NULL,
+    "password" text NOT NULL,
+    "role" text NOT NULL,
+    CONSTRAINT "uni_users_username" UNIQUE ("username")
+);
+CREATE INDEX "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE "patients" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "first_name" text NOT NULL,
+    "last_name" text NOT NULL,
+    "dob" text,
+    "gender" text,
+    "contact" text,
+    "address" text,
+    "doctor_notes" text,
+    "status" text DEFAULT 'active',
+    CONSTRAINT "uni_patients_contact" UNIQUE ("contact")
+);
+CREATE INDEX "idx_patients_deleted_at" ON "patients" ("deleted_at");
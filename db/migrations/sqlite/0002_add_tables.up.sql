@@ -0,0 +1,213 @@
+-- Tables added since the first version
+
+CREATE TABLE "appointments" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "patient_id" integer NOT NULL,
+    "doctor_id" integer NOT NULL,
+    "start_time" datetime NOT NULL,
+    "end_time" datetime NOT NULL,
+    "type" text NOT NULL,
+    "state" text NOT NULL DEFAULT 'booked',
+    "reason" text,
+    "cancel_reason" text,
+    CONSTRAINT "fk_appointments_patient" FOREIGN KEY ("patient_id") REFERENCES "patients"("id")
+);
+CREATE INDEX "idx_appointments_doctor_start" ON "appointments" ("doctor_id","start_time");
+CREATE INDEX "idx_appointments_patient_id" ON "appointments" ("patient_id");
+CREATE INDEX "idx_appointments_deleted_at" ON "appointments" ("deleted_at");
+
+CREATE TABLE "doctor_availabilities" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "doctor_id" integer NOT NULL,
+    "weekday" integer NOT NULL,
+    "start_time" text NOT NULL,
+    "end_time" text NOT NULL,
+    "slot_minutes" integer NOT NULL DEFAULT 30
+);
+CREATE INDEX "idx_doctor_availabilities_doctor_id" ON "doctor_availabilities" ("doctor_id");
+CREATE INDEX "idx_doctor_availabilities_deleted_at" ON "doctor_availabilities" ("deleted_at");
+
+CREATE TABLE "availability_exceptions" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "doctor_id" integer NOT NULL,
+    "start_time" datetime NOT NULL,
+    "end_time" datetime NOT NULL,
+    "reason" text
+);
+CREATE INDEX "idx_availability_exceptions_doctor_id" ON "availability_exceptions" ("doctor_id");
+CREATE INDEX "idx_availability_exceptions_deleted_at" ON "availability_exceptions" ("deleted_at");
+
+CREATE TABLE "encounter_notes" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "patient_id" integer NOT NULL,
+    "appointment_id" integer,
+    "author_id" integer NOT NULL,
+    "author_username" text NOT NULL,
+    "subjective" text,
+    "objective" text,
+    "assessment" text,
+    "plan" text,
+    "state" text NOT NULL DEFAULT 'draft',
+    "signed_at" datetime,
+    "amends_id" integer,
+    "amendment_reason" text
+);
+CREATE INDEX "idx_encounter_notes_amends_id" ON "encounter_notes" ("amends_id");
+CREATE INDEX "idx_encounter_notes_appointment_id" ON "encounter_notes" ("appointment_id");
+CREATE INDEX "idx_encounter_notes_patient_id" ON "encounter_notes" ("patient_id");
+CREATE INDEX "idx_encounter_notes_deleted_at" ON "encounter_notes" ("deleted_at");
+
+CREATE TABLE "audit_logs" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime NOT NULL,
+    "actor" text NOT NULL,
+    "role" text,
+    "ip" text,
+    "method" text,
+    "route" text,
+    "action" text NOT NULL,
+    "patient_id" integer,
+    "changes" text,
+    "prev_hash" text NOT NULL,
+    "hash" text NOT NULL
+);
+CREATE UNIQUE INDEX "idx_audit_logs_hash" ON "audit_logs" ("hash");
+CREATE INDEX "idx_audit_logs_patient_id" ON "audit_logs" ("patient_id");
+CREATE INDEX "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX "idx_audit_logs_actor" ON "audit_logs" ("actor");
+CREATE INDEX "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+
+CREATE TABLE "refresh_tokens" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer NOT NULL,
+    "family_id" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "expires_at" datetime NOT NULL,
+    "used_at" datetime,
+    "revoked_at" datetime
+);
+CREATE UNIQUE INDEX "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+CREATE INDEX "idx_refresh_tokens_family_id" ON "refresh_tokens" ("family_id");
+CREATE INDEX "idx_refresh_tokens_user_id" ON "refresh_tokens" ("user_id");
+CREATE INDEX "idx_refresh_tokens_deleted_at" ON "refresh_tokens" ("deleted_at");
+
+CREATE TABLE "revoked_tokens" (
+    "jti" text,
+    "expires_at" datetime NOT NULL,
+    "created_at" datetime,
+    PRIMARY KEY ("jti")
+);
+CREATE INDEX "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
+
+CREATE TABLE "login_throttles" (
+    "key" text,
+    "failures" integer NOT NULL DEFAULT 0,
+    "last_failure" datetime,
+    "locked_until" datetime,
+    "updated_at" datetime,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE "recovery_codes" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" datetime
+);
+CREATE INDEX "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+CREATE INDEX "idx_recovery_codes_deleted_at" ON "recovery_codes" ("deleted_at");
+
+CREATE TABLE "mfa_policies" (
+    "role" text,
+    "required" numeric NOT NULL DEFAULT false,
+    "updated_at" datetime,
+    PRIMARY KEY ("role")
+);
+
+CREATE TABLE "password_histories" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "hash" text NOT NULL,
+    "created_at" datetime
+);
+CREATE INDEX "idx_password_histories_user_id" ON "password_histories" ("user_id");
+
+CREATE TABLE "roles" (
+    "name" text,
+    "description" text,
+    "built_in" numeric NOT NULL DEFAULT false,
+    "created_at" datetime,
+    "updated_at" datetime,
+    PRIMARY KEY ("name")
+);
+
+CREATE TABLE "role_permissions" (
+    "role_name" text,
+    "permission" text,
+    PRIMARY KEY ("role_name","permission"),
+    CONSTRAINT "fk_roles_permissions" FOREIGN KEY ("role_name") REFERENCES "roles"("name") ON DELETE CASCADE
+);
+
+CREATE TABLE "field_visibilities" (
+    "role" text,
+    "field" text,
+    "access" text NOT NULL,
+    PRIMARY KEY ("role","field")
+);
+
+CREATE TABLE "patient_status_changes" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "patient_id" integer NOT NULL,
+    "from_status" text NOT NULL,
+    "to_status" text NOT NULL,
+    "reason" text,
+    "discharge_summary" text,
+    "changed_by" text NOT NULL,
+    "created_at" datetime
+);
+CREATE INDEX "idx_patient_status_changes_created_at" ON "patient_status_changes" ("created_at");
+CREATE INDEX "idx_patient_status_changes_patient_id" ON "patient_status_changes" ("patient_id");
+
+CREATE TABLE "patient_merges" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "target_id" integer NOT NULL,
+    "source_id" integer NOT NULL,
+    "merged_by" text NOT NULL,
+    "created_at" datetime,
+    "field_changes" text,
+    "moved" text,
+    "unmerged_at" datetime,
+    "unmerged_by" text
+);
+CREATE INDEX "idx_patient_merges_created_at" ON "patient_merges" ("created_at");
+CREATE INDEX "idx_patient_merges_source_id" ON "patient_merges" ("source_id");
+CREATE INDEX "idx_patient_merges_target_id" ON "patient_merges" ("target_id");
+
+CREATE TABLE "emergency_contacts" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "patient_id" integer NOT NULL,
+    "name" text NOT NULL,
+    "relationship" text,
+    "phone" text,
+    "email" text,
+    CONSTRAINT "fk_patients_emergency_contacts" FOREIGN KEY ("patient_id") REFERENCES "patients"("id")
+);
+CREATE INDEX "idx_emergency_contacts_patient_id" ON "emergency_contacts" ("patient_id");
@@ -0,0 +1,21 @@
+DROP TABLE "patient_legacy_values";
+
+DROP INDEX "idx_patients_mrn";
+ALTER TABLE "patients" DROP COLUMN "mrn";
+ALTER TABLE "patients" DROP COLUMN "birth_date";
+ALTER TABLE "patients" DROP COLUMN "phone";
+ALTER TABLE "patients" DROP COLUMN "secondary_phone";
+ALTER TABLE "patients" DROP COLUMN "email";
+ALTER TABLE "patients" DROP COLUMN "address_line1";
+ALTER TABLE "patients" DROP COLUMN "address_line2";
+ALTER TABLE "patients" DROP COLUMN "address_city";
+ALTER TABLE "patients" DROP COLUMN "address_state";
+ALTER TABLE "patients" DROP COLUMN "address_postal_code";
+ALTER TABLE "patients" DROP COLUMN "address_country";
+ALTER TABLE "patients" DROP COLUMN "version";
+
+ALTER TABLE "users" DROP COLUMN "disabled";
+ALTER TABLE "users" DROP COLUMN "must_change_password";
+ALTER TABLE "users" DROP COLUMN "mfa_enabled";
+ALTER TABLE "users" DROP COLUMN "mfa_secret";
+ALTER TABLE "users" DROP COLUMN "mfa_last_step";
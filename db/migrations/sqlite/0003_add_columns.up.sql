@@ -0,0 +1,31 @@
+-- Columns added to users and patients since the first version. The free text dob,
+-- contact and address columns of patients are moved to the typed ones by the next
+-- migration and dropped by the one after, which also sets the new column defaults.
+
+ALTER TABLE "users" ADD COLUMN "disabled" numeric NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "must_change_password" numeric NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "mfa_enabled" numeric NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "mfa_secret" text;
+ALTER TABLE "users" ADD COLUMN "mfa_last_step" integer;
+
+ALTER TABLE "patients" ADD COLUMN "mrn" text;
+ALTER TABLE "patients" ADD COLUMN "birth_date" date;
+ALTER TABLE "patients" ADD COLUMN "phone" text;
+ALTER TABLE "patients" ADD COLUMN "secondary_phone" text;
+ALTER TABLE "patients" ADD COLUMN "email" text;
+ALTER TABLE "patients" ADD COLUMN "address_line1" text;
+ALTER TABLE "patients" ADD COLUMN "address_line2" text;
+ALTER TABLE "patients" ADD COLUMN "address_city" text;
+ALTER TABLE "patients" ADD COLUMN "address_state" text;
+ALTER TABLE "patients" ADD COLUMN "address_postal_code" text;
+ALTER TABLE "patients" ADD COLUMN "address_country" text;
+ALTER TABLE "patients" ADD COLUMN "version" integer NOT NULL DEFAULT 1;
+CREATE UNIQUE INDEX "idx_patients_mrn" ON "patients" ("mrn") WHERE mrn <> '';
+
+-- Legacy values the next migration cannot parse, kept to be fixed by hand
+CREATE TABLE "patient_legacy_values" (
+    "patient_id" integer NOT NULL,
+    "field" text NOT NULL,
+    "value" text NOT NULL,
+    PRIMARY KEY ("patient_id","field")
+);
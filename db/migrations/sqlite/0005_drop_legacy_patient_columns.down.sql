@@ -0,0 +1,37 @@
+-- Rebuilds the table as it was after migration 3, with the empty legacy columns back
+
+CREATE TABLE "patients_old" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "first_name" text NOT NULL,
+    "last_name" text NOT NULL,
+    "dob" text,
+    "gender" text,
+    "contact" text,
+    "address" text,
+    "doctor_notes" text,
+    "status" text DEFAULT 'active',
+    "mrn" text,
+    "birth_date" date,
+    "phone" text,
+    "secondary_phone" text,
+    "email" text,
+    "address_line1" text,
+    "address_line2" text,
+    "address_city" text,
+    "address_state" text,
+    "address_postal_code" text,
+    "address_country" text,
+    "version" integer NOT NULL DEFAULT 1,
+    CONSTRAINT "uni_patients_contact" UNIQUE ("contact")
+);
+INSERT INTO "patients_old" ("id", "created_at", "updated_at", "deleted_at", "mrn", "first_name", "last_name", "birth_date", "gender", "phone", "secondary_phone", "email", "address_line1", "address_line2", "address_city", "address_state", "address_postal_code", "address_country", "doctor_notes", "status", "version")
+    SELECT "id", "created_at", "updated_at", "deleted_at", "mrn", "first_name", "last_name", "birth_date", "gender", "phone", "secondary_phone", "email", "address_line1", "address_line2", "address_city", "address_state", "address_postal_code", "address_country", "doctor_notes", "status", "version" FROM "patients";
+DELETE FROM sqlite_sequence WHERE name = 'patients_old';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'patients_old', seq FROM sqlite_sequence WHERE name = 'patients';
+DROP TABLE "patients";
+ALTER TABLE "patients_old" RENAME TO "patients";
+CREATE UNIQUE INDEX "idx_patients_mrn" ON "patients" ("mrn") WHERE mrn <> '';
+CREATE INDEX "idx_patients_deleted_at" ON "patients" ("deleted_at");
@@ -0,0 +1,38 @@
+-- The free text columns of patients, moved to the typed ones by the previous migration.
+-- SQLite cannot drop a column with a unique constraint or change a default, so the table
+-- is rebuilt. The foreign keys of other tables name the table, so they refer to the new
+-- one. This needs foreign key enforcement off, the default unless DATABASE_URL turns it
+-- on with _foreign_keys.
+
+CREATE TABLE "patients_new" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "mrn" text,
+    "first_name" text NOT NULL,
+    "last_name" text NOT NULL,
+    "birth_date" date,
+    "gender" text DEFAULT 'unknown',
+    "phone" text,
+    "secondary_phone" text,
+    "email" text,
+    "address_line1" text,
+    "address_line2" text,
+    "address_city" text,
+    "address_state" text,
+    "address_postal_code" text,
+    "address_country" text,
+    "doctor_notes" text,
+    "status" text DEFAULT 'registered',
+    "version" integer NOT NULL DEFAULT 1
+);
+INSERT INTO "patients_new" ("id", "created_at", "updated_at", "deleted_at", "mrn", "first_name", "last_name", "birth_date", "gender", "phone", "secondary_phone", "email", "address_line1", "address_line2", "address_city", "address_state", "address_postal_code", "address_country", "doctor_notes", "status", "version")
+    SELECT "id", "created_at", "updated_at", "deleted_at", "mrn", "first_name", "last_name", "birth_date", "gender", "phone", "secondary_phone", "email", "address_line1", "address_line2", "address_city", "address_state", "address_postal_code", "address_country", "doctor_notes", "status", "version" FROM "patients";
+-- Keep the ID counter, so IDs of purged patients are not handed out again
+DELETE FROM sqlite_sequence WHERE name = 'patients_new';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'patients_new', seq FROM sqlite_sequence WHERE name = 'patients';
+DROP TABLE "patients";
+ALTER TABLE "patients_new" RENAME TO "patients";
+CREATE UNIQUE INDEX "idx_patients_mrn" ON "patients" ("mrn") WHERE mrn <> '';
+CREATE INDEX "idx_patients_deleted_at" ON "patients" ("deleted_at");
@@ -0,0 +1,2 @@
+-- SQLite has no index for LIKE '%...%', so patient search scans the table there; the
+-- migration only keeps the versions of both databases the same.
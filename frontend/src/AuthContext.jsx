@@ -24,6 +24,7 @@ export const AuthProvider = ({ children }) => {
 
             if (response.ok) {
                 localStorage.setItem('jwtToken', data.token);
+                localStorage.setItem('refreshToken', data.refresh_token);
                 localStorage.setItem('username', data.user.username);
                 localStorage.setItem('role', data.user.role);
                 setToken(data.token);
@@ -31,7 +32,7 @@ export const AuthProvider = ({ children }) => {
                 setRole(data.user.role);
                 return { success: true, message: 'Login successful!' };
             } else {
-                return { success: false, message: data.error || 'Login failed.' };
+                return { success: false, message: data.detail || 'Login failed.' };
             }
         } catch (error) {
             console.error('Login error:', error);
@@ -39,9 +40,21 @@ export const AuthProvider = ({ children }) => {
         }
     };
 
-    // Logout function: clears local storage and state, redirects to login
-    const logout = () => {
+    // Logout function: revokes the tokens server-side, clears local storage and state, redirects to login
+    const logout = async () => {
+        if (token) {
+            try {
+                await fetch('/api/logout', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json', 'Authorization': `Bearer ${token}` },
+                    body: JSON.stringify({ refresh_token: localStorage.getItem('refreshToken') || '' })
+                });
+            } catch (error) {
+                console.error('Logout error:', error);
+            }
+        }
         localStorage.removeItem('jwtToken');
+        localStorage.removeItem('refreshToken');
         localStorage.removeItem('username');
         localStorage.removeItem('role');
         setToken(null);
@@ -5,6 +5,23 @@ const api = {
         ...(token && { 'Authorization': `Bearer ${token}` }), 
     }),
 
+    // Headers for updating a patient: If-Match carries the version the form was loaded from,
+    // so the server answers 412 instead of overwriting someone else's changes
+    _versionedHeaders: (token, version) => ({
+        ...api._headers(token),
+        'If-Match': `"${version}"`,
+    }),
+
+    // Read a response body. Errors come as RFC 7807 problem details; their detail is
+    // copied to "error", which the pages show
+    _json: async (response) => {
+        const data = await response.json();
+        if (!response.ok && data.detail) {
+            data.error = data.detail;
+        }
+        return data;
+    },
+
     // Authenticate user
     login: async (username, password) => {
         const response = await fetch('/api/login', {
@@ -12,7 +29,7 @@ const api = {
             headers: api._headers(), // No token needed for login
             body: JSON.stringify({ username, password }),
         });
-        return response.json();
+        return api._json(response);
     },
 
     // Get all patients
@@ -21,7 +38,7 @@ const api = {
             method: 'GET',
             headers: api._headers(token), // Token required
         });
-        return response.json();
+        return api._json(response);
     },
 
     // Create a new patient (receptionist only)
@@ -31,7 +48,7 @@ const api = {
             headers: api._headers(token), // Token required
             body: JSON.stringify(patientData),
         });
-        return response.json();
+        return api._json(response);
     },
 
     // Get a specific patient by ID (receptionist or doctor)
@@ -40,17 +57,19 @@ const api = {
             method: 'GET',
             headers: api._headers(token), // Token required
         });
-        return response.json();
+        return api._json(response);
     },
 
-    // Update patient details 
+    // Update patient details (JSON Merge Patch: only the edited demographic fields and address parts are sent)
     updatePatient: async (id, patientData, token) => {
-        const response = await fetch(`/api/receptionist/patients/${id}`, {
-            method: 'PUT',
-            headers: api._headers(token), // Token required
-            body: JSON.stringify(patientData),
+        const fields = ['first_name', 'last_name', 'dob', 'gender', 'phone', 'secondary_phone', 'email', 'address'];
+        const patch = Object.fromEntries(fields.filter((f) => f in patientData).map((f) => [f, patientData[f]]));
+        const response = await fetch(`/api/patients/${id}`, {
+            method: 'PATCH',
+            headers: api._versionedHeaders(token, patientData.Version), // Token required
+            body: JSON.stringify(patch),
         });
-        return response.json();
+        return api._json(response);
     },
 
     // Delete a patient 
@@ -59,17 +78,17 @@ const api = {
             method: 'DELETE',
             headers: api._headers(token), // Token required
         });
-        return response.json();
+        return api._json(response);
     },
 
     // Update doctor notes and patient status (doctor only)
-    updateDoctorNotes: async (id, notesData, token) => {
+    updateDoctorNotes: async (id, notesData, version, token) => {
         const response = await fetch(`/api/doctor/patients/${id}/notes`, {
             method: 'PUT',
-            headers: api._headers(token), // Token required
+            headers: api._versionedHeaders(token, version), // Token required
             body: JSON.stringify(notesData),
         });
-        return response.json();
+        return api._json(response);
     },
 };
 
@@ -5,7 +5,9 @@ const PatientTable = ({ patients, role, onEdit, onDelete, onViewEditNotes, searc
     const filteredPatients = patients.filter(patient =>
         (patient.FirstName && patient.FirstName.toLowerCase().includes(searchTerm)) ||
         (patient.LastName && patient.LastName.toLowerCase().includes(searchTerm)) ||
-        (patient.Contact && patient.Contact.toLowerCase().includes(searchTerm)) ||
+        (patient.MRN && patient.MRN.includes(searchTerm)) ||
+        (patient.Phone && patient.Phone.toLowerCase().includes(searchTerm)) ||
+        (patient.Email && patient.Email.toLowerCase().includes(searchTerm)) ||
         (patient.DOB && patient.DOB.toLowerCase().includes(searchTerm)) ||
         (patient.Gender && patient.Gender.toLowerCase().includes(searchTerm)) ||
         (patient.Status && patient.Status.toLowerCase().includes(searchTerm)) ||
@@ -17,9 +19,9 @@ const PatientTable = ({ patients, role, onEdit, onDelete, onViewEditNotes, searc
             <table className="min-w-full divide-y divide-gray-200">
                 <thead className="bg-gray-50">
                     <tr>
-                        <th scope="col" className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">ID</th>
+                        <th scope="col" className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">MRN</th>
                         <th scope="col" className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Name</th>
-                        <th scope="col" className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Contact</th>
+                        <th scope="col" className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Phone / Email</th>
                         <th scope="col" className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">DOB</th>
                         <th scope="col" className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Gender</th>
                         <th scope="col" className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Status</th>
@@ -38,9 +40,9 @@ const PatientTable = ({ patients, role, onEdit, onDelete, onViewEditNotes, searc
                     ) : (
                         filteredPatients.map(patient => (
                             <tr key={patient.ID} className="hover:bg-gray-100">
-                                <td className="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{patient.ID}</td>
+                                <td className="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{patient.MRN || patient.ID}</td>
                                 <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-800">{patient.FirstName} {patient.LastName}</td>
-                                <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-800">{patient.Contact}</td>
+                                <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-800">{[patient.Phone, patient.Email].filter(Boolean).join(' / ')}</td>
                                 <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-800">{patient.DOB || 'N/A'}</td>
                                 <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-800">{patient.Gender || 'N/A'}</td>
                                 <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-800 capitalize">{patient.Status || 'active'}</td>
@@ -66,7 +68,7 @@ const PatientTable = ({ patients, role, onEdit, onDelete, onViewEditNotes, searc
                                     )}
                                     {role === 'doctor' && (
                                         <button
-                                            onClick={() => onViewEditNotes(patient.ID, `${patient.FirstName} ${patient.LastName}`, patient.DoctorNotes, patient.Status)} // Callback for viewing/editing notes
+                                            onClick={() => onViewEditNotes(patient.ID, `${patient.FirstName} ${patient.LastName}`, patient.DoctorNotes, patient.Status, patient.Version)} // Callback for viewing/editing notes
                                             className="bg-blue-500 hover:bg-blue-600 text-white px-3 py-1 rounded-md text-sm"
                                         >
                                             View/Edit Notes
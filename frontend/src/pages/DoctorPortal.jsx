@@ -52,8 +52,8 @@ const DoctorPortal = () => {
     }, [token, role, fetchPatients]);
 
     // Open the "Edit Doctor Notes" modal and load patient data
-    const handleViewEditNotes = (id, name, notes, status) => {
-        setCurrentNotesPatient({ id, name, doctor_notes: notes || '', status: status || 'active' });
+    const handleViewEditNotes = (id, name, notes, status, version) => {
+        setCurrentNotesPatient({ id, name, doctor_notes: notes || '', status: status || 'registered', version, reason: '', discharge_summary: '' });
         setIsNotesModalOpen(true); 
         setEditNotesMessage('');
         setEditNotesMessageType('');
@@ -74,7 +74,13 @@ const DoctorPortal = () => {
         try {
             const data = await api.updateDoctorNotes( 
                 currentNotesPatient.id,
-                { doctor_notes: currentNotesPatient.doctor_notes, status: currentNotesPatient.status },
+                {
+                    doctor_notes: currentNotesPatient.doctor_notes,
+                    status: currentNotesPatient.status,
+                    reason: currentNotesPatient.reason,
+                    discharge_summary: currentNotesPatient.discharge_summary,
+                },
+                currentNotesPatient.version,
                 token
             );
             if (data.message) {
@@ -82,6 +88,12 @@ const DoctorPortal = () => {
                 setEditNotesMessageType('success');
                 setIsNotesModalOpen(false); 
                 fetchPatients(); // Refresh patient list
+            } else if (data.patient) {
+                // Someone else saved first: show their version and let the doctor re-apply the edit
+                setCurrentNotesPatient(prevState => ({ ...prevState, version: data.patient.Version }));
+                setEditNotesMessage(`${data.error} Current notes: ${data.patient.DoctorNotes || '(none)'}`);
+                setEditNotesMessageType('error');
+                fetchPatients();
             } else {
                 setEditNotesMessage(data.error || 'Failed to update notes.');
                 setEditNotesMessageType('error');
@@ -140,13 +152,31 @@ const DoctorPortal = () => {
                         <div>
                             <label htmlFor="patientStatus" className="block text-sm font-medium text-gray-700">Status:</label>
                             <select id="patientStatus" name="status"
-                                value={currentNotesPatient.status || 'active'} onChange={handleNotesFormChange}
+                                value={currentNotesPatient.status || 'registered'} onChange={handleNotesFormChange}
                                 className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm">
-                                <option value="active">Active</option>
+                                <option value="registered">Registered</option>
+                                <option value="admitted">Admitted</option>
+                                <option value="under_treatment">Under Treatment</option>
                                 <option value="discharged">Discharged</option>
-                                <option value="on_leave">On Leave</option>
+                                <option value="deceased">Deceased</option>
+                                <option value="archived">Archived</option>
                             </select>
                         </div>
+                        {/* Some status changes need a reason (e.g. readmission, deceased) or a discharge summary */}
+                        <div>
+                            <label htmlFor="statusReason" className="block text-sm font-medium text-gray-700">Reason for status change:</label>
+                            <input type="text" id="statusReason" name="reason"
+                                value={currentNotesPatient.reason || ''} onChange={handleNotesFormChange}
+                                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm focus:outline-none focus:ring-indigo-500 focus:border-indigo-500 sm:text-sm" />
+                        </div>
+                        {currentNotesPatient.status === 'discharged' && (
+                            <div>
+                                <label htmlFor="dischargeSummary" className="block text-sm font-medium text-gray-700">Discharge Summary:</label>
+                                <textarea id="dischargeSummary" name="discharge_summary" rows="4"
+                                    value={currentNotesPatient.discharge_summary || ''} onChange={handleNotesFormChange}
+                                    className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm focus:outline-none focus:ring-indigo-500 focus:border-indigo-500 sm:text-sm"></textarea>
+                            </div>
+                        )}
                         <div className="flex justify-end space-x-4 mt-6">
                             <button type="button" onClick={() => setIsNotesModalOpen(false)}
                                 className="px-4 py-2 border border-gray-300 rounded-md shadow-sm text-sm font-medium text-gray-700 bg-white hover:bg-gray-50 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-indigo-500">
@@ -4,6 +4,16 @@ import api from '../api';
 import Modal from '../components/Modal'; 
 import Notification from '../components/Notification'; 
 import PatientTable from '../components/PatientTable'; 
+const emptyAddress = { line1: '', line2: '', city: '', state: '', postal_code: '', country: '' };
+
+// withField sets a form field; "address.<part>" names set a part of the address object
+const withField = (form, name, value) => {
+    if (name.startsWith('address.')) {
+        return { ...form, address: { ...form.address, [name.slice('address.'.length)]: value } };
+    }
+    return { ...form, [name]: value };
+};
+
 // ReceptionistPortal component handles patient management for receptionists
 const ReceptionistPortal = () => {
     const { username, role, logout, token } = useAuth(); 
@@ -14,9 +24,7 @@ const ReceptionistPortal = () => {
     const [searchTerm, setSearchTerm] = useState('');
 
     // State for Add Patient Form
-    const [addPatientForm, setAddPatientForm] = useState({
-        first_name: '', last_name: '', dob: '', gender: '', contact: '', address: ''
-    });
+    const [addPatientForm, setAddPatientForm] = useState({ first_name: '', last_name: '', dob: '', gender: '', phone: '', email: '', address: emptyAddress });
     const [addPatientMessage, setAddPatientMessage] = useState('');
     const [addPatientMessageType, setAddPatientMessageType] = useState('');
 
@@ -62,7 +70,7 @@ const ReceptionistPortal = () => {
 
     const handleAddPatientChange = (e) => {
         const { name, value } = e.target;
-        setAddPatientForm(prevState => ({ ...prevState, [name]: value }));
+        setAddPatientForm(prevState => withField(prevState, name, value));
     };
 
     const handleAddPatientSubmit = async (e) => {
@@ -71,11 +79,21 @@ const ReceptionistPortal = () => {
         setAddPatientMessageType('');
 
         try {
-            const data = await api.createPatient(addPatientForm, token); 
+            let data = await api.createPatient(addPatientForm, token); 
+            if (data.duplicates) {
+                // Possible duplicates: let the receptionist confirm it is a different person
+                const list = data.duplicates.map((d) => `#${d.patient.ID} ${d.patient.FirstName} ${d.patient.LastName} ${d.patient.DOB || ''} (${d.reasons.join(', ')})`).join('\n');
+                if (!window.confirm(`This patient may already be registered:\n${list}\n\nRegister anyway?`)) {
+                    setAddPatientMessage('Registration cancelled, the patient may already exist.');
+                    setAddPatientMessageType('error');
+                    return;
+                }
+                data = await api.createPatient({ ...addPatientForm, allow_duplicate: true }, token);
+            }
             if (data.message) {
                 setAddPatientMessage('Patient added successfully!');
                 setAddPatientMessageType('success');
-                setAddPatientForm({ first_name: '', last_name: '', dob: '', gender: '', contact: '', address: '' }); // Reset form
+                setAddPatientForm({ first_name: '', last_name: '', dob: '', gender: '', phone: '', email: '', address: emptyAddress }); // Reset form
                 fetchPatients(); 
             } else {
                 setAddPatientMessage(data.error || 'Failed to add patient.');
@@ -110,7 +128,7 @@ const ReceptionistPortal = () => {
 
     const handleEditFormChange = (e) => {
         const { name, value } = e.target;
-        setCurrentEditPatient(prevState => ({ ...prevState, [name]: value }));
+        setCurrentEditPatient(prevState => withField(prevState, name, value));
     };
 
     const handleEditFormSubmit = async (e) => {
@@ -127,6 +145,12 @@ const ReceptionistPortal = () => {
                 setEditPatientMessageType('success');
                 setIsEditModalOpen(false); 
                 fetchPatients(); 
+            } else if (data.patient) {
+                // Someone else saved first: load the current record and version, keeping the
+                // edited (snake_case) fields so they can be submitted again
+                setCurrentEditPatient(prevState => ({ ...prevState, ...data.patient }));
+                setEditPatientMessage(data.error);
+                setEditPatientMessageType('error');
             } else {
                 setEditPatientMessage(data.error || 'Failed to update patient.');
                 setEditPatientMessageType('error');
@@ -217,21 +241,58 @@ const ReceptionistPortal = () => {
                             value={addPatientForm.gender} onChange={handleAddPatientChange}
                             className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm">
                             <option value="">Select Gender</option>
-                            <option value="Male">Male</option>
-                            <option value="Female">Female</option>
-                            <option value="Other">Other</option>
+                            <option value="female">Female</option>
+                            <option value="male">Male</option>
+                            <option value="other">Other</option>
+                            <option value="unknown">Unknown</option>
                         </select>
                     </div>
                     <div>
-                        <label htmlFor="contact" className="block text-sm font-medium text-gray-700">Contact:</label>
-                        <input type="text" id="contact" name="contact" required
-                            value={addPatientForm.contact} onChange={handleAddPatientChange}
+                        <label htmlFor="phone" className="block text-sm font-medium text-gray-700">Phone:</label>
+                        <input type="tel" id="phone" name="phone"
+                            value={addPatientForm.phone} onChange={handleAddPatientChange}
+                            className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                    </div>
+                    <div>
+                        <label htmlFor="email" className="block text-sm font-medium text-gray-700">Email:</label>
+                        <input type="email" id="email" name="email"
+                            value={addPatientForm.email} onChange={handleAddPatientChange}
+                            className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                    </div>
+                    <div>
+                        <label htmlFor="addressLine1" className="block text-sm font-medium text-gray-700">Address Line 1:</label>
+                        <input type="text" id="addressLine1" name="address.line1"
+                            value={addPatientForm.address.line1} onChange={handleAddPatientChange}
+                            className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                    </div>
+                    <div>
+                        <label htmlFor="addressLine2" className="block text-sm font-medium text-gray-700">Address Line 2:</label>
+                        <input type="text" id="addressLine2" name="address.line2"
+                            value={addPatientForm.address.line2} onChange={handleAddPatientChange}
+                            className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                    </div>
+                    <div>
+                        <label htmlFor="addressCity" className="block text-sm font-medium text-gray-700">City:</label>
+                        <input type="text" id="addressCity" name="address.city"
+                            value={addPatientForm.address.city} onChange={handleAddPatientChange}
+                            className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                    </div>
+                    <div>
+                        <label htmlFor="addressState" className="block text-sm font-medium text-gray-700">State:</label>
+                        <input type="text" id="addressState" name="address.state"
+                            value={addPatientForm.address.state} onChange={handleAddPatientChange}
                             className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
                     </div>
                     <div>
-                        <label htmlFor="address" className="block text-sm font-medium text-gray-700">Address:</label>
-                        <input type="text" id="address" name="address"
-                            value={addPatientForm.address} onChange={handleAddPatientChange}
+                        <label htmlFor="addressPostalCode" className="block text-sm font-medium text-gray-700">Postal Code:</label>
+                        <input type="text" id="addressPostalCode" name="address.postal_code"
+                            value={addPatientForm.address.postal_code} onChange={handleAddPatientChange}
+                            className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                    </div>
+                    <div>
+                        <label htmlFor="addressCountry" className="block text-sm font-medium text-gray-700">Country:</label>
+                        <input type="text" id="addressCountry" name="address.country"
+                            value={addPatientForm.address.country} onChange={handleAddPatientChange}
                             className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
                     </div>
                     <div className="md:col-span-2">
@@ -287,21 +348,58 @@ const ReceptionistPortal = () => {
                                 value={currentEditPatient?.Gender || ''} onChange={handleEditFormChange}
                                 className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm">
                                 <option value="">Select Gender</option>
-                                <option value="Male">Male</option>
-                                <option value="Female">Female</option>
-                                <option value="Other">Other</option>
+                                <option value="female">Female</option>
+                                <option value="male">Male</option>
+                                <option value="other">Other</option>
+                                <option value="unknown">Unknown</option>
                             </select>
                         </div>
                         <div>
-                            <label htmlFor="editContact" className="block text-sm font-medium text-gray-700">Contact:</label>
-                            <input type="text" id="editContact" name="contact" required
-                                value={currentEditPatient?.Contact || ''} onChange={handleEditFormChange}
+                            <label htmlFor="editPhone" className="block text-sm font-medium text-gray-700">Phone:</label>
+                            <input type="tel" id="editPhone" name="phone"
+                                value={currentEditPatient?.phone ?? currentEditPatient?.Phone ?? ''} onChange={handleEditFormChange}
+                                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                        </div>
+                        <div>
+                            <label htmlFor="editEmail" className="block text-sm font-medium text-gray-700">Email:</label>
+                            <input type="email" id="editEmail" name="email"
+                                value={currentEditPatient?.email ?? currentEditPatient?.Email ?? ''} onChange={handleEditFormChange}
+                                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                        </div>
+                        <div>
+                            <label htmlFor="editAddressLine1" className="block text-sm font-medium text-gray-700">Address Line 1:</label>
+                            <input type="text" id="editAddressLine1" name="address.line1"
+                                value={currentEditPatient?.address?.line1 ?? currentEditPatient?.Address?.Line1 ?? ''} onChange={handleEditFormChange}
+                                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                        </div>
+                        <div>
+                            <label htmlFor="editAddressLine2" className="block text-sm font-medium text-gray-700">Address Line 2:</label>
+                            <input type="text" id="editAddressLine2" name="address.line2"
+                                value={currentEditPatient?.address?.line2 ?? currentEditPatient?.Address?.Line2 ?? ''} onChange={handleEditFormChange}
+                                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                        </div>
+                        <div>
+                            <label htmlFor="editAddressCity" className="block text-sm font-medium text-gray-700">City:</label>
+                            <input type="text" id="editAddressCity" name="address.city"
+                                value={currentEditPatient?.address?.city ?? currentEditPatient?.Address?.City ?? ''} onChange={handleEditFormChange}
+                                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                        </div>
+                        <div>
+                            <label htmlFor="editAddressState" className="block text-sm font-medium text-gray-700">State:</label>
+                            <input type="text" id="editAddressState" name="address.state"
+                                value={currentEditPatient?.address?.state ?? currentEditPatient?.Address?.State ?? ''} onChange={handleEditFormChange}
+                                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
+                        </div>
+                        <div>
+                            <label htmlFor="editAddressPostalCode" className="block text-sm font-medium text-gray-700">Postal Code:</label>
+                            <input type="text" id="editAddressPostalCode" name="address.postal_code"
+                                value={currentEditPatient?.address?.postal_code ?? currentEditPatient?.Address?.PostalCode ?? ''} onChange={handleEditFormChange}
                                 className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
                         </div>
                         <div>
-                            <label htmlFor="editAddress" className="block text-sm font-medium text-gray-700">Address:</label>
-                            <input type="text" id="editAddress" name="address"
-                                value={currentEditPatient?.Address || ''} onChange={handleEditFormChange}
+                            <label htmlFor="editAddressCountry" className="block text-sm font-medium text-gray-700">Country:</label>
+                            <input type="text" id="editAddressCountry" name="address.country"
+                                value={currentEditPatient?.address?.country ?? currentEditPatient?.Address?.Country ?? ''} onChange={handleEditFormChange}
                                 className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm" />
                         </div>
                         <div className="flex justify-end space-x-4 mt-6">
@@ -1,114 +1,136 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"medical_app/config"
-	"medical_app/controllers"
 	"medical_app/db"
-	"medical_app/models"
-	"medical_app/routes"
 	"medical_app/services"
-	"github.com/gin-gonic/gin"
+	"os"
+
 	"gorm.io/gorm"
 )
 
-func main() {
-	// Load Configuration
-	cfg := config.LoadConfig()
-
-	//  Initialize Database
-	database.InitDB(cfg)
+// command is a subcommand of the binary
+type command struct {
+	name    string
+	args    string // synopsis of the flags and arguments
+	summary string
+	run     func(args []string) error
+}
 
-	err := database.DB.AutoMigrate(&models.User{}, &models.Patient{})
-	if err != nil {
-		log.Fatalf("Failed to auto migrate database: %v", err)
+// commands lists the subcommands. Without one the binary runs serve. It is filled in
+// init because the usage of each command refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "Start the API server", runServe},
+		{"migrate", "up | down [steps] | status | to <version>", "Apply or roll back schema migrations", runMigrate},
+		{"create-user", "-username <name> -role <role> [-password-stdin] [-must-change=false]", "Create a user account", runCreateUser},
+		{"reset-password", "-username <name> [-password-stdin]", "Set a new password for a user and end their sessions", runResetPassword},
+		{"seed", "[-password-stdin]", "Add demo users, patients and appointments to an empty database", runSeed},
+		{"export", "[-o <file>]", "Write every patient as JSON lines", runExport},
+		{"import", "[-i <file>]", "Create patients from JSON lines written by export", runImport},
+		{"check-config", "", "Check the configuration, the database connection and the schema", runCheckConfig},
 	}
-	log.Println("Database migrations completed successfully")
-
-	// Default Users (for initial setup)
-	bootstrapUsers(database.DB)
-
-	// services
-	authService := services.NewAuthService(database.DB)
-	userService := services.NewUserService(database.DB)
-	patientService := services.NewPatientService(database.DB)
-
-	// Controllers
-	authController := controllers.NewAuthController(authService, userService, cfg)
-	patientController := controllers.NewPatientController(patientService)
-
-	// Setup Gin Router
-	router := gin.Default()
+}
 
-	// CORS middleware
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(os.Stdout)
+		return
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
 		}
-		c.Next()
-	})
-
-	routes.SetupRoutes(router, authController, patientController, cfg)
-
-	// Start Server
-	log.Printf("Server starting on :%s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+		if err := cmd.run(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			log.Fatalf("%s: %v", name, err)
+		}
+		return
 	}
-
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	os.Exit(2)
 }
 
-// bootstrapUsers creates default users if they don't exist
-func bootstrapUsers(db *gorm.DB) {
-	userService := services.NewUserService(db)
-
-	// Create a default receptionist
-	receptionistUser := &models.User{
-		Username: "receptionist",
-		Password: "password", // Will be hashed by service
-		Role:     "receptionist",
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s <command> [arguments]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-15s %s\n", cmd.name, cmd.summary)
+		if cmd.args != "" {
+			fmt.Fprintf(w, "  %-15s   %s %s\n", "", cmd.name, cmd.args)
+		}
 	}
-	_, err := userService.GetUserByUsername(receptionistUser.Username)
-	if err != nil { // User not found, create it
-		if err := userService.CreateUser(receptionistUser); err != nil {
-			log.Printf("Failed to bootstrap receptionist user: %v", err)
-		} else {
-			log.Println("Bootstrapped default receptionist user: receptionist/password")
+	fmt.Fprintln(w, "\nWithout a command the server is started.")
+}
+
+// newFlagSet returns the flags of a command, which report errors instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		for _, cmd := range commands {
+			if cmd.name == name {
+				fmt.Fprintf(flags.Output(), "usage: %s %s\n", name, cmd.args)
+			}
 		}
-	} else {
-		log.Println("Receptionist user already exists.")
+		flags.PrintDefaults()
 	}
+	return flags
+}
 
-	// Create a default doctor
-	doctorUser := &models.User{
-		Username: "doctor",
-		Password: "password", // Will be hashed by service
-		Role:     "doctor",
+// openDatabase loads the configuration and connects to the database. With checkSchema
+// it refuses a database whose migrations are not current, which every command but
+// migrate and check-config needs.
+func openDatabase(checkSchema bool) (*config.Config, *gorm.DB, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	db := database.InitDB(cfg)
+	if !checkSchema {
+		return cfg, db, nil
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return nil, nil, err
 	}
-	_, err = userService.GetUserByUsername(doctorUser.Username)
-	if err != nil { // User not found, create it
-		if err := userService.CreateUser(doctorUser); err != nil {
-			log.Printf("Failed to bootstrap doctor user: %v", err)
-		} else {
-			log.Println("Bootstrapped default doctor user: doctor/password")
+	if database.InMemory(db.Dialector) {
+		// Nothing to keep from an earlier run, so it is migrated rather than refused
+		if _, err := migrator.Up(); err != nil {
+			return nil, nil, err
 		}
-	} else {
-		log.Println("Doctor user already exists.")
 	}
+	if err := migrator.CheckSchema(); err != nil {
+		return nil, nil, fmt.Errorf("%w; run the \"migrate up\" command first", err)
+	}
+	return cfg, db, nil
 }
 
+// seedBuiltIns creates the built-in roles and field visibility rules where missing
+func seedBuiltIns(db *gorm.DB) error {
+	if err := services.NewRoleService(db).SeedBuiltInRoles(); err != nil {
+		return fmt.Errorf("failed to create built-in roles: %w", err)
+	}
+	if err := services.NewFieldVisibilityService(db).SeedBuiltInRules(); err != nil {
+		return fmt.Errorf("failed to create built-in field visibility rules: %w", err)
+	}
+	return nil
+}
 
-
-
-
-
-
-
-
-
+// cliActor is the audit actor of patient data read or changed by a command
+func cliActor(command string) services.AuditActor {
+	return services.AuditActor{Username: "system", Role: "system", Method: "CLI", Route: command}
+}
@@ -1,74 +1,105 @@
 package middlewares
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"medical_app/config"
+	"medical_app/services"
 	"medical_app/utils"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware authenticates requests using JWT
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// AuthMiddleware authenticates requests using JWT and rejects tokens revoked by logout
+// or belonging to disabled accounts. The permissions of the user's role are loaded for
+// RequirePermission.
+func AuthMiddleware(cfg *config.Config, tokenSvc services.TokenService, roleSvc services.RoleService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			utils.AbortWithProblem(c, http.StatusUnauthorized, "missing_token", "Authorization header required", nil)
 			return
 		}
 
 		// Expected format: Bearer <token>
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header format"})
-			c.Abort()
+			utils.AbortWithProblem(c, http.StatusUnauthorized, "invalid_token", "Invalid Authorization header format", nil)
 			return
 		}
 
 		tokenString := parts[1]
 		claims, err := utils.ValidateJWT(tokenString, cfg)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Invalid or expired token: %v", err)})
-			c.Abort()
+			utils.AbortWithProblem(c, http.StatusUnauthorized, "invalid_token", fmt.Sprintf("Invalid or expired token: %v", err), nil)
 			return
 		}
 
-		// Store user info in context for downstream handlers
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+		if claims.Purpose != "" {
+			utils.AbortWithProblem(c, http.StatusUnauthorized, "invalid_token", "Invalid or expired token: not an access token", nil)
+			return
+		}
+		if claims.ID == "" {
+			utils.AbortWithProblem(c, http.StatusUnauthorized, "invalid_token", "Invalid or expired token: missing token ID", nil)
+			return
+		}
+		user, err := tokenSvc.SessionUser(claims.ID, claims.Username)
+		if err != nil {
+			if errors.Is(err, services.ErrTokenRevoked) || errors.Is(err, services.ErrSessionUserInactive) {
+				utils.AbortWithProblem(c, http.StatusUnauthorized, services.AsError(err).Code, err.Error(), nil)
+				return
+			}
+			log.Printf("Failed to validate session: %v", err)
+			utils.AbortWithProblem(c, http.StatusInternalServerError, "internal_error", "Failed to validate token", nil)
+			return
+		}
+
+		permissions, err := roleSvc.Permissions(user.Role)
+		if err != nil {
+			log.Printf("Failed to load permissions of role %s: %v", user.Role, err)
+			utils.AbortWithProblem(c, http.StatusInternalServerError, "internal_error", "Failed to validate token", nil)
+			return
+		}
+
+		// Store user info in context for downstream handlers. The role and permissions come
+		// from the database rather than the token, so changes apply immediately.
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Set("permissions", permissions)
+		c.Set("jti", claims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+		c.Set("must_change_password", user.MustChangePassword)
 		c.Next()
 	}
 }
 
-// AuthorizeRoles returns a middleware that checks if the user has one of the allowed roles
-func AuthorizeRoles(allowedRoles ...string) gin.HandlerFunc {
+// RequirePasswordChanged blocks users who have to change their password first,
+// e.g. after an admin reset. Must run after AuthMiddleware.
+func RequirePasswordChanged() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Role not found in context"})
-			c.Abort()
-			return
-		}
-
-		userRole, ok := role.(string)
-		if !ok {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role type in context"})
-			c.Abort()
+		if c.GetBool("must_change_password") {
+			utils.AbortWithProblem(c, http.StatusForbidden, "password_change_required", "Password change required", gin.H{"must_change_password": true})
 			return
 		}
+		c.Next()
+	}
+}
 
-		for _, allowedRole := range allowedRoles {
-			if userRole == allowedRole {
-				c.Next()
+// RequirePermission returns a middleware that checks the user's role grants every given permission
+func RequirePermission(required ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice("permissions")
+		for _, permission := range required {
+			if !slices.Contains(granted, permission) {
+				utils.AbortWithProblem(c, http.StatusForbidden, "permission_denied", "Missing permission "+permission, gin.H{"permission": permission})
 				return
 			}
 		}
-
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Insufficient role permissions"})
-		c.Abort()
+		c.Next()
 	}
 }
-
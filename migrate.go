@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"medical_app/db"
+	"strconv"
+	"strings"
+)
+
+// runMigrate runs the migrate subcommand: up applies every pending migration, down rolls
+// back the last one (or the given number), to migrates up or down to a version and status
+// lists the migrations and whether they have been applied
+func runMigrate(args []string) error {
+	flags := newFlagSet("migrate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return errors.New("missing migrate command")
+	}
+
+	_, db, err := openDatabase(false)
+	if err != nil {
+		return err
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	before, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	var ran []database.Migration
+	switch command := args[0]; {
+	case command == "status" && len(args) == 1:
+		return printMigrationStatus(migrator)
+	case command == "up" && len(args) == 1:
+		ran, err = migrator.Up()
+	case command == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+		}
+		ran, err = migrator.Down(steps)
+	case command == "to" && len(args) == 2:
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return fmt.Errorf("version must be a number, got %q", args[1])
+		}
+		ran, err = migrator.To(version)
+	default:
+		flags.Usage()
+		return fmt.Errorf("invalid migrate command %q", strings.Join(args, " "))
+	}
+
+	for _, migration := range ran {
+		if migration.Version > before {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		} else {
+			fmt.Printf("rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema is at version %d of %d\n", version, migrator.Latest())
+	return nil
+}
+
+func printMigrationStatus(migrator *database.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		state := "pending"
+		switch {
+		case status.AppliedAt == nil:
+		case status.AppliedAt.IsZero():
+			state = "applied (existing schema, recorded on the next migrate up)"
+		default:
+			state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d_%s  %s\n", status.Version, status.Name, state)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Appointment states
+const (
+	AppointmentBooked     = "booked"
+	AppointmentCheckedIn  = "checked_in"
+	AppointmentInProgress = "in_progress"
+	AppointmentCompleted  = "completed"
+	AppointmentNoShow     = "no_show"
+	AppointmentCancelled  = "cancelled"
+)
+
+// AppointmentTypes lists the kinds of visit that can be booked
+var AppointmentTypes = []string{"consultation", "follow_up", "procedure", "telehealth"}
+
+// Appointment represents a scheduled visit of a patient with a doctor
+type Appointment struct {
+	gorm.Model
+	PatientID    uint      `gorm:"not null;index"`
+	Patient      *Patient  `json:",omitempty"`
+	DoctorID     uint      `gorm:"not null;index:idx_appointments_doctor_start"`
+	StartTime    time.Time `gorm:"not null;index:idx_appointments_doctor_start"`
+	EndTime      time.Time `gorm:"not null"`
+	Type         string    `gorm:"not null"`
+	State        string    `gorm:"not null;default:'booked'"`
+	Reason       string    // Reason for the visit
+	CancelReason string
+}
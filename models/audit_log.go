@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditRead    = "read"
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditMerge   = "merge"
+	AuditUnmerge = "unmerge"
+)
+
+// ErrAuditLogImmutable is returned by the hooks that stop audit entries from being changed
+var ErrAuditLogImmutable = errors.New("audit log entries cannot be modified or deleted")
+
+// AuditLog is one append-only entry recording access to or a change of patient data.
+// Each entry stores the hash of the previous one, so editing or removing a row breaks the chain.
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"not null;index"`
+	Actor     string    `gorm:"not null;index"` // username
+	Role      string
+	IP        string
+	Method    string
+	Route     string
+	Action    string `gorm:"not null;index"`
+	PatientID *uint  `gorm:"index"`
+	Changes   string `gorm:"type:text"` // JSON object of field => {"before": ..., "after": ...}
+	PrevHash  string `gorm:"not null"`
+	Hash      string `gorm:"not null;uniqueIndex"`
+}
+
+// BeforeUpdate keeps audit entries immutable
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete keeps audit entries immutable
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
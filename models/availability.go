@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DoctorAvailability is a recurring weekly block of working hours for a doctor.
+// Times are "HH:MM" wall-clock values in the server's time zone.
+type DoctorAvailability struct {
+	gorm.Model
+	DoctorID    uint   `gorm:"not null;index"`
+	Weekday     int    `gorm:"not null"` // 0 = Sunday ... 6 = Saturday
+	StartTime   string `gorm:"not null"` // e.g. "09:00"
+	EndTime     string `gorm:"not null"` // e.g. "17:00"
+	SlotMinutes int    `gorm:"not null;default:30"`
+}
+
+// AvailabilityException blocks out part of a doctor's working hours (leave, holidays, training)
+type AvailabilityException struct {
+	gorm.Model
+	DoctorID  uint      `gorm:"not null;index"`
+	StartTime time.Time `gorm:"not null"`
+	EndTime   time.Time `gorm:"not null"`
+	Reason    string
+}
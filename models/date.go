@@ -0,0 +1,113 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DateLayout is the format of dates in requests, responses and the database
+const DateLayout = "2006-01-02"
+
+// MaxPatientAge bounds how far in the past a date of birth may be, in years
+const MaxPatientAge = 150
+
+// Date is a calendar day without a time of day or time zone, such as a date of birth.
+// It is stored as an SQL date and written as "YYYY-MM-DD" in JSON.
+type Date struct {
+	time.Time
+}
+
+// ParseDate parses a "YYYY-MM-DD" date
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, err
+	}
+	return Date{t}, nil
+}
+
+// NewDate returns the date of the given day
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// Today returns the current date in the local time zone
+func Today() Date {
+	now := time.Now()
+	return NewDate(now.Year(), now.Month(), now.Day())
+}
+
+// CheckBirthDate rejects dates of birth in the future or more than MaxPatientAge years ago
+func (d Date) CheckBirthDate() error {
+	today := Today()
+	if d.After(today.Time) {
+		return errors.New("cannot be in the future")
+	}
+	if d.Before(today.AddDate(-MaxPatientAge, 0, 0)) {
+		return fmt.Errorf("cannot be more than %d years ago", MaxPatientAge)
+	}
+	return nil
+}
+
+// String returns the date as "YYYY-MM-DD"
+func (d Date) String() string {
+	return d.Format(DateLayout)
+}
+
+// MarshalJSON writes the date as "YYYY-MM-DD"
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a "YYYY-MM-DD" date
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	*d = parsed
+	return nil
+}
+
+// Value stores the date as "YYYY-MM-DD"
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan reads a date column, which drivers return either as a time or as text
+func (d *Date) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		*d = NewDate(v.Year(), v.Month(), v.Day())
+		return nil
+	case string:
+		return d.scanText(v)
+	case []byte:
+		return d.scanText(string(v))
+	}
+	return fmt.Errorf("cannot scan %T into a date", value)
+}
+
+func (d *Date) scanText(value string) error {
+	if len(value) > len(DateLayout) {
+		value = value[:len(DateLayout)]
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// GormDataType makes GORM create date columns
+func (Date) GormDataType() string {
+	return "date"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Encounter note states
+const (
+	NoteDraft  = "draft"
+	NoteSigned = "signed"
+)
+
+// EncounterNote is a structured SOAP note written by a doctor about a patient.
+// A signed note is never changed; corrections are stored as a new note whose
+// AmendsID points at the note it amends, so the full history is kept.
+type EncounterNote struct {
+	gorm.Model
+	PatientID       uint   `gorm:"not null;index"`
+	AppointmentID   *uint  `gorm:"index"` // Visit the note was written for, if any
+	AuthorID        uint   `gorm:"not null"`
+	AuthorUsername  string `gorm:"not null"`
+	Subjective      string `gorm:"type:text"`
+	Objective       string `gorm:"type:text"`
+	Assessment      string `gorm:"type:text"`
+	Plan            string `gorm:"type:text"`
+	State           string `gorm:"not null;default:'draft'"`
+	SignedAt        *time.Time
+	AmendsID        *uint `gorm:"index"`
+	AmendmentReason string
+}
@@ -0,0 +1,62 @@
+package models
+
+// Field access levels used by field visibility rules
+const (
+	FieldVisible = "visible" // the value is returned as is
+	FieldMasked  = "masked"  // only the last characters are returned
+	FieldHidden  = "hidden"  // the field is left out
+)
+
+// PatientFields maps the configurable patient fields to their JSON keys in responses.
+// Fields not listed here (ID, MRN, timestamps) are always visible.
+var PatientFields = map[string]string{
+	"first_name":         "FirstName",
+	"last_name":          "LastName",
+	"dob":                "DOB",
+	"gender":             "Gender",
+	"phone":              "Phone",
+	"secondary_phone":    "SecondaryPhone",
+	"email":              "Email",
+	"address":            "Address",
+	"emergency_contacts": "EmergencyContacts",
+	"doctor_notes":       "DoctorNotes",
+	"status":             "Status",
+}
+
+// PatientFieldWritePermissions is the permission needed to change each patient field.
+// Demographics belong to the front desk, clinical fields to clinicians. Status is not
+// patched directly; it changes through the status workflow.
+var PatientFieldWritePermissions = map[string]string{
+	"first_name":         PermPatientWrite,
+	"last_name":          PermPatientWrite,
+	"dob":                PermPatientWrite,
+	"gender":             PermPatientWrite,
+	"phone":              PermPatientWrite,
+	"secondary_phone":    PermPatientWrite,
+	"email":              PermPatientWrite,
+	"address":            PermPatientWrite,
+	"emergency_contacts": PermPatientWrite,
+	"doctor_notes":       PermPatientClinical,
+}
+
+// DefaultPatientFieldAccess applies to roles without a rule for the field. Clinical
+// fields are hidden unless a role is explicitly allowed to see them.
+var DefaultPatientFieldAccess = map[string]string{
+	"doctor_notes": FieldHidden,
+}
+
+// BuiltInFieldRules are created on startup for the built-in roles when missing
+var BuiltInFieldRules = map[string]map[string]string{
+	RoleDoctor: {"doctor_notes": FieldVisible},
+	RoleAdmin: {
+		"phone": FieldMasked, "secondary_phone": FieldMasked, "email": FieldMasked,
+		"address": FieldMasked, "dob": FieldMasked, "emergency_contacts": FieldMasked,
+	},
+}
+
+// FieldVisibility controls how one patient field is shown to users of a role
+type FieldVisibility struct {
+	Role   string `gorm:"primaryKey"`
+	Field  string `gorm:"primaryKey"`
+	Access string `gorm:"not null"` // FieldVisible, FieldMasked or FieldHidden
+}
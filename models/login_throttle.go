@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one username or one client IP
+type LoginThrottle struct {
+	Key         string `gorm:"primaryKey"` // "user:<username>" or "ip:<address>"
+	Failures    int    `gorm:"not null;default:0"`
+	LastFailure time.Time
+	LockedUntil *time.Time
+	UpdatedAt   time.Time
+}
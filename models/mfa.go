@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a one-time code that replaces a TOTP code when the authenticator is lost.
+// Only the SHA-256 of the code is stored.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"not null"`
+	UsedAt   *time.Time
+}
+
+// MFAPolicy says whether users of a role must use two-factor authentication
+type MFAPolicy struct {
+	Role      string `gorm:"primaryKey"`
+	Required  bool   `gorm:"not null;default:false"`
+	UpdatedAt time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// PasswordHistory keeps the bcrypt hashes of a user's previous passwords so they cannot be reused
+type PasswordHistory struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"not null;index"`
+	Hash      string `gorm:"not null"`
+	CreatedAt time.Time
+}
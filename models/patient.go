@@ -1,14 +1,70 @@
 package models
+
 import "gorm.io/gorm"
+
+// Patient statuses. Changes follow the workflow in services.patientTransitions.
+const (
+	PatientRegistered     = "registered"
+	PatientAdmitted       = "admitted"
+	PatientUnderTreatment = "under_treatment"
+	PatientDischarged     = "discharged"
+	PatientDeceased       = "deceased"
+	PatientArchived       = "archived"
+)
+
+// PatientStatuses lists every status a patient can have
+var PatientStatuses = []string{PatientRegistered, PatientAdmitted, PatientUnderTreatment, PatientDischarged, PatientDeceased, PatientArchived}
+
+// Patient genders
+const (
+	GenderFemale  = "female"
+	GenderMale    = "male"
+	GenderOther   = "other"
+	GenderUnknown = "unknown"
+)
+
+// Genders is the controlled vocabulary for Patient.Gender (HL7 FHIR administrative gender)
+var Genders = []string{GenderFemale, GenderMale, GenderOther, GenderUnknown}
+
 // Patient represents a patient in the system
 type Patient struct {
 	gorm.Model
-	FirstName   string `gorm:"not null"`
-	LastName    string `gorm:"not null"`
-	DOB         string // Date of Birth (e.g., "YYYY-MM-DD")
-	Gender      string
-	Contact     string `gorm:"unique"` // Phone number or email
-	Address     string
-	DoctorNotes string `gorm:"type:text"` // For doctors to update
-	Status      string `gorm:"default:'active'"` // e.g., "active", "discharged"
-}
\ No newline at end of file
+	MRN               string `gorm:"size:16;uniqueIndex:idx_patients_mrn,where:mrn <> ''"` // Medical record number, assigned on registration and never changed
+	FirstName         string `gorm:"not null"`
+	LastName          string `gorm:"not null"`
+	DOB               *Date  `gorm:"column:birth_date"` // Date of birth, never in the future
+	Gender            string `gorm:"default:'unknown'"` // One of Genders
+	Phone             string // Normalized: digits with an optional leading "+"
+	SecondaryPhone    string
+	Email             string
+	Address           Address            `gorm:"embedded;embeddedPrefix:address_"`
+	EmergencyContacts []EmergencyContact // Only loaded for single patients
+	DoctorNotes       string             `gorm:"type:text"`            // For doctors to update
+	Status            string             `gorm:"default:'registered'"` // One of PatientStatuses
+	Version           uint               `gorm:"not null;default:1"`   // Incremented on every update, used as the ETag
+}
+
+// Address is a patient's postal address
+type Address struct {
+	Line1      string
+	Line2      string
+	City       string
+	State      string
+	PostalCode string
+	Country    string
+}
+
+// IsZero reports whether no part of the address is set
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+// EmergencyContact is a person to call about a patient
+type EmergencyContact struct {
+	ID           uint   `gorm:"primarykey"`
+	PatientID    uint   `gorm:"not null;index"`
+	Name         string `gorm:"not null"`
+	Relationship string
+	Phone        string
+	Email        string
+}
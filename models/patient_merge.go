@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PatientMerge records that the source patient was merged into the target patient, with
+// what changed so the merge can be undone
+type PatientMerge struct {
+	ID           uint      `gorm:"primaryKey"`
+	TargetID     uint      `gorm:"not null;index"` // Surviving patient
+	SourceID     uint      `gorm:"not null;index"` // Duplicate that was folded into the target and deleted
+	MergedBy     string    `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"index"`
+	FieldChanges string    `gorm:"type:text"` // JSON object of target field => {"before": ..., "after": ...}
+	Moved        string    `gorm:"type:text"` // JSON object of related records moved from source to target
+	UnmergedAt   *time.Time
+	UnmergedBy   string
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PatientStatusChange is one entry of a patient's status history
+type PatientStatusChange struct {
+	ID               uint      `gorm:"primaryKey"`
+	PatientID        uint      `gorm:"not null;index"`
+	FromStatus       string    `gorm:"not null"`
+	ToStatus         string    `gorm:"not null"`
+	Reason           string    `gorm:"type:text"`
+	DischargeSummary string    `gorm:"type:text"`
+	ChangedBy        string    `gorm:"not null"` // Username of the user who made the change
+	CreatedAt        time.Time `gorm:"index"`
+}
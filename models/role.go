@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Permissions checked by the API. Roles are sets of these.
+const (
+	PermPatientRead       = "patient:read"       // list, search and read patients
+	PermPatientWrite      = "patient:write"      // register patients and edit their details
+	PermPatientDelete     = "patient:delete"     // delete patients
+	PermPatientClinical   = "patient:clinical"   // update doctor notes and clinical status
+	PermPatientAdmin      = "patient:admin"      // list, restore and purge deleted patients, merge duplicates
+	PermNotesRead         = "notes:read"         // read encounter notes
+	PermNotesWrite        = "notes:write"        // write, sign and amend encounter notes
+	PermAppointmentWrite  = "appointment:write"  // book, reschedule, cancel, check in
+	PermAppointmentAttend = "appointment:attend" // have a schedule, start and complete own visits
+	PermAvailability      = "availability:write" // manage own working hours and leave
+	PermUserAdmin         = "user:admin"         // manage users, roles and security settings
+	PermAuditRead         = "audit:read"         // read and export the audit log
+)
+
+// Permissions lists every permission a role can be given
+var Permissions = []string{
+	PermPatientRead, PermPatientWrite, PermPatientDelete, PermPatientClinical, PermPatientAdmin,
+	PermNotesRead, PermNotesWrite,
+	PermAppointmentWrite, PermAppointmentAttend, PermAvailability,
+	PermUserAdmin, PermAuditRead,
+}
+
+// BuiltInRoles are created on startup when missing. Their permissions can be edited but
+// the roles cannot be deleted.
+var BuiltInRoles = map[string][]string{
+	RoleAdmin:        {PermUserAdmin, PermAuditRead, PermPatientRead, PermPatientAdmin},
+	RoleReceptionist: {PermPatientRead, PermPatientWrite, PermPatientDelete, PermAppointmentWrite},
+	RoleDoctor:       {PermPatientRead, PermPatientClinical, PermNotesRead, PermNotesWrite, PermAppointmentAttend, PermAvailability},
+}
+
+// Role is a named set of permissions assigned to users
+type Role struct {
+	Name        string `gorm:"primaryKey"`
+	Description string
+	BuiltIn     bool             `gorm:"not null;default:false"`
+	Permissions []RolePermission `gorm:"foreignKey:RoleName;references:Name;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// RolePermission grants one permission to a role
+type RolePermission struct {
+	RoleName   string `gorm:"primaryKey"`
+	Permission string `gorm:"primaryKey"`
+}
+
+// PermissionNames returns the role's permissions as strings
+func (r *Role) PermissionNames() []string {
+	names := make([]string, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		names = append(names, p.Permission)
+	}
+	return names
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is a server-side refresh token. Only the SHA-256 of the token is stored.
+// Every refresh replaces the token with a new one in the same family; presenting a
+// token that was already used revokes the whole family.
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	FamilyID  string    `gorm:"not null;index"` // Shared by every token rotated from the same login
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// RevokedToken is an access token revoked before it expired, identified by its jti claim
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"` // The row can be purged after this
+	CreatedAt time.Time
+}
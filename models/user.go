@@ -2,10 +2,27 @@ package models
 
 import "gorm.io/gorm"
 
-// User represents a user in the system (receptionist or doctor)
+// Built-in roles, see BuiltInRoles. Admins can add custom roles.
+const (
+	RoleAdmin        = "admin"
+	RoleDoctor       = "doctor"
+	RoleReceptionist = "receptionist"
+)
+
+// User represents a user in the system (admin, receptionist, doctor or a custom role)
 type User struct {
 	gorm.Model
 	Username string `gorm:"unique;not null"`
-	Password string `gorm:"not null"`
-	Role     string `gorm:"not null"` // "receptionist" or "doctor"
-}
\ No newline at end of file
+	Password string `gorm:"not null" json:"-"` // bcrypt hash, never serialized
+	Role     string `gorm:"not null"` // name of a Role
+	Disabled bool   `gorm:"not null;default:false"`
+
+	// MustChangePassword blocks everything but changing the password, set for bootstrapped
+	// accounts and after an admin reset
+	MustChangePassword bool `gorm:"not null;default:false"`
+
+	// TOTP two-factor authentication
+	MFAEnabled  bool   `gorm:"not null;default:false"`
+	MFASecret   string `json:"-"` // base32 secret, set once enrollment starts
+	MFALastStep int64  `json:"-"` // last accepted time step, so a code cannot be replayed
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"medical_app/models"
+	"medical_app/services"
+	"os"
+)
+
+// exportBatchSize is how many patients are audited and written at a time
+const exportBatchSize = 100
+
+// runExport writes every patient that is not deleted, with their emergency contacts, as
+// one JSON object per line. Each patient is audited as read before it is written.
+func runExport(args []string) error {
+	flags := newFlagSet("export")
+	output := flags.String("o", "", "file to write (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	_, db, err := openDatabase(true)
+	if err != nil {
+		return err
+	}
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+	auditService := services.NewAuditService(db)
+
+	var pending []models.Patient
+	exported := 0
+	flush := func() error {
+		ids := make([]uint, len(pending))
+		for i, patient := range pending {
+			ids[i] = patient.ID
+		}
+		if err := auditService.RecordReads(cliActor("export"), ids); err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		for i := range pending {
+			if err := encoder.Encode(&pending[i]); err != nil {
+				return err
+			}
+		}
+		exported += len(pending)
+		pending = pending[:0]
+		return nil
+	}
+
+	err = services.NewPatientService(db).ExportPatients(func(patient *models.Patient) error {
+		pending = append(pending, *patient)
+		if len(pending) < exportBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d patients\n", exported)
+	return nil
+}
+
+// runImport creates a patient for every line written by export. Each patient is created
+// and audited in one transaction; patients that fail, e.g. because their medical record
+// number is already in use, are reported and skipped.
+func runImport(args []string) error {
+	flags := newFlagSet("import")
+	input := flags.String("i", "", "file to read (default stdin)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, db, err := openDatabase(true)
+	if err != nil {
+		return err
+	}
+	in := os.Stdin
+	if *input != "" {
+		if in, err = os.Open(*input); err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+
+	unitOfWork := services.NewUnitOfWork(db, cfg)
+	decoder := json.NewDecoder(bufio.NewReader(in))
+	imported, failed := 0, 0
+	for record := 1; ; record++ {
+		var patient models.Patient
+		if err := decoder.Decode(&patient); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("record %d: %w", record, err)
+		}
+		name := patient.FirstName + " " + patient.LastName
+		err := unitOfWork.Do(func(tx *services.Services) error {
+			if err := tx.Patients.ImportPatient(&patient); err != nil {
+				return err
+			}
+			return tx.Audit.Record(cliActor("import"), models.AuditCreate, patient.ID, nil, &patient)
+		})
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "record %d (%s): %v\n", record, name, err)
+			continue
+		}
+		imported++
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %d patients\n", imported)
+	if failed > 0 {
+		return fmt.Errorf("%d patients were not imported", failed)
+	}
+	return nil
+}
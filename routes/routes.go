@@ -4,48 +4,156 @@ import (
 	"medical_app/config"
 	"medical_app/controllers"
 	"medical_app/middlewares"
+	"medical_app/models"
+	"medical_app/services"
+	"medical_app/utils"
+	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes configures all application routes
-func SetupRoutes(router *gin.Engine, authCtrl *controllers.AuthController, patientCtrl *controllers.PatientController, cfg *config.Config) {
+func SetupRoutes(router *gin.Engine, authCtrl *controllers.AuthController, patientCtrl *controllers.PatientController, appointmentCtrl *controllers.AppointmentController, availabilityCtrl *controllers.AvailabilityController, noteCtrl *controllers.EncounterNoteController, auditCtrl *controllers.AuditController, userCtrl *controllers.UserController, mfaCtrl *controllers.MFAController, roleCtrl *controllers.RoleController, tokenSvc services.TokenService, roleSvc services.RoleService, cfg *config.Config) {
 
 	// API Routes
 	api := router.Group("/api")
 	{
 		api.POST("/login", authCtrl.Login)
-		api.POST("/register", authCtrl.RegisterUser) 
+		api.POST("/login/mfa", authCtrl.LoginMFA)
+		api.POST("/login/mfa/enroll", authCtrl.LoginMFAEnroll)
+		api.POST("/login/mfa/enroll/confirm", authCtrl.LoginMFAConfirm)
+		api.POST("/token/refresh", authCtrl.RefreshToken)
 	}
 
 	// Authenticated routes
 	authenticated := api.Group("/")
-	authenticated.Use(middlewares.AuthMiddleware(cfg))
+	authenticated.Use(middlewares.AuthMiddleware(cfg, tokenSvc, roleSvc))
 	{
+		authenticated.POST("/logout", authCtrl.Logout)
+		authenticated.POST("/password", authCtrl.ChangePassword)
+	}
+
+	// Access is granted per permission. The /receptionist, /doctor and /admin prefixes are
+	// kept for existing clients; custom roles with the right permissions can use them too.
+	can := middlewares.RequirePermission
+
+	// Everything else is blocked until a required password change is done
+	active := authenticated.Group("/")
+	active.Use(middlewares.RequirePasswordChanged())
+	{
+		// Two-factor authentication of the logged in user
+		active.POST("/mfa/enroll", mfaCtrl.BeginEnrollment)
+		active.POST("/mfa/confirm", mfaCtrl.ConfirmEnrollment)
+		active.POST("/mfa/disable", mfaCtrl.Disable)
+		active.POST("/mfa/recovery-codes", mfaCtrl.RegenerateRecoveryCodes)
+
 		// Patient routes (common for receptionist and doctor to view)
-		authenticated.GET("/patients", patientCtrl.GetAllPatients)
-		authenticated.GET("/patients/:id", patientCtrl.GetPatientByID)
+		active.GET("/patients", can(models.PermPatientRead), patientCtrl.GetAllPatients)
+		active.GET("/patients/search", can(models.PermPatientRead), patientCtrl.SearchPatients)
+		active.POST("/patients/duplicates", can(models.PermPatientWrite), patientCtrl.CheckDuplicates) // before registering
+		active.GET("/patients/:id/duplicates", can(models.PermPatientRead), patientCtrl.GetPatientDuplicates)
+		active.GET("/patients/:id", can(models.PermPatientRead), patientCtrl.GetPatientByID)
+		active.PATCH("/patients/:id", can(models.PermPatientRead), patientCtrl.PatchPatient) // write permissions are checked per field
+		active.PUT("/patients/:id/emergency-contacts", can(models.PermPatientWrite), patientCtrl.ReplaceEmergencyContacts)
+		active.POST("/patients/:id/status", patientCtrl.ChangeStatus) // permissions are checked per transition
+		active.GET("/patients/:id/status-history", can(models.PermPatientRead), patientCtrl.GetStatusHistory)
 
-		// Receptionist specific routes
-		receptionist := authenticated.Group("/receptionist")
-		receptionist.Use(middlewares.AuthorizeRoles("receptionist"))
+		// Doctor directory and free slots (used by receptionists when booking)
+		active.GET("/doctors", availabilityCtrl.ListDoctors)
+		active.GET("/doctors/:id/slots", availabilityCtrl.GetFreeSlots)
+
+		// Receptionist routes
+		receptionist := active.Group("/receptionist")
 		{
-			receptionist.POST("/patients", patientCtrl.CreatePatient)
-			receptionist.PUT("/patients/:id", patientCtrl.UpdatePatient) // Receptionist can update most patient details
-			receptionist.DELETE("/patients/:id", patientCtrl.DeletePatient)
+			receptionist.POST("/patients", can(models.PermPatientWrite), patientCtrl.CreatePatient)
+			receptionist.PUT("/patients/:id", can(models.PermPatientWrite), patientCtrl.PatchPatient) // same merge semantics as PATCH /patients/:id
+			receptionist.DELETE("/patients/:id", can(models.PermPatientDelete), patientCtrl.DeletePatient)
+
+			receptionist.GET("/appointments", can(models.PermAppointmentWrite), appointmentCtrl.GetDoctorSchedule)
+			receptionist.POST("/appointments", can(models.PermAppointmentWrite), appointmentCtrl.BookAppointment)
+			receptionist.PUT("/appointments/:id", can(models.PermAppointmentWrite), appointmentCtrl.RescheduleAppointment)
+			receptionist.POST("/appointments/:id/cancel", can(models.PermAppointmentWrite), appointmentCtrl.CancelAppointment)
+			receptionist.PUT("/appointments/:id/state", can(models.PermAppointmentWrite), appointmentCtrl.UpdateAppointmentState) // check-in / no-show
 		}
 
-		// Doctor specific routes
-		doctor := authenticated.Group("/doctor")
-		doctor.Use(middlewares.AuthorizeRoles("doctor"))
+		// Doctor routes
+		doctor := active.Group("/doctor")
 		{
 			// Doctor can only update doctor_notes and status
-			doctor.PUT("/patients/:id/notes", patientCtrl.UpdatePatientDoctorNotes)
+			doctor.PUT("/patients/:id/notes", can(models.PermPatientClinical), patientCtrl.UpdatePatientDoctorNotes)
+
+			// Structured SOAP encounter notes, append-only once signed
+			doctor.GET("/patients/:id/encounters", can(models.PermNotesRead), noteCtrl.ListNotes)
+			doctor.POST("/patients/:id/encounters", can(models.PermNotesWrite), noteCtrl.CreateNote)
+			doctor.GET("/encounters/:id", can(models.PermNotesRead), noteCtrl.GetNote)
+			doctor.PUT("/encounters/:id", can(models.PermNotesWrite), noteCtrl.UpdateDraft)
+			doctor.POST("/encounters/:id/sign", can(models.PermNotesWrite), noteCtrl.SignNote)
+			doctor.POST("/encounters/:id/amend", can(models.PermNotesWrite), noteCtrl.AmendNote)
+
+			doctor.GET("/appointments", can(models.PermAppointmentAttend), appointmentCtrl.GetMySchedule)
+			doctor.PUT("/appointments/:id/state", can(models.PermAppointmentAttend), appointmentCtrl.UpdateAppointmentState) // start / complete
+
+			doctor.GET("/availability", can(models.PermAvailability), availabilityCtrl.GetMyAvailability)
+			doctor.PUT("/availability", can(models.PermAvailability), availabilityCtrl.SetMyAvailability)
+			doctor.GET("/availability/exceptions", can(models.PermAvailability), availabilityCtrl.ListMyExceptions)
+			doctor.POST("/availability/exceptions", can(models.PermAvailability), availabilityCtrl.AddMyException)
+			doctor.DELETE("/availability/exceptions/:id", can(models.PermAvailability), availabilityCtrl.DeleteMyException)
+		}
+
+		// Admin routes
+		admin := active.Group("/admin")
+		{
+			// User management (registration is admin-only)
+			users := admin.Group("/", can(models.PermUserAdmin))
+			users.GET("/users", userCtrl.ListUsers)
+			users.POST("/users", userCtrl.RegisterUser)
+			users.GET("/users/:id", userCtrl.GetUser)
+			users.PUT("/users/:id/role", userCtrl.ChangeRole)
+			users.POST("/users/:id/disable", userCtrl.DisableUser)
+			users.POST("/users/:id/enable", userCtrl.EnableUser)
+			users.POST("/users/:id/reset-password", userCtrl.ResetPassword)
+			users.DELETE("/users/:id", userCtrl.DeleteUser)
+			users.POST("/users/:id/unlock", userCtrl.UnlockUser)
+			users.GET("/lockouts", userCtrl.ListLockouts)
+			users.POST("/lockouts/unlock-ip", userCtrl.UnlockIP)
+			users.POST("/users/:id/reset-mfa", mfaCtrl.ResetUserMFA)
+			users.GET("/mfa-policy", mfaCtrl.ListPolicies)
+			users.PUT("/mfa-policy", mfaCtrl.SetPolicy)
+
+			// Roles and permissions
+			users.GET("/permissions", roleCtrl.ListPermissions)
+			users.GET("/roles", roleCtrl.ListRoles)
+			users.POST("/roles", roleCtrl.CreateRole)
+			users.GET("/roles/:name", roleCtrl.GetRole)
+			users.PUT("/roles/:name", roleCtrl.UpdateRole)
+			users.DELETE("/roles/:name", roleCtrl.DeleteRole)
+			users.GET("/roles/:name/patient-fields", roleCtrl.GetPatientFields)
+			users.PUT("/roles/:name/patient-fields", roleCtrl.SetPatientFields)
+
+			// Deleted patients
+			admin.GET("/patients/deleted", can(models.PermPatientAdmin), patientCtrl.ListDeletedPatients)
+			admin.POST("/patients/:id/restore", can(models.PermPatientAdmin), patientCtrl.RestorePatient)
+			admin.DELETE("/patients/:id/purge", can(models.PermPatientAdmin), patientCtrl.PurgePatient)
+
+			// Merging duplicates
+			admin.GET("/patients/merges", can(models.PermPatientAdmin), patientCtrl.ListMerges)
+			admin.POST("/patients/:id/merge", can(models.PermPatientAdmin), patientCtrl.MergePatients)
+			admin.POST("/patients/merges/:id/unmerge", can(models.PermPatientAdmin), patientCtrl.UnmergePatients)
+
+			admin.GET("/audit", can(models.PermAuditRead), auditCtrl.ListEntries)
+			admin.GET("/audit/export", can(models.PermAuditRead), auditCtrl.ExportEntries)
+			admin.GET("/audit/verify", can(models.PermAuditRead), auditCtrl.VerifyChain)
 		}
 	}
 
 	// Catch-all route for React client-side routing.
+	// Unknown API paths get a problem details response instead of the client.
 	router.NoRoute(func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			utils.AbortWithProblem(c, http.StatusNotFound, "route_not_found", "No API route for "+c.Request.Method+" "+c.Request.URL.Path, nil)
+			return
+		}
 		c.File("./index.html") 
 	})
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"medical_app/models"
+	"medical_app/services"
+	"time"
+)
+
+// Demo accounts created by seed
+const (
+	demoDoctor       = "demo.doctor"
+	demoReceptionist = "demo.receptionist"
+)
+
+// runSeed fills an empty database with demo data: a doctor and a receptionist sharing one
+// password (read from stdin with -password-stdin, otherwise generated and printed), the
+// doctor's working hours, a few patients and appointments. Everything is created in one
+// transaction, and nothing when the database already has patients.
+func runSeed(args []string) error {
+	flags := newFlagSet("seed")
+	passwordStdin := flags.Bool("password-stdin", false, "read the demo users' password from stdin instead of generating one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, db, err := openDatabase(true)
+	if err != nil {
+		return err
+	}
+	if err := seedBuiltIns(db); err != nil {
+		return err
+	}
+	var patients int64
+	if err := db.Model(&models.Patient{}).Unscoped().Count(&patients).Error; err != nil {
+		return err
+	}
+	if patients > 0 {
+		return errors.New("the database already has patients; seed is meant for an empty database")
+	}
+	password, generated, err := readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	actor := cliActor("seed")
+	var booked int
+	err = services.NewUnitOfWork(db, cfg).Do(func(tx *services.Services) error {
+		doctor := &models.User{Username: demoDoctor, Password: password, Role: models.RoleDoctor}
+		if err := tx.Users.RegisterUser(doctor); err != nil {
+			return fmt.Errorf("creating %s: %w", demoDoctor, err)
+		}
+		receptionist := &models.User{Username: demoReceptionist, Password: password, Role: models.RoleReceptionist}
+		if err := tx.Users.RegisterUser(receptionist); err != nil {
+			return fmt.Errorf("creating %s: %w", demoReceptionist, err)
+		}
+
+		var hours []models.DoctorAvailability
+		for weekday := time.Monday; weekday <= time.Friday; weekday++ {
+			hours = append(hours, models.DoctorAvailability{Weekday: int(weekday), StartTime: "09:00", EndTime: "17:00", SlotMinutes: 30})
+		}
+		if err := tx.Availability.SetWeeklyAvailability(doctor.ID, hours); err != nil {
+			return err
+		}
+
+		demo := demoPatients()
+		for i := range demo {
+			if err := tx.Patients.CreatePatient(&demo[i]); err != nil {
+				return fmt.Errorf("creating patient %s %s: %w", demo[i].FirstName, demo[i].LastName, err)
+			}
+			if err := tx.Audit.Record(actor, models.AuditCreate, demo[i].ID, nil, &demo[i]); err != nil {
+				return err
+			}
+		}
+
+		// Book every other free slot of the coming week for the first patients
+		from := time.Now().Truncate(time.Hour).Add(time.Hour)
+		slots, err := tx.Availability.FreeSlots(doctor.ID, from, from.AddDate(0, 0, 7))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 3 && 2*i < len(slots); i++ {
+			appointment := &models.Appointment{
+				PatientID: demo[i].ID,
+				DoctorID:  doctor.ID,
+				StartTime: slots[2*i].Start,
+				EndTime:   slots[2*i].End,
+				Type:      models.AppointmentTypes[i%len(models.AppointmentTypes)],
+				Reason:    "Demo appointment",
+			}
+			if err := tx.Appointments.BookAppointment(appointment); err != nil {
+				return err
+			}
+			booked++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created users %q (doctor) and %q (receptionist), %d patients and %d appointments\n", demoDoctor, demoReceptionist, len(demoPatients()), booked)
+	if generated {
+		fmt.Printf("Password of both users: %s\n", password)
+	}
+	return nil
+}
+
+// demoPatients returns the patients seed creates
+func demoPatients() []models.Patient {
+	dob := func(year int, month time.Month, day int) *models.Date {
+		date := models.NewDate(year, month, day)
+		return &date
+	}
+	return []models.Patient{
+		{FirstName: "Ada", LastName: "Lovelace", DOB: dob(1985, time.December, 10), Gender: models.GenderFemale, Phone: "+44 20 7946 0101", Email: "ada.lovelace@example.com",
+			Address:           models.Address{Line1: "12 St James's Square", City: "London", PostalCode: "SW1Y 4JH", Country: "GB"},
+			EmergencyContacts: []models.EmergencyContact{{Name: "William King", Relationship: "spouse", Phone: "+44 20 7946 0102"}}},
+		{FirstName: "Alan", LastName: "Turing", DOB: dob(1992, time.June, 23), Gender: models.GenderMale, Phone: "+44 161 496 0103",
+			Address: models.Address{Line1: "Hollymeade, Adlington Road", City: "Wilmslow", PostalCode: "SK9 2BT", Country: "GB"}},
+		{FirstName: "Grace", LastName: "Hopper", DOB: dob(1976, time.December, 9), Gender: models.GenderFemale, Email: "grace.hopper@example.com",
+			Address: models.Address{Line1: "1 Navy Way", City: "Arlington", State: "VA", PostalCode: "22202", Country: "US"}},
+		{FirstName: "Edsger", LastName: "Dijkstra", DOB: dob(1960, time.May, 11), Gender: models.GenderMale, Phone: "+31 20 794 0104"},
+		{FirstName: "Barbara", LastName: "Liskov", DOB: dob(1999, time.November, 7), Gender: models.GenderFemale, Phone: "+1 617 555 0105", SecondaryPhone: "+1 617 555 0106"},
+		{FirstName: "Sam", LastName: "Rivera", DOB: dob(2015, time.March, 2), Gender: models.GenderUnknown, Phone: "+1 415 555 0107",
+			EmergencyContacts: []models.EmergencyContact{{Name: "Alex Rivera", Relationship: "parent", Phone: "+1 415 555 0108"}}},
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"medical_app/config"
+	"medical_app/controllers"
+	"medical_app/db"
+	"medical_app/middlewares"
+	"medical_app/models"
+	"medical_app/routes"
+	"medical_app/services"
+	"medical_app/utils"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// runServe starts the API server and its background jobs
+func runServe(args []string) error {
+	if err := newFlagSet("serve").Parse(args); err != nil {
+		return err
+	}
+	cfg, db, err := openDatabase(true)
+	if err != nil {
+		return err
+	}
+	replica := database.InitReplica(cfg, db)
+
+	// services
+	if err := seedBuiltIns(db); err != nil {
+		return err
+	}
+	roleService := services.NewRoleService(db)
+	fieldService := services.NewFieldVisibilityService(db)
+
+	// Initial admin account (for first setup)
+	if cfg.FeatureBootstrapAdmin {
+		bootstrapAdmin(db, cfg)
+	}
+
+	authService := services.NewAuthService(db, cfg)
+	userService := services.NewUserService(db, cfg)
+	patientService := services.NewPatientService(db)
+	appointmentService := services.NewAppointmentService(db)
+	availabilityService := services.NewAvailabilityService(db)
+	noteService := services.NewEncounterNoteService(db)
+	auditService := services.NewAuditService(db)
+	patientService.Replica, auditService.Replica = replica, replica
+	tokenService := services.NewTokenService(db, cfg)
+	mfaService := services.NewMFAService(db)
+	unitOfWork := services.NewUnitOfWork(db, cfg)
+
+	// Controllers
+	authController := controllers.NewAuthController(authService, userService, tokenService, mfaService, cfg)
+	patientController := controllers.NewPatientController(unitOfWork, patientService, auditService, fieldService)
+	appointmentController := controllers.NewAppointmentController(appointmentService, userService, fieldService, auditService)
+	availabilityController := controllers.NewAvailabilityController(availabilityService, userService)
+	noteController := controllers.NewEncounterNoteController(unitOfWork, noteService, userService, auditService)
+	auditController := controllers.NewAuditController(auditService, fieldService)
+	userController := controllers.NewUserController(userService, authService, tokenService)
+	mfaController := controllers.NewMFAController(mfaService, userService)
+	roleController := controllers.NewRoleController(roleService, fieldService)
+
+	// Drop expired revocations and refresh tokens in the background
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := tokenService.PurgeExpired(); err != nil {
+				log.Printf("Failed to purge expired tokens: %v", err)
+			}
+		}
+	}()
+
+	// Purge patients whose deletion is older than the retention period
+	if cfg.FeaturePatientPurge {
+		go func() {
+			for range time.Tick(time.Hour) {
+				purgeExpiredPatients(unitOfWork, cfg.PatientRetention)
+			}
+		}()
+	}
+
+	// Setup Gin Router
+	if cfg.LogLevel != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.Default()
+
+	// CORS middleware
+	router.Use(middlewares.CORS(cfg.CORSAllowedOrigins))
+
+	routes.SetupRoutes(router, authController, patientController, appointmentController, availabilityController, noteController, auditController, userController, mfaController, roleController, tokenService, roleService, cfg)
+
+	// Start Server
+	server := &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      router,
+		ReadTimeout:  cfg.ServerReadTimeout,
+		WriteTimeout: cfg.ServerWriteTimeout,
+		IdleTimeout:  cfg.ServerIdleTimeout,
+	}
+	return listenAndServe(server, cfg.ServerShutdownTimeout)
+}
+
+// listenAndServe runs the server until SIGINT or SIGTERM, then stops accepting requests
+// and waits up to timeout for the ones in progress
+func listenAndServe(server *http.Server, timeout time.Duration) error {
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	failed := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on %s", server.Addr)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			failed <- err
+		}
+	}()
+
+	select {
+	case err := <-failed:
+		return err
+	case <-stop.Done():
+	}
+	log.Println("Shutting down the server")
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), timeout)
+	defer cancelShutdown()
+	return server.Shutdown(ctx)
+}
+
+// bootstrapAdmin creates the initial admin account when no user can manage users yet.
+// The username comes from ADMIN_USERNAME (default "admin") and the password from
+// ADMIN_PASSWORD (or ADMIN_PASSWORD_FILE), which has to meet the password policy; without
+// it a random password is generated and logged once. Either way it must be changed at the
+// first login.
+func bootstrapAdmin(db *gorm.DB, cfg *config.Config) {
+	userService := services.NewUserService(db, cfg)
+	admins, err := userService.ListUsersWithPermission(models.PermUserAdmin)
+	if err != nil {
+		log.Fatalf("Failed to check for admin users: %v", err)
+	}
+	if len(admins) > 0 {
+		log.Println("Admin user already exists.")
+		return
+	}
+
+	username := cfg.AdminUsername
+	password := cfg.AdminPassword
+	generated := password == ""
+	if generated {
+		password = utils.GeneratePassword(20)
+	}
+
+	adminUser := &models.User{
+		Username: username,
+		Password: password, // Will be hashed by service
+		Role:     models.RoleAdmin,
+
+		MustChangePassword: true,
+	}
+	if err := userService.RegisterUser(adminUser); err != nil {
+		log.Fatalf("Failed to bootstrap admin user %q: %v", username, err)
+	}
+	if generated {
+		log.Printf("Bootstrapped admin user %q with generated password: %s (it must be changed at the first login)", username, password)
+	} else {
+		log.Printf("Bootstrapped admin user %q with the password from ADMIN_PASSWORD", username)
+	}
+}
+
+// purgeExpiredPatients permanently removes patients deleted longer than retention ago and
+// audits each purge as the system user. The purges and their audit entries are committed
+// together, so a failed audit leaves the patients for the next run.
+func purgeExpiredPatients(unitOfWork services.UnitOfWork, retention time.Duration) {
+	system := services.AuditActor{Username: "system", Role: "system", Method: "JOB", Route: "patient-retention"}
+	var purged []uint
+	err := unitOfWork.Do(func(tx *services.Services) (err error) {
+		if purged, err = tx.Patients.PurgeExpiredPatients(retention); err != nil {
+			return err
+		}
+		for _, id := range purged {
+			if err := tx.Audit.Record(system, models.AuditPurge, id, nil, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to purge expired patients: %v", err)
+		return
+	}
+	if len(purged) > 0 {
+		log.Printf("Purged %d patients deleted more than %s ago", len(purged), retention)
+	}
+}
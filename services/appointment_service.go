@@ -0,0 +1,223 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"medical_app/models"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAppointmentNotFound is returned when no appointment has the requested ID
+	ErrAppointmentNotFound = newError(KindNotFound, "appointment_not_found", "appointment not found")
+	// ErrDoctorNotFound is returned when the given user does not exist or is not a doctor
+	ErrDoctorNotFound = newError(KindNotFound, "doctor_not_found", "doctor not found")
+	// ErrPatientNotFound is returned when the patient referenced by an appointment does not exist
+	ErrPatientNotFound = newError(KindNotFound, "patient_not_found", "patient not found")
+	// ErrInvalidAppointmentTime is returned when an appointment does not end after it starts
+	ErrInvalidAppointmentTime = newError(KindValidation, "invalid_appointment_time", "appointment must end after it starts")
+	// ErrInvalidAppointmentType is returned for a type outside models.AppointmentTypes
+	ErrInvalidAppointmentType = newError(KindValidation, "invalid_appointment_type", "invalid appointment type")
+	// ErrDoubleBooking is returned when the doctor already has an appointment in the requested time
+	ErrDoubleBooking = newError(KindConflict, "double_booking", "doctor already has an appointment at that time")
+	// ErrInvalidAppointmentTransition is returned when the appointment cannot move to the requested state
+	ErrInvalidAppointmentTransition = newError(KindConflict, "invalid_appointment_transition", "invalid appointment state transition")
+)
+
+// appointmentTransitions lists the states an appointment may move to from each state
+var appointmentTransitions = map[string][]string{
+	models.AppointmentBooked:     {models.AppointmentCheckedIn, models.AppointmentNoShow, models.AppointmentCancelled},
+	models.AppointmentCheckedIn:  {models.AppointmentInProgress, models.AppointmentCancelled},
+	models.AppointmentInProgress: {models.AppointmentCompleted},
+}
+
+// AppointmentService books appointments and moves them through their states
+type AppointmentService interface {
+	BookAppointment(appointment *models.Appointment) error
+	GetAppointmentByID(id uint) (*models.Appointment, error)
+	RescheduleAppointment(id uint, start, end time.Time) (*models.Appointment, error)
+	CancelAppointment(id uint, reason string) (*models.Appointment, error)
+	UpdateAppointmentState(id uint, state string) (*models.Appointment, error)
+	GetDoctorSchedule(doctorID uint, from, to time.Time) ([]models.Appointment, error)
+}
+
+// AppointmentServiceImpl provides appointment scheduling related services
+type AppointmentServiceImpl struct {
+	DB *gorm.DB
+}
+
+var _ AppointmentService = (*AppointmentServiceImpl)(nil)
+
+func NewAppointmentService(db *gorm.DB) *AppointmentServiceImpl {
+	return &AppointmentServiceImpl{DB: db}
+}
+
+// BookAppointment creates a new appointment after checking that the doctor is free
+func (s *AppointmentServiceImpl) BookAppointment(appointment *models.Appointment) error {
+	if !appointment.EndTime.After(appointment.StartTime) {
+		return ErrInvalidAppointmentTime
+	}
+	if !slices.Contains(models.AppointmentTypes, appointment.Type) {
+		return ErrInvalidAppointmentType
+	}
+	appointment.State = models.AppointmentBooked
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockDoctor(tx, appointment.DoctorID); err != nil {
+			return err
+		}
+		if err := tx.Select("id").First(&models.Patient{}, appointment.PatientID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPatientNotFound
+			}
+			return err
+		}
+		if err := checkDoctorFree(tx, appointment.DoctorID, appointment.StartTime, appointment.EndTime, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(appointment).Error; err != nil {
+			log.Printf("Error creating appointment in DB: %v", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// GetAppointmentByID retrieves a single appointment with its patient
+func (s *AppointmentServiceImpl) GetAppointmentByID(id uint) (*models.Appointment, error) {
+	var appointment models.Appointment
+	if err := s.DB.Preload("Patient").First(&appointment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAppointmentNotFound
+		}
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+// RescheduleAppointment moves a booked appointment to a new time slot
+func (s *AppointmentServiceImpl) RescheduleAppointment(id uint, start, end time.Time) (*models.Appointment, error) {
+	if !end.After(start) {
+		return nil, ErrInvalidAppointmentTime
+	}
+
+	var appointment models.Appointment
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&appointment, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAppointmentNotFound
+			}
+			return err
+		}
+		if appointment.State != models.AppointmentBooked {
+			return ErrInvalidAppointmentTransition
+		}
+		if err := lockDoctor(tx, appointment.DoctorID); err != nil {
+			return err
+		}
+		if err := checkDoctorFree(tx, appointment.DoctorID, start, end, appointment.ID); err != nil {
+			return err
+		}
+		appointment.StartTime = start
+		appointment.EndTime = end
+		if err := tx.Save(&appointment).Error; err != nil {
+			log.Printf("Error rescheduling appointment ID %d: %v", id, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+// CancelAppointment cancels an appointment, freeing the doctor's time
+func (s *AppointmentServiceImpl) CancelAppointment(id uint, reason string) (*models.Appointment, error) {
+	return s.transition(id, models.AppointmentCancelled, func(a *models.Appointment) {
+		a.CancelReason = reason
+	})
+}
+
+// UpdateAppointmentState moves an appointment through its lifecycle (check-in, start, complete, no-show)
+func (s *AppointmentServiceImpl) UpdateAppointmentState(id uint, state string) (*models.Appointment, error) {
+	return s.transition(id, state, nil)
+}
+
+// transition applies a state change if it is allowed from the appointment's current state
+func (s *AppointmentServiceImpl) transition(id uint, state string, apply func(*models.Appointment)) (*models.Appointment, error) {
+	var appointment models.Appointment
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&appointment, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAppointmentNotFound
+			}
+			return err
+		}
+		if !slices.Contains(appointmentTransitions[appointment.State], state) {
+			return ErrInvalidAppointmentTransition
+		}
+		appointment.State = state
+		if apply != nil {
+			apply(&appointment)
+		}
+		if err := tx.Save(&appointment).Error; err != nil {
+			log.Printf("Error updating appointment ID %d to %s: %v", id, state, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+// GetDoctorSchedule lists a doctor's appointments that start within [from, to), earliest first
+func (s *AppointmentServiceImpl) GetDoctorSchedule(doctorID uint, from, to time.Time) ([]models.Appointment, error) {
+	appointments := []models.Appointment{}
+	err := s.DB.Preload("Patient").
+		Where("doctor_id = ? AND start_time >= ? AND start_time < ?", doctorID, from, to).
+		Order("start_time ASC").
+		Find(&appointments).Error
+	if err != nil {
+		log.Printf("Error retrieving schedule for doctor ID %d: %v", doctorID, err)
+		return nil, err
+	}
+	return appointments, nil
+}
+
+// lockDoctor checks that the user is a doctor (has appointment:attend) and locks the row so concurrent bookings
+// for the same doctor are serialized. SQLite ignores the lock and serializes writers itself.
+func lockDoctor(tx *gorm.DB, doctorID uint) error {
+	var doctor models.User
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND role IN (?)", doctorID, rolesWithPermission(tx, models.PermAppointmentAttend)).
+		First(&doctor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrDoctorNotFound
+	}
+	return err
+}
+
+// checkDoctorFree returns ErrDoubleBooking when another active appointment of the doctor overlaps [start, end)
+func checkDoctorFree(tx *gorm.DB, doctorID uint, start, end time.Time, excludeID uint) error {
+	var count int64
+	query := tx.Model(&models.Appointment{}).
+		Where("doctor_id = ? AND start_time < ? AND end_time > ?", doctorID, end, start).
+		Where("state NOT IN ?", []string{models.AppointmentCancelled, models.AppointmentNoShow})
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDoubleBooking
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"medical_app/models"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditChainLockID is the Postgres advisory lock key that serializes appends to the hash
+// chain. It is held until the outermost transaction ends, so a unit of work that records
+// several entries keeps the chain head until it commits.
+const auditChainLockID = 7_201_006
+
+// genesisHash is the PrevHash of the very first audit entry
+var genesisHash = strings.Repeat("0", 64)
+
+// auditIgnoredFields are bookkeeping columns left out of field-level diffs
+var auditIgnoredFields = map[string]bool{"CreatedAt": true, "UpdatedAt": true, "DeletedAt": true}
+
+// AuditActor describes who performed an audited action and through which request
+type AuditActor struct {
+	Username string
+	Role     string
+	IP       string
+	Method   string
+	Route    string
+}
+
+// FieldChange is the before and after value of a single changed field
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditQuery holds the filters for searching the audit log
+type AuditQuery struct {
+	Actor     string
+	Action    string
+	PatientID *uint
+	From      *time.Time
+	To        *time.Time
+	Page      int
+	PageSize  int
+}
+
+// AuditChainReport is the result of verifying the hash chain
+type AuditChainReport struct {
+	Valid    bool  `json:"valid"`
+	Checked  int64 `json:"checked"`
+	BrokenAt *uint `json:"broken_at,omitempty"` // ID of the first entry that does not match
+}
+
+// AuditService writes and reads the tamper-evident audit log of patient data access
+type AuditService interface {
+	Record(actor AuditActor, action string, patientID uint, before, after interface{}) error
+	RecordReads(actor AuditActor, patientIDs []uint) error
+	ListEntries(query AuditQuery) ([]models.AuditLog, int64, error)
+	ExportEntries(query AuditQuery, fn func([]models.AuditLog) error) error
+	VerifyChain() (*AuditChainReport, error)
+}
+
+// AuditServiceImpl records and queries the append-only audit log
+type AuditServiceImpl struct {
+	DB      *gorm.DB
+	Replica *gorm.DB // optional read replica for searching and exporting, which may lag behind DB
+}
+
+var _ AuditService = (*AuditServiceImpl)(nil)
+
+func NewAuditService(db *gorm.DB) *AuditServiceImpl {
+	return &AuditServiceImpl{DB: db}
+}
+
+// Record appends one audit entry about a patient. before and after are the record
+// before and after the change (nil when it did not exist), used for the field-level diff.
+func (s *AuditServiceImpl) Record(actor AuditActor, action string, patientID uint, before, after interface{}) error {
+	changes, err := DiffRecords(before, after)
+	if err != nil {
+		return err
+	}
+	return s.append([]models.AuditLog{newAuditEntry(actor, action, patientID, changes)})
+}
+
+// RecordReads appends a read entry for every patient that was returned to the caller
+func (s *AuditServiceImpl) RecordReads(actor AuditActor, patientIDs []uint) error {
+	if len(patientIDs) == 0 {
+		return nil
+	}
+	entries := make([]models.AuditLog, 0, len(patientIDs))
+	for _, id := range patientIDs {
+		entries = append(entries, newAuditEntry(actor, models.AuditRead, id, nil))
+	}
+	return s.append(entries)
+}
+
+// append chains the entries onto the last stored entry and writes them in one transaction.
+// Concurrent appends are serialized by the database, across processes as well: Postgres
+// takes an advisory lock, and SQLite allows one write transaction at a time (database.Open
+// makes transactions take the write lock when they begin, so they wait instead of failing
+// with "database is locked").
+func (s *AuditServiceImpl) append(entries []models.AuditLog) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockID).Error; err != nil {
+				return err
+			}
+		}
+
+		prevHash := genesisHash
+		var last models.AuditLog
+		result := tx.Order("id DESC").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			prevHash = last.Hash
+		}
+
+		for i := range entries {
+			entries[i].PrevHash = prevHash
+			entries[i].Hash = auditHash(&entries[i])
+			prevHash = entries[i].Hash
+		}
+		return tx.Create(&entries).Error
+	})
+	if err != nil {
+		log.Printf("Error writing audit log: %v", err)
+	}
+	return err
+}
+
+// ListEntries returns a page of audit entries matching the query, newest first
+func (s *AuditServiceImpl) ListEntries(query AuditQuery) ([]models.AuditLog, int64, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 || query.PageSize > MaxPatientPageSize {
+		query.PageSize = DefaultPatientPageSize
+	}
+
+	db := s.filtered(query).Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	entries := []models.AuditLog{}
+	err := db.Order("id DESC").Limit(query.PageSize).Offset((query.Page - 1) * query.PageSize).Find(&entries).Error
+	if err != nil {
+		log.Printf("Error listing audit log: %v", err)
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// ExportEntries streams every matching entry, oldest first, to fn in batches
+func (s *AuditServiceImpl) ExportEntries(query AuditQuery, fn func([]models.AuditLog) error) error {
+	var batch []models.AuditLog
+	return s.filtered(query).FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// VerifyChain recomputes every hash and checks each entry links to the one before it
+func (s *AuditServiceImpl) VerifyChain() (*AuditChainReport, error) {
+	report := &AuditChainReport{Valid: true}
+	prevHash := genesisHash
+
+	var batch []models.AuditLog
+	err := s.DB.Model(&models.AuditLog{}).FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			entry := &batch[i]
+			if entry.PrevHash != prevHash || entry.Hash != auditHash(entry) {
+				report.Valid = false
+				report.BrokenAt = &entry.ID
+				return errStopVerify
+			}
+			prevHash = entry.Hash
+			report.Checked++
+		}
+		return nil
+	}).Error
+	if err != nil && err != errStopVerify {
+		return nil, err
+	}
+	return report, nil
+}
+
+// errStopVerify ends batch iteration once the chain is known to be broken
+var errStopVerify = errors.New("audit chain broken")
+
+// filtered builds the audit query shared by listing and export. It runs on the replica
+// when there is one; the chain is always verified on the primary.
+func (s *AuditServiceImpl) filtered(query AuditQuery) *gorm.DB {
+	db := s.DB
+	if s.Replica != nil {
+		db = s.Replica
+	}
+	db = db.Model(&models.AuditLog{})
+	if query.Actor != "" {
+		db = db.Where("actor = ?", query.Actor)
+	}
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.PatientID != nil {
+		db = db.Where("patient_id = ?", *query.PatientID)
+	}
+	if query.From != nil {
+		db = db.Where("created_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		db = db.Where("created_at < ?", *query.To)
+	}
+	return db
+}
+
+// DiffRecords compares two records field by field using their JSON representation.
+// A nil before means the record was created, a nil after means it was deleted.
+func DiffRecords(before, after interface{}) (map[string]FieldChange, error) {
+	beforeFields, err := toFieldMap(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := toFieldMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]FieldChange)
+	for name, value := range beforeFields {
+		if !auditIgnoredFields[name] && !reflect.DeepEqual(value, afterFields[name]) {
+			changes[name] = FieldChange{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, seen := beforeFields[name]; !seen && !auditIgnoredFields[name] && value != nil {
+			changes[name] = FieldChange{Before: nil, After: value}
+		}
+	}
+	return changes, nil
+}
+
+// toFieldMap turns a struct into a map of its JSON fields
+func toFieldMap(record interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if record == nil || (reflect.ValueOf(record).Kind() == reflect.Ptr && reflect.ValueOf(record).IsNil()) {
+		return fields, nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// newAuditEntry builds an unsaved, unchained entry
+func newAuditEntry(actor AuditActor, action string, patientID uint, changes map[string]FieldChange) models.AuditLog {
+	entry := models.AuditLog{
+		// Stored timestamps keep microseconds at most, so hash what will be read back
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		Actor:     actor.Username,
+		Role:      actor.Role,
+		IP:        actor.IP,
+		Method:    actor.Method,
+		Route:     actor.Route,
+		Action:    action,
+	}
+	if patientID != 0 {
+		entry.PatientID = &patientID
+	}
+	if len(changes) > 0 {
+		// encoding/json writes map keys in sorted order, so the text (and hash) is deterministic
+		data, _ := json.Marshal(changes)
+		entry.Changes = string(data)
+	}
+	return entry
+}
+
+// auditHash hashes the entry's content together with the previous hash
+func auditHash(entry *models.AuditLog) string {
+	var patientID uint
+	if entry.PatientID != nil {
+		patientID = *entry.PatientID
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x1f%s\x1f%s\x1f%s\x1f%s\x1f%s\x1f%s\x1f%d\x1f%s\x1f%s",
+		entry.PrevHash,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		entry.Actor, entry.Role, entry.IP, entry.Method, entry.Route,
+		patientID, entry.Action, entry.Changes)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -2,35 +2,257 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"medical_app/config"
 	"medical_app/models"
 	"medical_app/utils"
+	"strings"
+	"time"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var (
+	// ErrInvalidCredentials is returned for an unknown username or a wrong password alike
+	ErrInvalidCredentials = newError(KindUnauthorized, "invalid_credentials", "invalid credentials")
+	// ErrAccountDisabled is returned when the password is right but an admin disabled the account
+	ErrAccountDisabled = newError(KindForbidden, "account_disabled", "account is disabled")
+	// ErrLoginThrottled is wrapped by LoginThrottledError
+	ErrLoginThrottled = newError(KindRateLimited, "login_throttled", "too many failed login attempts, try again later")
+)
+
+// backoffFreeFailures is how many failures in a row are allowed before delays kick in
+const backoffFreeFailures = 2
+
+// dummyPasswordHash is compared against for unknown usernames, so they take as long as a
+// wrong password and the response time does not tell which usernames exist. It has the
+// cost of utils.HashPassword and matches no password a client would send.
+const dummyPasswordHash = "$2a$10$SLHZNAxRkdhO/KvFciJxpODapIJ1KOvhx.j0Hl/kBkrnD3JOLYD4i"
+
+// LoginThrottledError is returned while a username or client IP is backing off or locked out.
+// The message is the same whether or not the username exists.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // true for a lockout, false for a short backoff delay
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrLoginThrottled.Message
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrLoginThrottled
+}
+
+// AuthService checks login credentials and throttles failed attempts
+type AuthService interface {
+	Login(username, password, ip string) (*models.User, error)
+	SecondFactor(username, ip string, check func(user *models.User) error) (*models.User, error)
+	UnlockUser(username string) error
+	UnlockIP(ip string) error
+	ListLockouts() ([]models.LoginThrottle, error)
+}
+
 type AuthServiceImpl struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Config *config.Config
 }
 
+var _ AuthService = (*AuthServiceImpl)(nil)
+
 // creates a new AuthService instance
-func NewAuthService(db *gorm.DB) *AuthServiceImpl {
-	return &AuthServiceImpl{DB: db}
+func NewAuthService(db *gorm.DB, cfg *config.Config) *AuthServiceImpl {
+	return &AuthServiceImpl{DB: db, Config: cfg}
 }
 
-// Login authenticates a user
-func (s *AuthServiceImpl) Login(username, password string) (*models.User, error) {
+// Login authenticates a user. Failed attempts are counted per username and per client IP;
+// after a few failures further attempts are delayed with exponential backoff, and after
+// Config.LoginMaxFailures (or LoginMaxIPFailures) the username (or IP) is locked out.
+func (s *AuthServiceImpl) Login(username, password, ip string) (*models.User, error) {
+	keys := []string{userThrottleKey(username), ipThrottleKey(ip)}
+	if err := s.checkThrottle(keys); err != nil {
+		return nil, err
+	}
+
 	var user models.User
 	if err := s.DB.Where("username = ?", username).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid credentials")
+			// Unknown usernames are throttled exactly like real ones, and cost a bcrypt
+			// compare like them
+			utils.CheckPasswordHash(password, dummyPasswordHash)
+			return nil, s.recordFailure(keys)
 		}
 		log.Printf("Error finding user by username: %v", err)
 		return nil, err
 	}
 
 	if !utils.CheckPasswordHash(password, user.Password) {
-		return nil, errors.New("invalid credentials")
+		return nil, s.recordFailure(keys)
+	}
+
+	if user.Disabled {
+		return nil, ErrAccountDisabled
 	}
 
+	// The username's counter is only cleared once the login is complete: with a second
+	// factor still to come, the password alone must not reset the count of wrong codes
+	step, err := NewMFAService(s.DB).LoginStep(&user)
+	if err != nil {
+		return nil, err
+	}
+	if step == LoginComplete {
+		s.clearUserFailures(username)
+	}
+	return &user, nil
+}
+
+// SecondFactor runs the MFA step of a login for a user whose password was already
+// accepted. check verifies the code; wrong codes are throttled like wrong passwords.
+func (s *AuthServiceImpl) SecondFactor(username, ip string, check func(user *models.User) error) (*models.User, error) {
+	keys := []string{userThrottleKey(username), ipThrottleKey(ip)}
+	if err := s.checkThrottle(keys); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	if err := check(&user); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if ferr := s.recordFailure(keys); !errors.Is(ferr, ErrInvalidCredentials) {
+				return nil, ferr
+			}
+		}
+		return nil, err
+	}
+	s.clearUserFailures(username)
 	return &user, nil
-}
\ No newline at end of file
+}
+
+// clearUserFailures forgets the failed attempts of a username after a successful login.
+// Only the username's counter is cleared, so one valid account cannot be used to reset the
+// counter of an IP that is guessing other passwords.
+func (s *AuthServiceImpl) clearUserFailures(username string) {
+	if err := s.DB.Delete(&models.LoginThrottle{}, "key = ?", userThrottleKey(username)).Error; err != nil {
+		log.Printf("Error clearing login failures for %s: %v", username, err)
+	}
+}
+
+// UnlockUser clears the failed-login counter and lockout of a username
+func (s *AuthServiceImpl) UnlockUser(username string) error {
+	return s.DB.Delete(&models.LoginThrottle{}, "key = ?", userThrottleKey(username)).Error
+}
+
+// UnlockIP clears the failed-login counter and lockout of a client IP
+func (s *AuthServiceImpl) UnlockIP(ip string) error {
+	return s.DB.Delete(&models.LoginThrottle{}, "key = ?", ipThrottleKey(ip)).Error
+}
+
+// ListLockouts returns the usernames and IPs that are currently locked out
+func (s *AuthServiceImpl) ListLockouts() ([]models.LoginThrottle, error) {
+	lockouts := []models.LoginThrottle{}
+	err := s.DB.Where("locked_until > ?", time.Now()).Order("locked_until DESC").Find(&lockouts).Error
+	if err != nil {
+		log.Printf("Error listing login lockouts: %v", err)
+		return nil, err
+	}
+	return lockouts, nil
+}
+
+// checkThrottle returns a LoginThrottledError when any key is locked or still backing off
+func (s *AuthServiceImpl) checkThrottle(keys []string) error {
+	var throttles []models.LoginThrottle
+	if err := s.DB.Where("key IN ?", keys).Find(&throttles).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var worst *LoginThrottledError
+	for _, t := range throttles {
+		var wait time.Duration
+		locked := false
+		if t.LockedUntil != nil && now.Before(*t.LockedUntil) {
+			wait, locked = t.LockedUntil.Sub(now), true
+		} else if until := t.LastFailure.Add(s.backoff(t.Failures)); s.remembered(t, now) && now.Before(until) {
+			wait = until.Sub(now)
+		}
+		if wait > 0 && (worst == nil || wait > worst.RetryAfter) {
+			worst = &LoginThrottledError{RetryAfter: wait, Locked: locked}
+		}
+	}
+	if worst != nil {
+		return worst
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt against every key and returns the error for the caller
+func (s *AuthServiceImpl) recordFailure(keys []string) error {
+	now := time.Now()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{Key: key}).Error; err != nil {
+				return err
+			}
+			var t models.LoginThrottle
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, "key = ?", key).Error; err != nil {
+				return err
+			}
+
+			if !s.remembered(t, now) {
+				t.Failures = 0
+				t.LockedUntil = nil
+			}
+			t.Failures++
+			t.LastFailure = now
+			if t.Failures >= s.maxFailures(key) {
+				lockedUntil := now.Add(s.Config.LoginLockoutDuration)
+				t.LockedUntil = &lockedUntil
+				log.Printf("Login lockout: %s locked until %s after %d failed attempts", key, lockedUntil.Format(time.RFC3339), t.Failures)
+			}
+			if err := tx.Save(&t).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error recording failed login: %v", err)
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// remembered reports whether a throttle's failures are recent enough to still count
+func (s *AuthServiceImpl) remembered(t models.LoginThrottle, now time.Time) bool {
+	return now.Sub(t.LastFailure) < s.Config.LoginLockoutDuration
+}
+
+// backoff is the delay required after the given number of consecutive failures
+func (s *AuthServiceImpl) backoff(failures int) time.Duration {
+	if failures <= backoffFreeFailures {
+		return 0
+	}
+	delay := s.Config.LoginBackoffBase << min(failures-backoffFreeFailures-1, 20)
+	return min(delay, s.Config.LoginLockoutDuration)
+}
+
+// maxFailures returns the lockout threshold for a throttle key
+func (s *AuthServiceImpl) maxFailures(key string) int {
+	if strings.HasPrefix(key, "ip:") {
+		return s.Config.LoginMaxIPFailures
+	}
+	return s.Config.LoginMaxFailures
+}
+
+func userThrottleKey(username string) string { return fmt.Sprintf("user:%s", username) }
+func ipThrottleKey(ip string) string         { return fmt.Sprintf("ip:%s", ip) }
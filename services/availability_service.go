@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"medical_app/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MinSlotMinutes and MaxSlotMinutes bound the configurable appointment slot length
+	MinSlotMinutes = 5
+	MaxSlotMinutes = 240
+	// MaxSlotRangeDays caps the date range free slots can be computed for in one call
+	MaxSlotRangeDays = 31
+
+	clockLayout = "15:04"
+)
+
+var (
+	// ErrInvalidAvailability is returned when weekly working hours are malformed or overlap
+	ErrInvalidAvailability = newError(KindValidation, "invalid_availability", "invalid availability")
+	// ErrInvalidSlotRange is returned when the free-slot range is empty or too long
+	ErrInvalidSlotRange = newError(KindValidation, "invalid_slot_range", fmt.Sprintf("slot range must be non-empty and at most %d days", MaxSlotRangeDays))
+	// ErrExceptionNotFound is returned when the availability exception does not exist for the doctor
+	ErrExceptionNotFound = newError(KindNotFound, "availability_exception_not_found", "availability exception not found")
+)
+
+// Slot is a bookable period of a doctor's time
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// AvailabilityService manages doctors' weekly availability, exceptions and free slots
+type AvailabilityService interface {
+	GetWeeklyAvailability(doctorID uint) ([]models.DoctorAvailability, error)
+	SetWeeklyAvailability(doctorID uint, blocks []models.DoctorAvailability) error
+	ListExceptions(doctorID uint, from, to time.Time) ([]models.AvailabilityException, error)
+	AddException(exception *models.AvailabilityException) error
+	DeleteException(doctorID, id uint) error
+	FreeSlots(doctorID uint, from, to time.Time) ([]Slot, error)
+}
+
+// AvailabilityServiceImpl manages doctors' working hours and computes free slots
+type AvailabilityServiceImpl struct {
+	DB *gorm.DB
+}
+
+var _ AvailabilityService = (*AvailabilityServiceImpl)(nil)
+
+func NewAvailabilityService(db *gorm.DB) *AvailabilityServiceImpl {
+	return &AvailabilityServiceImpl{DB: db}
+}
+
+// GetWeeklyAvailability returns a doctor's recurring working hours ordered by day and time
+func (s *AvailabilityServiceImpl) GetWeeklyAvailability(doctorID uint) ([]models.DoctorAvailability, error) {
+	availability := []models.DoctorAvailability{}
+	if err := s.DB.Where("doctor_id = ?", doctorID).Order("weekday ASC, start_time ASC").Find(&availability).Error; err != nil {
+		log.Printf("Error retrieving availability for doctor ID %d: %v", doctorID, err)
+		return nil, err
+	}
+	return availability, nil
+}
+
+// SetWeeklyAvailability replaces all of a doctor's recurring working hours
+func (s *AvailabilityServiceImpl) SetWeeklyAvailability(doctorID uint, blocks []models.DoctorAvailability) error {
+	if err := validateWeeklyAvailability(blocks); err != nil {
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND role IN (?)", doctorID, rolesWithPermission(tx, models.PermAppointmentAttend)).First(&models.User{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDoctorNotFound
+			}
+			return err
+		}
+		if err := tx.Unscoped().Where("doctor_id = ?", doctorID).Delete(&models.DoctorAvailability{}).Error; err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			return nil
+		}
+		for i := range blocks {
+			blocks[i].ID = 0
+			blocks[i].DoctorID = doctorID
+		}
+		if err := tx.Create(&blocks).Error; err != nil {
+			log.Printf("Error saving availability for doctor ID %d: %v", doctorID, err)
+			return err
+		}
+		return nil
+	})
+}
+
+// ListExceptions returns a doctor's exceptions that overlap [from, to)
+func (s *AvailabilityServiceImpl) ListExceptions(doctorID uint, from, to time.Time) ([]models.AvailabilityException, error) {
+	exceptions := []models.AvailabilityException{}
+	err := s.DB.Where("doctor_id = ? AND start_time < ? AND end_time > ?", doctorID, to, from).
+		Order("start_time ASC").
+		Find(&exceptions).Error
+	if err != nil {
+		log.Printf("Error retrieving availability exceptions for doctor ID %d: %v", doctorID, err)
+		return nil, err
+	}
+	return exceptions, nil
+}
+
+// AddException blocks out a period of a doctor's time
+func (s *AvailabilityServiceImpl) AddException(exception *models.AvailabilityException) error {
+	if !exception.EndTime.After(exception.StartTime) {
+		return fmt.Errorf("%w: exception must end after it starts", ErrInvalidAvailability)
+	}
+	if err := s.DB.Create(exception).Error; err != nil {
+		log.Printf("Error creating availability exception: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteException removes one of a doctor's exceptions
+func (s *AvailabilityServiceImpl) DeleteException(doctorID, id uint) error {
+	result := s.DB.Where("doctor_id = ?", doctorID).Delete(&models.AvailabilityException{}, id)
+	if result.Error != nil {
+		log.Printf("Error deleting availability exception ID %d: %v", id, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrExceptionNotFound
+	}
+	return nil
+}
+
+// FreeSlots computes the doctor's bookable slots within [from, to). Slots come from the
+// weekly working hours, minus exceptions and appointments that still block the schedule.
+func (s *AvailabilityServiceImpl) FreeSlots(doctorID uint, from, to time.Time) ([]Slot, error) {
+	if !to.After(from) || to.Sub(from) > MaxSlotRangeDays*24*time.Hour {
+		return nil, ErrInvalidSlotRange
+	}
+
+	weekly, err := s.GetWeeklyAvailability(doctorID)
+	if err != nil {
+		return nil, err
+	}
+	exceptions, err := s.ListExceptions(doctorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var appointments []models.Appointment
+	err = s.DB.Where("doctor_id = ? AND start_time < ? AND end_time > ?", doctorID, to, from).
+		Where("state NOT IN ?", []string{models.AppointmentCancelled, models.AppointmentNoShow}).
+		Find(&appointments).Error
+	if err != nil {
+		log.Printf("Error retrieving appointments for doctor ID %d: %v", doctorID, err)
+		return nil, err
+	}
+
+	busy := make([]Slot, 0, len(exceptions)+len(appointments))
+	for _, e := range exceptions {
+		busy = append(busy, Slot{Start: e.StartTime, End: e.EndTime})
+	}
+	for _, a := range appointments {
+		busy = append(busy, Slot{Start: a.StartTime, End: a.EndTime})
+	}
+
+	slots := []Slot{}
+	loc := from.Location()
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, block := range weekly {
+			if time.Weekday(block.Weekday) != day.Weekday() {
+				continue
+			}
+			blockStart, blockEnd := clockOn(day, block.StartTime), clockOn(day, block.EndTime)
+			length := time.Duration(block.SlotMinutes) * time.Minute
+			for start := blockStart; !start.Add(length).After(blockEnd); start = start.Add(length) {
+				slot := Slot{Start: start, End: start.Add(length)}
+				if slot.Start.Before(from) || slot.End.After(to) || overlapsAny(slot, busy) {
+					continue
+				}
+				slots = append(slots, slot)
+			}
+		}
+	}
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+	return slots, nil
+}
+
+// validateWeeklyAvailability checks days, clock values, slot lengths and overlaps within a day
+func validateWeeklyAvailability(blocks []models.DoctorAvailability) error {
+	byDay := make(map[int][]models.DoctorAvailability)
+	for i := range blocks {
+		block := &blocks[i]
+		if block.Weekday < 0 || block.Weekday > 6 {
+			return fmt.Errorf("%w: weekday must be between 0 (Sunday) and 6 (Saturday)", ErrInvalidAvailability)
+		}
+		start, errStart := time.Parse(clockLayout, block.StartTime)
+		end, errEnd := time.Parse(clockLayout, block.EndTime)
+		if errStart != nil || errEnd != nil {
+			return fmt.Errorf("%w: times must be HH:MM", ErrInvalidAvailability)
+		}
+		if !end.After(start) {
+			return fmt.Errorf("%w: end_time must be after start_time", ErrInvalidAvailability)
+		}
+		if block.SlotMinutes == 0 {
+			block.SlotMinutes = 30
+		}
+		if block.SlotMinutes < MinSlotMinutes || block.SlotMinutes > MaxSlotMinutes {
+			return fmt.Errorf("%w: slot_minutes must be between %d and %d", ErrInvalidAvailability, MinSlotMinutes, MaxSlotMinutes)
+		}
+		// "HH:MM" strings order the same way as the times they represent
+		for _, other := range byDay[block.Weekday] {
+			if block.StartTime < other.EndTime && other.StartTime < block.EndTime {
+				return fmt.Errorf("%w: overlapping hours on weekday %d", ErrInvalidAvailability, block.Weekday)
+			}
+		}
+		byDay[block.Weekday] = append(byDay[block.Weekday], *block)
+	}
+	return nil
+}
+
+// clockOn returns the "HH:MM" wall-clock time on the given day
+func clockOn(day time.Time, clock string) time.Time {
+	t, _ := time.Parse(clockLayout, clock)
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+}
+
+// overlapsAny reports whether slot intersects any of the busy periods
+func overlapsAny(slot Slot, busy []Slot) bool {
+	for _, b := range busy {
+		if slot.Start.Before(b.End) && b.Start.Before(slot.End) {
+			return true
+		}
+	}
+	return false
+}
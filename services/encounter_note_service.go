@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"medical_app/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNoteNotFound is returned when the encounter note does not exist or is not visible to the caller
+	ErrNoteNotFound = newError(KindNotFound, "note_not_found", "encounter note not found")
+	// ErrNoteEmpty is returned when none of the SOAP sections has content
+	ErrNoteEmpty = newError(KindValidation, "note_empty", "at least one of subjective, objective, assessment or plan is required")
+	// ErrNoteSigned is returned when trying to edit or sign a note that is already signed
+	ErrNoteSigned = newError(KindConflict, "note_signed", "encounter note is signed, amend it instead")
+	// ErrNoteNotSigned is returned when trying to amend a draft
+	ErrNoteNotSigned = newError(KindConflict, "note_not_signed", "only signed notes can be amended, edit the draft instead")
+	// ErrAmendmentReasonRequired is returned when an amendment does not say why it was made
+	ErrAmendmentReasonRequired = newError(KindValidation, "amendment_reason_required", "amendment reason is required")
+)
+
+// SOAPContent holds the four sections of an encounter note
+type SOAPContent struct {
+	Subjective string
+	Objective  string
+	Assessment string
+	Plan       string
+}
+
+// empty reports whether every section is blank
+func (s SOAPContent) empty() bool {
+	return strings.TrimSpace(s.Subjective+s.Objective+s.Assessment+s.Plan) == ""
+}
+
+// EncounterNoteService manages SOAP encounter notes with signing and amendments
+type EncounterNoteService interface {
+	CreateNote(patientID uint, author *models.User, content SOAPContent, appointmentID *uint, sign bool) (*models.EncounterNote, error)
+	ListNotes(patientID, viewerID uint) ([]models.EncounterNote, error)
+	GetNote(id, viewerID uint) (*models.EncounterNote, error)
+	UpdateDraft(id uint, author *models.User, content SOAPContent) (*models.EncounterNote, error)
+	SignNote(id uint, author *models.User) (*models.EncounterNote, error)
+	AmendNote(id uint, author *models.User, content SOAPContent, reason string, sign bool) (*models.EncounterNote, error)
+}
+
+// EncounterNoteServiceImpl provides append-only clinical note services
+type EncounterNoteServiceImpl struct {
+	DB *gorm.DB
+}
+
+var _ EncounterNoteService = (*EncounterNoteServiceImpl)(nil)
+
+func NewEncounterNoteService(db *gorm.DB) *EncounterNoteServiceImpl {
+	return &EncounterNoteServiceImpl{DB: db}
+}
+
+// CreateNote writes a new note for a patient, signing it straight away when sign is true
+func (s *EncounterNoteServiceImpl) CreateNote(patientID uint, author *models.User, content SOAPContent, appointmentID *uint, sign bool) (*models.EncounterNote, error) {
+	if content.empty() {
+		return nil, ErrNoteEmpty
+	}
+	if err := s.DB.Select("id").First(&models.Patient{}, patientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, err
+	}
+
+	note := newNote(patientID, author, content, sign)
+	note.AppointmentID = appointmentID
+	if err := s.DB.Create(note).Error; err != nil {
+		log.Printf("Error creating encounter note for patient ID %d: %v", patientID, err)
+		return nil, err
+	}
+	return note, nil
+}
+
+// ListNotes returns a patient's signed notes and the caller's own drafts, newest first
+func (s *EncounterNoteServiceImpl) ListNotes(patientID, viewerID uint) ([]models.EncounterNote, error) {
+	notes := []models.EncounterNote{}
+	err := s.DB.Where("patient_id = ?", patientID).
+		Where("state = ? OR author_id = ?", models.NoteSigned, viewerID).
+		Order("created_at DESC, id DESC").
+		Find(&notes).Error
+	if err != nil {
+		log.Printf("Error listing encounter notes for patient ID %d: %v", patientID, err)
+		return nil, err
+	}
+	return notes, nil
+}
+
+// GetNote returns a note if it is signed or a draft of the viewer
+func (s *EncounterNoteServiceImpl) GetNote(id, viewerID uint) (*models.EncounterNote, error) {
+	var note models.EncounterNote
+	if err := s.DB.First(&note, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoteNotFound
+		}
+		return nil, err
+	}
+	if note.State != models.NoteSigned && note.AuthorID != viewerID {
+		return nil, ErrNoteNotFound
+	}
+	return &note, nil
+}
+
+// UpdateDraft replaces the content of one of the author's own unsigned notes
+func (s *EncounterNoteServiceImpl) UpdateDraft(id uint, author *models.User, content SOAPContent) (*models.EncounterNote, error) {
+	if content.empty() {
+		return nil, ErrNoteEmpty
+	}
+	return s.changeDraft(id, author, func(note *models.EncounterNote) {
+		note.Subjective = content.Subjective
+		note.Objective = content.Objective
+		note.Assessment = content.Assessment
+		note.Plan = content.Plan
+	})
+}
+
+// SignNote signs one of the author's own drafts, after which it can only be amended
+func (s *EncounterNoteServiceImpl) SignNote(id uint, author *models.User) (*models.EncounterNote, error) {
+	return s.changeDraft(id, author, func(note *models.EncounterNote) {
+		now := time.Now()
+		note.State = models.NoteSigned
+		note.SignedAt = &now
+	})
+}
+
+// AmendNote records a correction to a signed note as a new note; the original is left untouched
+func (s *EncounterNoteServiceImpl) AmendNote(id uint, author *models.User, content SOAPContent, reason string, sign bool) (*models.EncounterNote, error) {
+	if content.empty() {
+		return nil, ErrNoteEmpty
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, ErrAmendmentReasonRequired
+	}
+
+	original, err := s.GetNote(id, author.ID)
+	if err != nil {
+		return nil, err
+	}
+	if original.State != models.NoteSigned {
+		return nil, ErrNoteNotSigned
+	}
+
+	amendment := newNote(original.PatientID, author, content, sign)
+	amendment.AppointmentID = original.AppointmentID
+	amendment.AmendsID = &original.ID
+	amendment.AmendmentReason = reason
+	if err := s.DB.Create(amendment).Error; err != nil {
+		log.Printf("Error amending encounter note ID %d: %v", id, err)
+		return nil, err
+	}
+	return amendment, nil
+}
+
+// changeDraft applies change to a draft owned by author inside a transaction
+func (s *EncounterNoteServiceImpl) changeDraft(id uint, author *models.User, change func(*models.EncounterNote)) (*models.EncounterNote, error) {
+	var note models.EncounterNote
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&note, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNoteNotFound
+			}
+			return err
+		}
+		if note.State == models.NoteSigned {
+			return ErrNoteSigned
+		}
+		if note.AuthorID != author.ID {
+			// Other people's drafts are not visible at all
+			return ErrNoteNotFound
+		}
+		change(&note)
+		if err := tx.Save(&note).Error; err != nil {
+			log.Printf("Error saving encounter note ID %d: %v", id, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// newNote builds an unsaved note authored by author
+func newNote(patientID uint, author *models.User, content SOAPContent, sign bool) *models.EncounterNote {
+	note := &models.EncounterNote{
+		PatientID:      patientID,
+		AuthorID:       author.ID,
+		AuthorUsername: author.Username,
+		Subjective:     content.Subjective,
+		Objective:      content.Objective,
+		Assessment:     content.Assessment,
+		Plan:           content.Plan,
+		State:          models.NoteDraft,
+	}
+	if sign {
+		now := time.Now()
+		note.State = models.NoteSigned
+		note.SignedAt = &now
+	}
+	return note
+}
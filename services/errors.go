@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrorKind classifies the errors services report to callers, so the API can choose a
+// response status without knowing every error
+type ErrorKind int
+
+const (
+	// KindInternal is an unexpected failure; its details are logged, never shown to clients
+	KindInternal ErrorKind = iota
+	// KindValidation is a request that is malformed or has invalid values
+	KindValidation
+	// KindUnauthorized is a caller that could not be authenticated
+	KindUnauthorized
+	// KindForbidden is an authenticated caller that may not do what was asked
+	KindForbidden
+	// KindNotFound is a record that does not exist
+	KindNotFound
+	// KindConflict is a request that clashes with the current state of a record
+	KindConflict
+	// KindStale is a change based on an outdated version of a record
+	KindStale
+	// KindRateLimited is a caller that has to wait before trying again
+	KindRateLimited
+)
+
+// Error is an error with a kind and a stable, machine-readable code. Services return the
+// exported Err values, possibly wrapped with more detail; the code never changes once
+// clients may rely on it, while the message may.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// ErrInvalidFields is wrapped by FieldErrors
+var ErrInvalidFields = newError(KindValidation, "invalid_fields", "invalid fields")
+
+// FieldErrors lists the rejected fields of a request with the reason for each
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, field+": "+e[field])
+	}
+	return "invalid fields: " + strings.Join(parts, "; ")
+}
+
+func (e FieldErrors) Unwrap() error {
+	return ErrInvalidFields
+}
+
+// AsError returns the Error that err is or wraps, or nil for unexpected errors
+func AsError(err error) *Error {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"medical_app/models"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrUnknownField is returned for a field visibility rule on a field outside models.PatientFields
+	ErrUnknownField = newError(KindValidation, "unknown_patient_field", "unknown patient field")
+	// ErrInvalidFieldAccess is returned for an access level other than visible, masked or hidden
+	ErrInvalidFieldAccess = newError(KindValidation, "invalid_field_access", "access must be visible, masked or hidden")
+)
+
+// maskKeep is how many trailing characters a masked value keeps
+const maskKeep = 4
+
+// FieldVisibilityService manages which patient fields each role sees
+type FieldVisibilityService interface {
+	Rules(role string) (map[string]string, error)
+	SetRules(role string, rules map[string]string) error
+	PatientView(role string) (*PatientView, error)
+}
+
+// FieldVisibilityServiceImpl manages which patient fields each role sees
+type FieldVisibilityServiceImpl struct {
+	DB *gorm.DB
+}
+
+var _ FieldVisibilityService = (*FieldVisibilityServiceImpl)(nil)
+
+func NewFieldVisibilityService(db *gorm.DB) *FieldVisibilityServiceImpl {
+	return &FieldVisibilityServiceImpl{DB: db}
+}
+
+// SeedBuiltInRules creates the default rules of the built-in roles that do not exist yet
+func (s *FieldVisibilityServiceImpl) SeedBuiltInRules() error {
+	for role, rules := range models.BuiltInFieldRules {
+		for field, access := range rules {
+			rule := models.FieldVisibility{Role: role, Field: field, Access: access}
+			if err := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rule).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Rules returns the access level of every patient field for a role, defaults included
+func (s *FieldVisibilityServiceImpl) Rules(role string) (map[string]string, error) {
+	var rows []models.FieldVisibility
+	if err := s.DB.Where("role = ?", role).Find(&rows).Error; err != nil {
+		log.Printf("Error loading field visibility of role %s: %v", role, err)
+		return nil, err
+	}
+
+	rules := make(map[string]string, len(models.PatientFields))
+	for field := range models.PatientFields {
+		rules[field] = models.FieldVisible
+		if access, ok := models.DefaultPatientFieldAccess[field]; ok {
+			rules[field] = access
+		}
+	}
+	for _, row := range rows {
+		rules[row.Field] = row.Access
+	}
+	return rules, nil
+}
+
+// SetRules stores access levels for some of a role's patient fields, leaving the others as they are
+func (s *FieldVisibilityServiceImpl) SetRules(role string, rules map[string]string) error {
+	exists, err := roleExists(s.DB, role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrInvalidRole
+	}
+	for field, access := range rules {
+		if _, ok := models.PatientFields[field]; !ok {
+			return ErrUnknownField
+		}
+		if access != models.FieldVisible && access != models.FieldMasked && access != models.FieldHidden {
+			return ErrInvalidFieldAccess
+		}
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		for field, access := range rules {
+			rule := models.FieldVisibility{Role: role, Field: field, Access: access}
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "role"}, {Name: "field"}},
+				DoUpdates: clause.AssignmentColumns([]string{"access"}),
+			}).Create(&rule).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PatientView returns the serializer applying a role's field visibility
+func (s *FieldVisibilityServiceImpl) PatientView(role string) (*PatientView, error) {
+	rules, err := s.Rules(role)
+	if err != nil {
+		return nil, err
+	}
+	return &PatientView{rules: rules}, nil
+}
+
+// PatientView turns patients into response objects with hidden fields left out and
+// masked fields shortened. Every response containing patient data goes through it.
+type PatientView struct {
+	rules map[string]string
+}
+
+// Access returns the access level of a patient field
+func (v *PatientView) Access(field string) string {
+	return v.rules[field]
+}
+
+// Patient returns the JSON object of a patient as the role may see it
+func (v *PatientView) Patient(patient *models.Patient) map[string]interface{} {
+	fields, err := toFieldMap(patient)
+	if err != nil {
+		// A plain struct of strings and times always marshals
+		panic(err)
+	}
+	for field, key := range models.PatientFields {
+		switch v.rules[field] {
+		case models.FieldHidden:
+			delete(fields, key)
+		case models.FieldMasked:
+			if value, ok := fields[key]; ok {
+				fields[key] = maskAll(value)
+			}
+		}
+	}
+	return fields
+}
+
+// Patients applies Patient to every patient of a list
+func (v *PatientView) Patients(patients []models.Patient) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(patients))
+	for i := range patients {
+		result = append(result, v.Patient(&patients[i]))
+	}
+	return result
+}
+
+// Appointment returns the JSON object of an appointment with its patient, if loaded, filtered
+func (v *PatientView) Appointment(appointment *models.Appointment) map[string]interface{} {
+	withoutPatient := *appointment
+	withoutPatient.Patient = nil
+	fields, err := toFieldMap(&withoutPatient)
+	if err != nil {
+		panic(err)
+	}
+	if appointment.Patient != nil {
+		fields["Patient"] = v.Patient(appointment.Patient)
+	}
+	return fields
+}
+
+// Appointments applies Appointment to every appointment of a list
+func (v *PatientView) Appointments(appointments []models.Appointment) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(appointments))
+	for i := range appointments {
+		result = append(result, v.Appointment(&appointments[i]))
+	}
+	return result
+}
+
+// Merge returns the JSON object of a patient merge with its field changes filtered like patient fields
+func (v *PatientView) Merge(merge *models.PatientMerge) map[string]interface{} {
+	var changes map[string]FieldChange
+	var moved map[string]interface{}
+	_ = json.Unmarshal([]byte(merge.FieldChanges), &changes)
+	_ = json.Unmarshal([]byte(merge.Moved), &moved)
+	for field, change := range changes {
+		switch v.rules[patientFieldOf(field)] {
+		case models.FieldHidden:
+			delete(changes, field)
+		case models.FieldMasked:
+			before, _ := change.Before.(string)
+			after, _ := change.After.(string)
+			changes[field] = FieldChange{Before: maskValue(before), After: maskValue(after)}
+		}
+	}
+	return map[string]interface{}{
+		"id":            merge.ID,
+		"target_id":     merge.TargetID,
+		"source_id":     merge.SourceID,
+		"merged_by":     merge.MergedBy,
+		"merged_at":     merge.CreatedAt,
+		"field_changes": changes,
+		"moved":         moved,
+		"unmerged_at":   merge.UnmergedAt,
+		"unmerged_by":   merge.UnmergedBy,
+	}
+}
+
+// clinicalNoteKeys are the keys of an encounter note's content in audit changes. They
+// follow the visibility of doctor_notes, the other clinical text about a patient.
+var clinicalNoteKeys = []string{"Subjective", "Objective", "Assessment", "Plan", "AmendmentReason"}
+
+// AuditChanges filters the field-level diff of an audit entry (models.AuditLog.Changes)
+// like patient fields, so the audit log shows no more than the patient endpoints do
+func (v *PatientView) AuditChanges(changes string) string {
+	if changes == "" {
+		return ""
+	}
+	var diff map[string]FieldChange
+	if err := json.Unmarshal([]byte(changes), &diff); err != nil {
+		// Entries are written by newAuditEntry, so this only happens to tampered ones
+		return ""
+	}
+	for key, change := range diff {
+		field := ""
+		for name, jsonKey := range models.PatientFields {
+			if jsonKey == key {
+				field = name
+			}
+		}
+		if slices.Contains(clinicalNoteKeys, key) {
+			field = "doctor_notes"
+		}
+		switch v.rules[field] {
+		case models.FieldHidden:
+			delete(diff, key)
+		case models.FieldMasked:
+			diff[key] = FieldChange{Before: maskAll(change.Before), After: maskAll(change.After)}
+		}
+	}
+	data, _ := json.Marshal(diff)
+	return string(data)
+}
+
+// maskAll masks every string in a JSON value, such as the parts of an address or the
+// names and numbers of emergency contacts
+func maskAll(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return maskValue(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			if key != "ID" && key != "PatientID" {
+				v[key] = maskAll(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = maskAll(item)
+		}
+	}
+	return value
+}
+
+// maskValue keeps only the last few characters of a value
+func maskValue(value string) string {
+	runes := []rune(value)
+	if len(runes) <= maskKeep {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-maskKeep) + string(runes[len(runes)-maskKeep:])
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"medical_app/models"
+	"medical_app/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MFAIssuer is the account issuer shown in authenticator apps
+	MFAIssuer = "Medical Portal"
+	// recoveryCodeCount is how many recovery codes are handed out at a time
+	recoveryCodeCount = 10
+)
+
+var (
+	// ErrMFAAlreadyEnabled is returned when starting enrollment for a user who already has MFA
+	ErrMFAAlreadyEnabled = newError(KindConflict, "mfa_already_enabled", "two-factor authentication is already enabled")
+	// ErrMFANotEnrolled is returned when confirming or using MFA before enrollment started
+	ErrMFANotEnrolled = newError(KindConflict, "mfa_not_enrolled", "two-factor authentication is not set up")
+	// ErrInvalidMFACode is returned for a wrong, expired or already used code
+	ErrInvalidMFACode = newError(KindUnauthorized, "invalid_mfa_code", "invalid authentication code")
+	// ErrMFARequired is returned when disabling MFA that the user's role requires
+	ErrMFARequired = newError(KindForbidden, "mfa_required", "two-factor authentication is required for this role")
+)
+
+// Login steps returned by MFAServiceImpl.LoginStep
+const (
+	LoginComplete        = ""
+	LoginNeedsMFA        = utils.PurposeMFAVerify
+	LoginNeedsEnrollment = utils.PurposeMFAEnroll
+)
+
+// MFAEnrollment is what a user needs to add the account to an authenticator app
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAService manages TOTP two-factor authentication, recovery codes and per-role policies
+type MFAService interface {
+	LoginStep(user *models.User) (string, error)
+	BeginEnrollment(user *models.User) (*MFAEnrollment, error)
+	ConfirmEnrollment(user *models.User, code string) ([]string, error)
+	Verify(user *models.User, code string) error
+	RegenerateRecoveryCodes(user *models.User, code string) ([]string, error)
+	Disable(user *models.User, code string) error
+	Reset(userID uint) error
+	IsRequired(role string) (bool, error)
+	ListPolicies() ([]models.MFAPolicy, error)
+	SetPolicy(role string, required bool) error
+}
+
+// MFAServiceImpl manages TOTP enrollment, recovery codes and the per-role MFA policy
+type MFAServiceImpl struct {
+	DB *gorm.DB
+}
+
+var _ MFAService = (*MFAServiceImpl)(nil)
+
+func NewMFAService(db *gorm.DB) *MFAServiceImpl {
+	return &MFAServiceImpl{DB: db}
+}
+
+// LoginStep decides what has to happen after a user's password was accepted
+func (s *MFAServiceImpl) LoginStep(user *models.User) (string, error) {
+	if user.MFAEnabled {
+		return LoginNeedsMFA, nil
+	}
+	required, err := s.IsRequired(user.Role)
+	if err != nil {
+		return "", err
+	}
+	if required {
+		return LoginNeedsEnrollment, nil
+	}
+	return LoginComplete, nil
+}
+
+// BeginEnrollment creates a new TOTP secret for the user. MFA stays off until the
+// user proves their app works with ConfirmEnrollment.
+func (s *MFAServiceImpl) BeginEnrollment(user *models.User) (*MFAEnrollment, error) {
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	secret := utils.GenerateTOTPSecret()
+	if err := s.DB.Model(user).Updates(map[string]interface{}{"mfa_secret": secret, "mfa_last_step": 0}).Error; err != nil {
+		log.Printf("Error starting MFA enrollment for user ID %d: %v", user.ID, err)
+		return nil, err
+	}
+	return &MFAEnrollment{Secret: secret, URI: utils.TOTPURI(MFAIssuer, user.Username, secret)}, nil
+}
+
+// ConfirmEnrollment turns MFA on once the user enters a valid code, returning fresh recovery codes
+func (s *MFAServiceImpl) ConfirmEnrollment(user *models.User, code string) ([]string, error) {
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.MFASecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	var codes []string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		step, ok := utils.ValidateTOTP(user.MFASecret, code, time.Now(), user.MFALastStep)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{"mfa_enabled": true, "mfa_last_step": step}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a TOTP code or, failing that, an unused recovery code
+func (s *MFAServiceImpl) Verify(user *models.User, code string) error {
+	if !user.MFAEnabled {
+		return ErrMFANotEnrolled
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		// Reload under lock so two requests cannot both accept the same code
+		var current models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, user.ID).Error; err != nil {
+			return err
+		}
+		if step, ok := utils.ValidateTOTP(current.MFASecret, code, time.Now(), current.MFALastStep); ok {
+			return tx.Model(&current).Update("mfa_last_step", step).Error
+		}
+
+		var recovery models.RecoveryCode
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+			First(&recovery).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidMFACode
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		log.Printf("Recovery code used by user ID %d", user.ID)
+		return tx.Model(&recovery).Update("used_at", &now).Error
+	})
+}
+
+// RegenerateRecoveryCodes replaces all of the user's recovery codes after checking a code
+func (s *MFAServiceImpl) RegenerateRecoveryCodes(user *models.User, code string) ([]string, error) {
+	if err := s.Verify(user, code); err != nil {
+		return nil, err
+	}
+	var codes []string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// Disable turns MFA off for the user after checking a code, unless their role requires it
+func (s *MFAServiceImpl) Disable(user *models.User, code string) error {
+	required, err := s.IsRequired(user.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequired
+	}
+	if err := s.Verify(user, code); err != nil {
+		return err
+	}
+	return s.Reset(user.ID)
+}
+
+// Reset removes a user's MFA setup entirely, e.g. when an admin helps after a lost phone
+func (s *MFAServiceImpl) Reset(userID uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_enabled":   false,
+			"mfa_secret":    "",
+			"mfa_last_step": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// IsRequired reports whether users of the role must use MFA
+func (s *MFAServiceImpl) IsRequired(role string) (bool, error) {
+	var policy models.MFAPolicy
+	result := s.DB.Where("role = ?", role).Limit(1).Find(&policy)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0 && policy.Required, nil
+}
+
+// ListPolicies returns the MFA policy of every role
+func (s *MFAServiceImpl) ListPolicies() ([]models.MFAPolicy, error) {
+	var roles []string
+	if err := s.DB.Model(&models.Role{}).Order("name ASC").Pluck("name", &roles).Error; err != nil {
+		return nil, err
+	}
+	policies := make([]models.MFAPolicy, 0, len(roles))
+	for _, role := range roles {
+		required, err := s.IsRequired(role)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, models.MFAPolicy{Role: role, Required: required})
+	}
+	return policies, nil
+}
+
+// SetPolicy requires or stops requiring MFA for a role. Users of the role without MFA
+// are asked to enroll on their next login.
+func (s *MFAServiceImpl) SetPolicy(role string, required bool) error {
+	exists, err := roleExists(s.DB, role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrInvalidRole
+	}
+	policy := models.MFAPolicy{Role: role, Required: required}
+	return s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "role"}},
+		DoUpdates: clause.AssignmentColumns([]string{"required", "updated_at"}),
+	}).Create(&policy).Error
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores a new set, returned in plain text
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw := utils.RandomToken(5) // 10 hex characters
+		codes[i] = raw[:5] + "-" + raw[5:]
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(codes[i])}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode normalizes a recovery code as typed by a user and hashes it
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashToken(normalized)
+}
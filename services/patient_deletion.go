@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"medical_app/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPatientNotDeleted is returned when restoring or purging a patient that is not deleted
+var ErrPatientNotDeleted = newError(KindConflict, "patient_not_deleted", "patient is not deleted")
+
+// ListDeletedPatients returns a page of soft-deleted patients, most recently deleted first
+func (s *PatientServiceImpl) ListDeletedPatients(page, pageSize int) (*PatientPage, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > MaxPatientPageSize {
+		pageSize = DefaultPatientPageSize
+	}
+
+	query := s.reader().Unscoped().Model(&models.Patient{}).Where("deleted_at IS NOT NULL").Session(&gorm.Session{})
+	result := &PatientPage{Page: page, PageSize: pageSize, Patients: []models.Patient{}}
+	if err := query.Count(&result.Total).Error; err != nil {
+		log.Printf("Error counting deleted patients: %v", err)
+		return nil, err
+	}
+	if err := query.Order("deleted_at DESC, id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&result.Patients).Error; err != nil {
+		log.Printf("Error listing deleted patients: %v", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// RestorePatient undoes the deletion of a patient. It fails with ErrPatientMerged when the
+// patient was deleted by a merge.
+func (s *PatientServiceImpl) RestorePatient(id uint) (*models.Patient, error) {
+	var patient models.Patient
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockDeletedPatient(tx, id, &patient); err != nil {
+			return err
+		}
+		if err := checkNotMergeSource(tx, id); err != nil {
+			return err
+		}
+		err := tx.Unscoped().Model(&models.Patient{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
+			return err
+		}
+		patient = models.Patient{}
+		return tx.First(&patient, id).Error
+	})
+	if err != nil {
+		if !errors.Is(err, ErrPatientNotFound) && !errors.Is(err, ErrPatientNotDeleted) && !errors.Is(err, ErrPatientMerged) {
+			log.Printf("Error restoring patient ID %d: %v", id, err)
+		}
+		return nil, err
+	}
+	return &patient, nil
+}
+
+// PurgePatient permanently removes a deleted patient together with their appointments,
+// encounter notes, status history and emergency contacts. Audit log entries are kept.
+// The source of a merge that was not undone is kept for UnmergePatients (ErrPatientMerged).
+func (s *PatientServiceImpl) PurgePatient(id uint) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var patient models.Patient
+		if err := lockDeletedPatient(tx, id, &patient); err != nil {
+			return err
+		}
+		if err := checkNotMergeSource(tx, id); err != nil {
+			return err
+		}
+		return purgePatient(tx, id)
+	})
+	if err != nil && !errors.Is(err, ErrPatientNotFound) && !errors.Is(err, ErrPatientNotDeleted) && !errors.Is(err, ErrPatientMerged) {
+		log.Printf("Error purging patient ID %d: %v", id, err)
+	}
+	return err
+}
+
+// PurgeExpiredPatients permanently removes every patient that was deleted longer than
+// retention ago and returns their IDs. Sources of merges that were not undone are kept,
+// as purging them would make the merge final.
+func (s *PatientServiceImpl) PurgeExpiredPatients(retention time.Duration) ([]uint, error) {
+	var ids []uint
+	cutoff := time.Now().Add(-retention)
+	activeSources := s.DB.Model(&models.PatientMerge{}).Select("source_id").Where("unmerged_at IS NULL")
+	err := s.DB.Unscoped().Model(&models.Patient{}).Where("deleted_at < ? AND id NOT IN (?)", cutoff, activeSources).Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	purged := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if err := s.PurgePatient(id); err != nil {
+			// Restored or merged since the lookup
+			if errors.Is(err, ErrPatientNotDeleted) || errors.Is(err, ErrPatientNotFound) || errors.Is(err, ErrPatientMerged) {
+				continue
+			}
+			return purged, err
+		}
+		purged = append(purged, id)
+	}
+	return purged, nil
+}
+
+// lockDeletedPatient loads and locks a patient that must be soft-deleted
+func lockDeletedPatient(tx *gorm.DB, id uint, patient *models.Patient) error {
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(patient, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPatientNotFound
+		}
+		return err
+	}
+	if !patient.DeletedAt.Valid {
+		return ErrPatientNotDeleted
+	}
+	return nil
+}
+
+// checkNotMergeSource returns ErrPatientMerged when the patient is the source of a merge
+// that was not undone
+func checkNotMergeSource(tx *gorm.DB, id uint) error {
+	var merges int64
+	if err := tx.Model(&models.PatientMerge{}).Where("source_id = ? AND unmerged_at IS NULL", id).Count(&merges).Error; err != nil {
+		return err
+	}
+	if merges > 0 {
+		return ErrPatientMerged
+	}
+	return nil
+}
+
+// purgePatient hard-deletes a patient and the records that belong to them
+func purgePatient(tx *gorm.DB, id uint) error {
+	dependents := []interface{}{&models.PatientStatusChange{}, &models.EncounterNote{}, &models.Appointment{}, &models.EmergencyContact{}}
+	for _, model := range dependents {
+		if err := tx.Unscoped().Where("patient_id = ?", id).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Delete(&models.Patient{}, id).Error
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"medical_app/models"
+	"medical_app/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrMRNUnavailable is returned when no unused medical record number could be generated
+var ErrMRNUnavailable = errors.New("could not generate a unique medical record number")
+
+const (
+	// mrnAttempts is how often a random medical record number is drawn before giving up
+	mrnAttempts = 5
+	// maxEmergencyContacts caps the emergency contacts of one patient
+	maxEmergencyContacts = 5
+)
+
+// addressParts are the keys of a structured address in requests and patches
+var addressParts = []string{"line1", "line2", "city", "state", "postal_code", "country"}
+
+// patientColumns maps the patchable patient fields to their columns. Address parts are
+// written as "address.<part>".
+var patientColumns = map[string]string{
+	"first_name":          "first_name",
+	"last_name":           "last_name",
+	"dob":                 "birth_date",
+	"gender":              "gender",
+	"phone":               "phone",
+	"secondary_phone":     "secondary_phone",
+	"email":               "email",
+	"address.line1":       "address_line1",
+	"address.line2":       "address_line2",
+	"address.city":        "address_city",
+	"address.state":       "address_state",
+	"address.postal_code": "address_postal_code",
+	"address.country":     "address_country",
+	"doctor_notes":        "doctor_notes",
+}
+
+// patientFieldValue returns the value of a patchable field as it is written in requests
+func patientFieldValue(patient *models.Patient, key string) string {
+	switch key {
+	case "first_name":
+		return patient.FirstName
+	case "last_name":
+		return patient.LastName
+	case "dob":
+		if patient.DOB == nil {
+			return ""
+		}
+		return patient.DOB.String()
+	case "gender":
+		return patient.Gender
+	case "phone":
+		return patient.Phone
+	case "secondary_phone":
+		return patient.SecondaryPhone
+	case "email":
+		return patient.Email
+	case "address.line1":
+		return patient.Address.Line1
+	case "address.line2":
+		return patient.Address.Line2
+	case "address.city":
+		return patient.Address.City
+	case "address.state":
+		return patient.Address.State
+	case "address.postal_code":
+		return patient.Address.PostalCode
+	case "address.country":
+		return patient.Address.Country
+	case "doctor_notes":
+		return patient.DoctorNotes
+	}
+	return ""
+}
+
+// patientFieldOf returns the field a patch key belongs to, e.g. "address" for "address.city"
+func patientFieldOf(key string) string {
+	field, _, _ := strings.Cut(key, ".")
+	return field
+}
+
+// normalizePatientValue validates the value of a patchable field and returns what is
+// stored in its column. An empty value clears the field; for gender that means "unknown".
+func normalizePatientValue(key, value string) (interface{}, error) {
+	value = strings.TrimSpace(value)
+	switch key {
+	case "dob":
+		dob, err := ParsePatientDOB(value)
+		if err != nil || dob == nil {
+			return nil, err
+		}
+		return *dob, nil
+	case "gender":
+		return normalizeGender(value)
+	case "phone", "secondary_phone":
+		return utils.NormalizePhone(value)
+	case "email":
+		return utils.NormalizeEmail(value)
+	}
+	return value, nil
+}
+
+// ParsePatientDOB parses a "YYYY-MM-DD" date of birth, which may not be in the future or
+// implausibly long ago. An empty value yields nil.
+func ParsePatientDOB(value string) (*models.Date, error) {
+	if value == "" {
+		return nil, nil
+	}
+	dob, err := models.ParseDate(value)
+	if err != nil {
+		return nil, errors.New("must be a date in YYYY-MM-DD format")
+	}
+	if err := dob.CheckBirthDate(); err != nil {
+		return nil, err
+	}
+	return &dob, nil
+}
+
+// normalizeGender accepts the values of models.Genders in any case
+func normalizeGender(value string) (string, error) {
+	if value == "" {
+		return models.GenderUnknown, nil
+	}
+	gender := strings.ToLower(value)
+	if !slices.Contains(models.Genders, gender) {
+		return "", errors.New("must be one of " + strings.Join(models.Genders, ", "))
+	}
+	return gender, nil
+}
+
+// NormalizePatient validates a new patient and brings its fields into their stored form.
+// Invalid fields are reported as FieldErrors.
+func NormalizePatient(patient *models.Patient) error {
+	fieldErrors := FieldErrors{}
+	patient.FirstName = strings.TrimSpace(patient.FirstName)
+	patient.LastName = strings.TrimSpace(patient.LastName)
+	if patient.FirstName == "" {
+		fieldErrors["first_name"] = "is required"
+	}
+	if patient.LastName == "" {
+		fieldErrors["last_name"] = "is required"
+	}
+	if patient.DOB != nil {
+		if err := patient.DOB.CheckBirthDate(); err != nil {
+			fieldErrors["dob"] = err.Error()
+		}
+	}
+
+	var err error
+	if patient.Gender, err = normalizeGender(strings.TrimSpace(patient.Gender)); err != nil {
+		fieldErrors["gender"] = err.Error()
+	}
+	if patient.Phone, err = utils.NormalizePhone(strings.TrimSpace(patient.Phone)); err != nil {
+		fieldErrors["phone"] = err.Error()
+	}
+	if patient.SecondaryPhone, err = utils.NormalizePhone(strings.TrimSpace(patient.SecondaryPhone)); err != nil {
+		fieldErrors["secondary_phone"] = err.Error()
+	}
+	if patient.Email, err = utils.NormalizeEmail(strings.TrimSpace(patient.Email)); err != nil {
+		fieldErrors["email"] = err.Error()
+	}
+	if patient.Phone == "" && patient.Email == "" && fieldErrors["phone"] == "" && fieldErrors["email"] == "" {
+		fieldErrors["phone"] = "a phone number or an email address is required"
+	}
+	patient.Address = trimAddress(patient.Address)
+
+	if err := normalizeEmergencyContacts(patient.EmergencyContacts); err != nil {
+		var contactErrors FieldErrors
+		if !errors.As(err, &contactErrors) {
+			return err
+		}
+		for field, reason := range contactErrors {
+			fieldErrors[field] = reason
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	return nil
+}
+
+// trimAddress trims every part of an address
+func trimAddress(address models.Address) models.Address {
+	return models.Address{
+		Line1:      strings.TrimSpace(address.Line1),
+		Line2:      strings.TrimSpace(address.Line2),
+		City:       strings.TrimSpace(address.City),
+		State:      strings.TrimSpace(address.State),
+		PostalCode: strings.TrimSpace(address.PostalCode),
+		Country:    strings.TrimSpace(address.Country),
+	}
+}
+
+// normalizeEmergencyContacts validates emergency contacts in place. Each needs a name
+// and a phone number or email address.
+func normalizeEmergencyContacts(contacts []models.EmergencyContact) error {
+	fieldErrors := FieldErrors{}
+	if len(contacts) > maxEmergencyContacts {
+		fieldErrors["emergency_contacts"] = fmt.Sprintf("at most %d emergency contacts are allowed", maxEmergencyContacts)
+	}
+	for i := range contacts {
+		contact := &contacts[i]
+		prefix := fmt.Sprintf("emergency_contacts[%d].", i)
+		contact.ID, contact.PatientID = 0, 0
+		contact.Name = strings.TrimSpace(contact.Name)
+		contact.Relationship = strings.TrimSpace(contact.Relationship)
+		if contact.Name == "" {
+			fieldErrors[prefix+"name"] = "is required"
+		}
+		var err error
+		if contact.Phone, err = utils.NormalizePhone(strings.TrimSpace(contact.Phone)); err != nil {
+			fieldErrors[prefix+"phone"] = err.Error()
+		}
+		if contact.Email, err = utils.NormalizeEmail(strings.TrimSpace(contact.Email)); err != nil {
+			fieldErrors[prefix+"email"] = err.Error()
+		}
+		if contact.Phone == "" && contact.Email == "" && fieldErrors[prefix+"phone"] == "" && fieldErrors[prefix+"email"] == "" {
+			fieldErrors[prefix+"phone"] = "a phone number or an email address is required"
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	return nil
+}
+
+// ReplaceEmergencyContacts replaces all emergency contacts of a patient and returns the
+// patient before and after. A non-zero version must match the stored one.
+func (s *PatientServiceImpl) ReplaceEmergencyContacts(id, version uint, contacts []models.EmergencyContact) (*models.Patient, *models.Patient, error) {
+	if err := normalizeEmergencyContacts(contacts); err != nil {
+		return nil, nil, err
+	}
+	var previous []models.EmergencyContact
+	before, after, err := s.updateVersioned(id, version, func(tx *gorm.DB, _ *models.Patient) (map[string]interface{}, error) {
+		if err := tx.Where("patient_id = ?", id).Order("id").Find(&previous).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("patient_id = ?", id).Delete(&models.EmergencyContact{}).Error; err != nil {
+			return nil, err
+		}
+		for i := range contacts {
+			contacts[i].PatientID = id
+		}
+		if len(contacts) > 0 {
+			if err := tx.Create(&contacts).Error; err != nil {
+				return nil, err
+			}
+		}
+		// Touch the patient so the version and ETag change
+		return map[string]interface{}{"updated_at": time.Now()}, nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrPatientNotFound) && !errors.Is(err, ErrPatientVersionConflict) {
+			log.Printf("Error replacing emergency contacts of patient ID %d: %v", id, err)
+		}
+		return nil, nil, err
+	}
+	before.EmergencyContacts = previous
+	after.EmergencyContacts = contacts
+	return before, after, nil
+}
+
+// generateMRN draws random medical record numbers (see utils.RandomMRN) until it finds an
+// unused one
+func generateMRN(tx *gorm.DB) (string, error) {
+	for attempt := 0; attempt < mrnAttempts; attempt++ {
+		mrn, err := utils.RandomMRN()
+		if err != nil {
+			return "", err
+		}
+
+		var used int64
+		if err := tx.Unscoped().Model(&models.Patient{}).Where("mrn = ?", mrn).Count(&used).Error; err != nil {
+			return "", err
+		}
+		if used == 0 {
+			return mrn, nil
+		}
+	}
+	return "", ErrMRNUnavailable
+}
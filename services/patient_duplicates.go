@@ -0,0 +1,222 @@
+package services
+
+import (
+	"log"
+	"medical_app/models"
+	"medical_app/utils"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DuplicateThreshold is the lowest score reported as a possible duplicate
+	DuplicateThreshold = 0.65
+	// maxDuplicateMatches caps how many possible duplicates are returned
+	maxDuplicateMatches = 10
+	// duplicateNamePrefix is how many letters of the last name a candidate has to share
+	duplicateNamePrefix = 3
+)
+
+// duplicateWeights is how much each signal contributes to a duplicate score. A missing
+// DOB or contact (phone numbers and email) on either side counts as half a match, so it
+// neither proves nor rules out a duplicate.
+var duplicateWeights = struct {
+	Name, DOB, Contact float64
+}{Name: 0.5, DOB: 0.3, Contact: 0.2}
+
+// Reasons reported with a duplicate match
+const (
+	DuplicateSameName    = "same name"
+	DuplicateSimilarName = "similar name"
+	DuplicateSameDOB     = "same date of birth"
+	DuplicateSimilarDOB  = "similar date of birth"
+	DuplicateSameContact = "same contact"
+)
+
+// DuplicateMatch is an existing patient that may be the same person as a candidate
+type DuplicateMatch struct {
+	Patient models.Patient
+	Score   float64
+	Reasons []string
+}
+
+// FindDuplicates scores the patients that share a block with the candidate (see
+// duplicateBlocks) against its name, DOB and contact and returns the likely duplicates,
+// best first. The candidate itself (same ID) is skipped.
+func (s *PatientServiceImpl) FindDuplicates(candidate *models.Patient) ([]DuplicateMatch, error) {
+	matches := []DuplicateMatch{}
+	var batch []models.Patient
+	err := s.DB.Model(&models.Patient{}).Where(duplicateBlocks(candidate)).FindInBatches(&batch, searchBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, patient := range batch {
+			if candidate.ID != 0 && patient.ID == candidate.ID {
+				continue
+			}
+			if score, reasons := scoreDuplicate(candidate, &patient); score >= DuplicateThreshold {
+				matches = append(matches, DuplicateMatch{Patient: patient, Score: score, Reasons: reasons})
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		log.Printf("Error looking for duplicate patients: %v", err)
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Patient.ID < matches[j].Patient.ID
+	})
+	if len(matches) > maxDuplicateMatches {
+		matches = matches[:maxDuplicateMatches]
+	}
+	return matches, nil
+}
+
+// duplicateBlocks narrows the patients to score to those born in the same year as the
+// candidate, with a first or last name starting like its last name (names may be swapped)
+// or with one of its phone numbers or its email address. A patient outside every block
+// would need a typo at the start of the name and no date of birth or contact in common.
+func duplicateBlocks(candidate *models.Patient) clause.Expression {
+	blocks := []clause.Expression{}
+	if candidate.DOB != nil {
+		year := candidate.DOB.Year()
+		blocks = append(blocks, clause.Expr{SQL: "birth_date >= ? AND birth_date < ?",
+			Vars: []interface{}{models.NewDate(year, time.January, 1), models.NewDate(year+1, time.January, 1)}})
+	}
+	if words := strings.Fields(utils.NormalizeText(candidate.LastName)); len(words) > 0 {
+		prefix := []rune(words[0])
+		prefix = prefix[:min(len(prefix), duplicateNamePrefix)]
+		blocks = append(blocks, clause.Expr{SQL: "lower(last_name) LIKE ? OR lower(first_name) LIKE ?",
+			Vars: []interface{}{string(prefix) + "%", string(prefix) + "%"}})
+	}
+	for _, contact := range contactsOf(candidate) {
+		if digits := utils.DigitsOnly(contact); !strings.Contains(contact, "@") && len(digits) >= 7 {
+			// Phone numbers are stored as digits with an optional "+", see sameContact
+			blocks = append(blocks, clause.Expr{SQL: "phone LIKE ? OR secondary_phone LIKE ?",
+				Vars: []interface{}{"%" + lastN(digits, 9), "%" + lastN(digits, 9)}})
+			continue
+		}
+		blocks = append(blocks, clause.Expr{SQL: "lower(email) = ?", Vars: []interface{}{strings.ToLower(strings.TrimSpace(contact))}})
+	}
+	return clause.Or(blocks...)
+}
+
+// scoreDuplicate returns how likely a and b are the same person, between 0 and 1
+func scoreDuplicate(a, b *models.Patient) (float64, []string) {
+	reasons := []string{}
+
+	name := nameSimilarity(a, b)
+	switch {
+	case name == 1:
+		reasons = append(reasons, DuplicateSameName)
+	case name >= 0.7:
+		reasons = append(reasons, DuplicateSimilarName)
+	}
+
+	dob := 0.5
+	aDOB, bDOB := patientFieldValue(a, "dob"), patientFieldValue(b, "dob")
+	if aDOB != "" && bDOB != "" {
+		switch {
+		case aDOB == bDOB:
+			dob = 1
+			reasons = append(reasons, DuplicateSameDOB)
+		case utils.Levenshtein(aDOB, bDOB) == 1 || swappedDayMonth(aDOB, bDOB):
+			// One mistyped digit or day and month swapped
+			dob = 0.6
+			reasons = append(reasons, DuplicateSimilarDOB)
+		default:
+			dob = 0
+		}
+	}
+
+	contact := 0.5
+	aContacts, bContacts := contactsOf(a), contactsOf(b)
+	if len(aContacts) > 0 && len(bContacts) > 0 {
+		contact = 0
+		if sharesContact(aContacts, bContacts) {
+			contact = 1
+			reasons = append(reasons, DuplicateSameContact)
+		}
+	}
+
+	score := name*duplicateWeights.Name + dob*duplicateWeights.DOB + contact*duplicateWeights.Contact
+	return score, reasons
+}
+
+// nameSimilarity compares first and last names, also with the two swapped
+func nameSimilarity(a, b *models.Patient) float64 {
+	aFirst, aLast := utils.NormalizeText(a.FirstName), utils.NormalizeText(a.LastName)
+	bFirst, bLast := utils.NormalizeText(b.FirstName), utils.NormalizeText(b.LastName)
+	straight := (wordSimilarity(aFirst, bFirst) + wordSimilarity(aLast, bLast)) / 2
+	swapped := (wordSimilarity(aFirst, bLast) + wordSimilarity(aLast, bFirst)) / 2
+	return max(straight, swapped)
+}
+
+// wordSimilarity is 1 for equal names and otherwise the better of the edit distance
+// ratio and the trigram similarity
+func wordSimilarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	longest := max(len([]rune(a)), len([]rune(b)))
+	edit := 1 - float64(utils.Levenshtein(a, b))/float64(longest)
+	return max(edit, utils.TrigramSimilarity(a, b))
+}
+
+// swappedDayMonth reports whether two YYYY-MM-DD dates differ only by day and month being swapped
+func swappedDayMonth(a, b string) bool {
+	if len(a) != 10 || len(b) != 10 {
+		return false
+	}
+	return a[:4] == b[:4] && a[5:7] == b[8:10] && a[8:10] == b[5:7]
+}
+
+// contactsOf returns the phone numbers and email address of a patient that are set
+func contactsOf(patient *models.Patient) []string {
+	contacts := []string{}
+	for _, value := range []string{patient.Phone, patient.SecondaryPhone, patient.Email} {
+		if value != "" {
+			contacts = append(contacts, value)
+		}
+	}
+	return contacts
+}
+
+// sharesContact reports whether any phone number or email address appears in both lists
+func sharesContact(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if sameContact(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameContact compares phone numbers by their digits and anything else case-insensitively
+func sameContact(a, b string) bool {
+	aDigits, bDigits := utils.DigitsOnly(a), utils.DigitsOnly(b)
+	if !strings.Contains(a, "@") && !strings.Contains(b, "@") && len(aDigits) >= 7 && len(bDigits) >= 7 {
+		// Ignore country codes and trunk prefixes by comparing the last 9 digits
+		return lastN(aDigits, 9) == lastN(bDigits, 9)
+	}
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// lastN returns the last n bytes of s, or s when it is shorter
+func lastN(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"medical_app/models"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrMergeSamePatient is returned when a patient would be merged into itself
+	ErrMergeSamePatient = newError(KindValidation, "merge_same_patient", "a patient cannot be merged into itself")
+	// ErrMergeNotFound is returned when a merge record does not exist
+	ErrMergeNotFound = newError(KindNotFound, "merge_not_found", "merge not found")
+	// ErrMergeUndone is returned when unmerging a merge that was already undone
+	ErrMergeUndone = newError(KindConflict, "merge_undone", "merge has already been undone")
+	// ErrMergeNotLatest is returned when a later merge involves the target; it has to be undone first
+	ErrMergeNotLatest = newError(KindConflict, "merge_not_latest", "a later merge involves this patient, undo it first")
+	// ErrPatientMerged is returned when restoring or purging a patient that was merged into
+	// another one
+	ErrPatientMerged = newError(KindConflict, "patient_merged", "patient was merged into another patient, undo the merge instead")
+)
+
+// mergeFields are the demographic fields combined by a merge. The target keeps its value
+// unless it is empty or the field is listed in MergeOptions.UseSource. The address is
+// taken as a whole so that parts of two addresses are never mixed.
+var mergeFields = []string{"first_name", "last_name", "dob", "gender", "phone", "secondary_phone", "email", "address"}
+
+// MergeOptions controls how two patients are merged
+type MergeOptions struct {
+	UseSource []string // Fields taken from the source even where the target has a value
+	MergedBy  string   // Username recorded on the merge
+}
+
+// MergeResult is the outcome of a merge or unmerge, with the records needed for auditing
+type MergeResult struct {
+	Merge        *models.PatientMerge
+	TargetBefore *models.Patient
+	Target       *models.Patient
+	Source       *models.Patient // For a merge the source before it was deleted, for an unmerge after it was restored
+}
+
+// mergedRecords lists the related records a merge moved from the source to the target
+type mergedRecords struct {
+	Appointments      []uint `json:"appointments"`
+	EncounterNotes    []uint `json:"encounter_notes"`
+	StatusChanges     []uint `json:"status_changes"`
+	EmergencyContacts []uint `json:"emergency_contacts"`
+}
+
+// relatedPatientRecords are the tables whose rows follow a patient through a merge
+var relatedPatientRecords = []struct {
+	model interface{}
+	ids   func(*mergedRecords) *[]uint
+}{
+	{&models.Appointment{}, func(m *mergedRecords) *[]uint { return &m.Appointments }},
+	{&models.EncounterNote{}, func(m *mergedRecords) *[]uint { return &m.EncounterNotes }},
+	{&models.PatientStatusChange{}, func(m *mergedRecords) *[]uint { return &m.StatusChanges }},
+	{&models.EmergencyContact{}, func(m *mergedRecords) *[]uint { return &m.EmergencyContacts }},
+}
+
+// MergePatients folds the source patient into the target: empty target fields (and those in
+// opts.UseSource) take the source's values, doctor notes are combined, appointments, encounter
+// notes, status history and emergency contacts move to the target and the source is deleted. A non-zero version
+// must match the target's. The merge is recorded so that UnmergePatients can undo it.
+func (s *PatientServiceImpl) MergePatients(targetID, version, sourceID uint, opts MergeOptions) (*MergeResult, error) {
+	if targetID == sourceID {
+		return nil, ErrMergeSamePatient
+	}
+	for _, field := range opts.UseSource {
+		if !slices.Contains(mergeFields, field) {
+			return nil, FieldErrors{"use_source": "must only contain " + strings.Join(mergeFields, ", ")}
+		}
+	}
+
+	result := &MergeResult{}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		target, source, err := lockPatientPair(tx, targetID, sourceID)
+		if err != nil {
+			return err
+		}
+		if version != 0 && target.Version != version {
+			return ErrPatientVersionConflict
+		}
+		result.TargetBefore, result.Source = target, source
+
+		updates, changes := mergedValues(target, source, opts.UseSource)
+
+		var moved mergedRecords
+		for _, related := range relatedPatientRecords {
+			ids := related.ids(&moved)
+			if err := tx.Unscoped().Model(related.model).Where("patient_id = ?", sourceID).Pluck("id", ids).Error; err != nil {
+				return err
+			}
+			if len(*ids) == 0 {
+				continue
+			}
+			if err := tx.Unscoped().Model(related.model).Where("id IN ?", *ids).Update("patient_id", targetID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&models.Patient{}, sourceID).Error; err != nil {
+			return err
+		}
+		updates["version"] = gorm.Expr("version + 1")
+		if err := tx.Model(&models.Patient{}).Where("id = ?", targetID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		changesJSON, _ := json.Marshal(changes)
+		movedJSON, _ := json.Marshal(moved)
+		result.Merge = &models.PatientMerge{
+			TargetID:     targetID,
+			SourceID:     sourceID,
+			MergedBy:     opts.MergedBy,
+			FieldChanges: string(changesJSON),
+			Moved:        string(movedJSON),
+		}
+		if err := tx.Create(result.Merge).Error; err != nil {
+			return err
+		}
+		result.Target = &models.Patient{}
+		return tx.First(result.Target, targetID).Error
+	})
+	if err != nil {
+		if !isExpectedMergeError(err) {
+			log.Printf("Error merging patient ID %d into %d: %v", sourceID, targetID, err)
+		}
+		return nil, err
+	}
+	log.Printf("Patient ID %d merged into %d by %s", sourceID, targetID, opts.MergedBy)
+	return result, nil
+}
+
+// UnmergePatients undoes a merge: target fields the merge changed are set back unless they
+// were edited since, the moved records return to the source and the source is restored.
+// Only the latest merge involving the target can be undone.
+func (s *PatientServiceImpl) UnmergePatients(mergeID uint, unmergedBy string) (*MergeResult, error) {
+	result := &MergeResult{}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var merge models.PatientMerge
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&merge, mergeID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMergeNotFound
+			}
+			return err
+		}
+		if merge.UnmergedAt != nil {
+			return ErrMergeUndone
+		}
+		var later int64
+		err := tx.Model(&models.PatientMerge{}).
+			Where("id > ? AND unmerged_at IS NULL AND (target_id = ? OR source_id = ?)", merge.ID, merge.TargetID, merge.TargetID).
+			Count(&later).Error
+		if err != nil {
+			return err
+		}
+		if later > 0 {
+			return ErrMergeNotLatest
+		}
+
+		var target models.Patient
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&target, merge.TargetID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPatientNotFound
+			}
+			return err
+		}
+		result.TargetBefore = &target
+
+		var changes map[string]FieldChange
+		var moved mergedRecords
+		if err := json.Unmarshal([]byte(merge.FieldChanges), &changes); err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(merge.Moved), &moved); err != nil {
+			return err
+		}
+
+		// Revert only the fields that still hold the merged value
+		updates := map[string]interface{}{"version": gorm.Expr("version + 1")}
+		for key, change := range changes {
+			column, known := patientColumns[key]
+			before, _ := change.Before.(string)
+			if !known || patientFieldValue(&target, key) != change.After {
+				continue
+			}
+			value, err := normalizePatientValue(key, before)
+			if err != nil {
+				return err
+			}
+			updates[column] = value
+		}
+		if err := tx.Model(&models.Patient{}).Where("id = ?", target.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		for _, related := range relatedPatientRecords {
+			ids := *related.ids(&moved)
+			if len(ids) == 0 {
+				continue
+			}
+			err := tx.Unscoped().Model(related.model).Where("id IN ? AND patient_id = ?", ids, target.ID).Update("patient_id", merge.SourceID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		restored := tx.Unscoped().Model(&models.Patient{}).Where("id = ? AND deleted_at IS NOT NULL", merge.SourceID).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+		if restored.Error != nil {
+			return restored.Error
+		}
+		if restored.RowsAffected == 0 {
+			// Purged after the merge, so there is nothing to restore
+			return ErrPatientNotFound
+		}
+
+		now := time.Now()
+		merge.UnmergedAt = &now
+		merge.UnmergedBy = unmergedBy
+		if err := tx.Save(&merge).Error; err != nil {
+			return err
+		}
+		result.Merge = &merge
+
+		result.Target, result.Source = &models.Patient{}, &models.Patient{}
+		if err := tx.First(result.Target, merge.TargetID).Error; err != nil {
+			return err
+		}
+		return tx.First(result.Source, merge.SourceID).Error
+	})
+	if err != nil {
+		if !isExpectedMergeError(err) {
+			log.Printf("Error undoing patient merge ID %d: %v", mergeID, err)
+		}
+		return nil, err
+	}
+	log.Printf("Patient merge ID %d undone by %s", mergeID, unmergedBy)
+	return result, nil
+}
+
+// ListMerges returns the merges a patient took part in (every merge when patientID is 0), newest first
+func (s *PatientServiceImpl) ListMerges(patientID uint) ([]models.PatientMerge, error) {
+	query := s.DB.Model(&models.PatientMerge{})
+	if patientID != 0 {
+		query = query.Where("target_id = ? OR source_id = ?", patientID, patientID)
+	}
+	merges := []models.PatientMerge{}
+	if err := query.Order("id DESC").Find(&merges).Error; err != nil {
+		log.Printf("Error listing patient merges: %v", err)
+		return nil, err
+	}
+	return merges, nil
+}
+
+// lockPatientPair locks two patients in ID order, so concurrent merges cannot deadlock
+func lockPatientPair(tx *gorm.DB, targetID, sourceID uint) (*models.Patient, *models.Patient, error) {
+	var patients []models.Patient
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", []uint{targetID, sourceID}).Order("id").Find(&patients).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	var target, source *models.Patient
+	for i := range patients {
+		switch patients[i].ID {
+		case targetID:
+			target = &patients[i]
+		case sourceID:
+			source = &patients[i]
+		}
+	}
+	if target == nil || source == nil {
+		return nil, nil, ErrPatientNotFound
+	}
+	return target, source, nil
+}
+
+// mergedValues works out the target's new column values and the changes they make, keyed
+// like patch fields
+func mergedValues(target, source *models.Patient, useSource []string) (map[string]interface{}, map[string]FieldChange) {
+	updates := map[string]interface{}{}
+	changes := map[string]FieldChange{}
+	take := func(key, value string) {
+		// Values read from the database are already valid
+		stored, _ := normalizePatientValue(key, value)
+		updates[patientColumns[key]] = stored
+		changes[key] = FieldChange{Before: patientFieldValue(target, key), After: value}
+	}
+
+	for _, field := range mergeFields {
+		preferSource := slices.Contains(useSource, field)
+		if field == "address" {
+			if !source.Address.IsZero() && source.Address != target.Address && (target.Address.IsZero() || preferSource) {
+				for _, part := range addressParts {
+					key := "address." + part
+					if value := patientFieldValue(source, key); value != patientFieldValue(target, key) {
+						take(key, value)
+					}
+				}
+			}
+			continue
+		}
+		before, value := patientFieldValue(target, field), patientFieldValue(source, field)
+		targetEmpty := before == "" || (field == "gender" && before == models.GenderUnknown)
+		sourceEmpty := value == "" || (field == "gender" && value == models.GenderUnknown)
+		if !sourceEmpty && value != before && (targetEmpty || preferSource) {
+			take(field, value)
+		}
+	}
+
+	if source.DoctorNotes != "" && !strings.Contains(target.DoctorNotes, source.DoctorNotes) {
+		notes := source.DoctorNotes
+		if target.DoctorNotes != "" {
+			notes = fmt.Sprintf("%s\n\n--- Merged from patient #%d ---\n%s", target.DoctorNotes, source.ID, source.DoctorNotes)
+		}
+		take("doctor_notes", notes)
+	}
+	return updates, changes
+}
+
+// isExpectedMergeError reports whether err is a client error that does not need logging
+func isExpectedMergeError(err error) bool {
+	var fieldErrors FieldErrors
+	return errors.As(err, &fieldErrors) ||
+		errors.Is(err, ErrPatientNotFound) ||
+		errors.Is(err, ErrPatientVersionConflict) ||
+		errors.Is(err, ErrMergeSamePatient) ||
+		errors.Is(err, ErrMergeNotFound) ||
+		errors.Is(err, ErrMergeUndone) ||
+		errors.Is(err, ErrMergeNotLatest)
+}
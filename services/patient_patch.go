@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"medical_app/models"
+	"slices"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidPatch is returned when a patch body is not a JSON object
+var ErrInvalidPatch = newError(KindValidation, "invalid_patch", "request body must be a JSON object")
+
+// ErrEmptyPatch is returned when a patch does not change any field
+var ErrEmptyPatch = newError(KindValidation, "empty_patch", "request body must contain at least one field")
+
+// requiredPatientFields cannot be cleared by a patch
+var requiredPatientFields = []string{"first_name", "last_name"}
+
+// PatientPatch is a JSON Merge Patch (RFC 7396) of a patient's fields: a field mapped to
+// a value sets it, a field mapped to nil (JSON null) clears it and absent fields are kept.
+// The parts of the address are keyed "address.<part>", as the address object merges too.
+type PatientPatch map[string]*string
+
+// ParsePatientPatch decodes a merge patch document. Unknown fields and values that are
+// not strings or null are reported as FieldErrors, and a patch without fields (such as {}
+// or {"address": {}}) as ErrEmptyPatch.
+func ParsePatientPatch(body []byte) (PatientPatch, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	patch := PatientPatch{}
+	fieldErrors := FieldErrors{}
+	for field, value := range raw {
+		switch field {
+		case "status":
+			fieldErrors[field] = "cannot be patched, it changes through the status workflow"
+			continue
+		case "emergency_contacts":
+			fieldErrors[field] = "cannot be patched, replace them with PUT /api/patients/:id/emergency-contacts"
+			continue
+		case "address":
+			parseAddressPatch(value, patch, fieldErrors)
+			continue
+		}
+		if _, ok := patientColumns[field]; !ok {
+			fieldErrors[field] = "unknown field"
+			continue
+		}
+		if err := parsePatchValue(value, field, patch); err != nil {
+			fieldErrors[field] = err.Error()
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+	if len(patch) == 0 {
+		return nil, ErrEmptyPatch
+	}
+	return patch, nil
+}
+
+// parseAddressPatch adds the parts of an address merge patch to patch. A null address
+// clears every part.
+func parseAddressPatch(value json.RawMessage, patch PatientPatch, fieldErrors FieldErrors) {
+	if isJSONNull(value) {
+		for _, part := range addressParts {
+			patch["address."+part] = nil
+		}
+		return
+	}
+	var parts map[string]json.RawMessage
+	if err := json.Unmarshal(value, &parts); err != nil {
+		fieldErrors["address"] = "must be an object or null"
+		return
+	}
+	for part, partValue := range parts {
+		key := "address." + part
+		if !slices.Contains(addressParts, part) {
+			fieldErrors[key] = "unknown field, expected one of " + strings.Join(addressParts, ", ")
+			continue
+		}
+		if err := parsePatchValue(partValue, key, patch); err != nil {
+			fieldErrors[key] = err.Error()
+		}
+	}
+}
+
+// parsePatchValue adds a string or null value to patch
+func parsePatchValue(value json.RawMessage, key string, patch PatientPatch) error {
+	if isJSONNull(value) {
+		patch[key] = nil
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return errors.New("must be a string or null")
+	}
+	s = strings.TrimSpace(s)
+	patch[key] = &s
+	return nil
+}
+
+func isJSONNull(value json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
+
+// Fields returns the patched fields in a stable order, "address" for any address part
+func (p PatientPatch) Fields() []string {
+	fields := make([]string, 0, len(p))
+	for key := range p {
+		if field := patientFieldOf(key); !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// updates validates the new values, treating empty strings like null, and returns the
+// column updates. A patient must keep a phone number or an email address.
+func (p PatientPatch) updates(current *models.Patient) (map[string]interface{}, error) {
+	fieldErrors := FieldErrors{}
+	updates := make(map[string]interface{}, len(p))
+	for key, value := range p {
+		raw := ""
+		if value != nil {
+			raw = *value
+		}
+		if raw == "" && slices.Contains(requiredPatientFields, key) {
+			fieldErrors[key] = "is required and cannot be cleared"
+			continue
+		}
+		normalized, err := normalizePatientValue(key, raw)
+		if err != nil {
+			fieldErrors[key] = err.Error()
+			continue
+		}
+		updates[patientColumns[key]] = normalized
+	}
+
+	phone, email := current.Phone, current.Email
+	if value, ok := updates["phone"]; ok {
+		phone = value.(string)
+	}
+	if value, ok := updates["email"]; ok {
+		email = value.(string)
+	}
+	if phone == "" && email == "" && fieldErrors["phone"] == "" && fieldErrors["email"] == "" {
+		fieldErrors["phone"] = "a phone number or an email address is required"
+	}
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+	return updates, nil
+}
+
+// PatchPatient applies a merge patch to a patient and returns the record before and after.
+// A non-zero version must match the stored one, otherwise ErrPatientVersionConflict is returned.
+func (s *PatientServiceImpl) PatchPatient(id, version uint, patch PatientPatch) (*models.Patient, *models.Patient, error) {
+	before, after, err := s.updateVersioned(id, version, func(_ *gorm.DB, current *models.Patient) (map[string]interface{}, error) {
+		return patch.updates(current)
+	})
+	if err != nil {
+		var fieldErrors FieldErrors
+		if !errors.As(err, &fieldErrors) && !errors.Is(err, ErrPatientNotFound) && !errors.Is(err, ErrPatientVersionConflict) {
+			log.Printf("Error patching patient ID %d: %v", id, err)
+		}
+		return nil, nil, err
+	}
+	return before, after, nil
+}
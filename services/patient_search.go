@@ -0,0 +1,210 @@
+package services
+
+import (
+	"log"
+	"medical_app/models"
+	"medical_app/utils"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultPatientSearchLimit is the number of results returned when no limit is given
+	DefaultPatientSearchLimit = 20
+	// MaxPatientSearchLimit caps the number of search results
+	MaxPatientSearchLimit = 100
+
+	// searchBatchSize is how many candidate patients are scored per database round trip
+	searchBatchSize = 500
+)
+
+// searchFieldWeights gives more weight to a name hit than to a hit somewhere in the address
+var searchFieldWeights = struct {
+	FirstName, LastName, Contact, Address float64
+}{FirstName: 1.0, LastName: 1.0, Contact: 0.9, Address: 0.5}
+
+// patientSearchText is every searchable column of a patient in lower case, for the LIKE
+// filter of SearchPatients. Postgres has a trigram index on it (migration 6), which only
+// works while the expression stays exactly the same.
+const patientSearchText = "lower(coalesce(first_name, '') || ' ' || coalesce(last_name, '') || ' ' || coalesce(mrn, '') || ' ' || " +
+	"coalesce(phone, '') || ' ' || coalesce(secondary_phone, '') || ' ' || coalesce(email, '') || ' ' || " +
+	"coalesce(address_line1, '') || ' ' || coalesce(address_line2, '') || ' ' || coalesce(address_city, '') || ' ' || " +
+	"coalesce(address_state, '') || ' ' || coalesce(address_postal_code, '') || ' ' || coalesce(address_country, ''))"
+
+// PatientSearchResult is a single search hit along with its relevance score
+type PatientSearchResult struct {
+	Patient models.Patient `json:"patient"`
+	Score   float64        `json:"score"`
+}
+
+// SearchPatients finds patients whose first name, last name, phone numbers, email or
+// address match term. A word equal to a patient's medical record number matches too.
+// Every word of term has to match at least one field, either exactly, as a prefix, as a
+// substring, or within a small typo distance. Results are ranked by relevance.
+//
+// The candidates are narrowed in SQL with LIKE (see searchPieces) and then scored in Go,
+// so Postgres and SQLite rank them the same way. SQLite's lower() only folds ASCII letters,
+// so there a non-ASCII capital letter has to be searched as it is written.
+func (s *PatientServiceImpl) SearchPatients(term string, limit int) ([]PatientSearchResult, error) {
+	if limit < 1 {
+		limit = DefaultPatientSearchLimit
+	}
+	if limit > MaxPatientSearchLimit {
+		limit = MaxPatientSearchLimit
+	}
+
+	tokens := strings.Fields(utils.NormalizeText(term))
+	if len(tokens) == 0 {
+		return []PatientSearchResult{}, nil
+	}
+
+	results := []PatientSearchResult{}
+	var batch []models.Patient
+	query := s.reader().Model(&models.Patient{})
+	for _, token := range tokens {
+		query = query.Where(searchCondition(token))
+	}
+	err := query.FindInBatches(&batch, searchBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, patient := range batch {
+			if score := scorePatient(patient, tokens); score > 0 {
+				results = append(results, PatientSearchResult{Patient: patient, Score: score})
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		log.Printf("Error searching patients: %v", err)
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Patient.LastName != results[j].Patient.LastName {
+			return results[i].Patient.LastName < results[j].Patient.LastName
+		}
+		return results[i].Patient.ID < results[j].Patient.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// searchCondition matches the patients that may match token: their search text contains
+// one of its pieces
+func searchCondition(token string) clause.Expression {
+	pieces := searchPieces(token)
+	conditions := make([]clause.Expression, 0, len(pieces))
+	for _, piece := range pieces {
+		conditions = append(conditions, clause.Expr{SQL: patientSearchText + " LIKE ?", Vars: []interface{}{"%" + piece + "%"}})
+	}
+	return clause.Or(conditions...)
+}
+
+// searchPieces splits token into allowedTypos(token)+1 parts. A word within that many
+// edits of token still contains one of them unchanged, as every edit touches at most one
+// part; exact, prefix, substring and phone matches contain the whole token. Normalized
+// tokens are letters and digits only, so the pieces need no escaping for LIKE.
+func searchPieces(token string) []string {
+	runes := []rune(token)
+	n := allowedTypos(token) + 1
+	pieces := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		pieces = append(pieces, string(runes[i*len(runes)/n:(i+1)*len(runes)/n]))
+	}
+	return pieces
+}
+
+// scorePatient returns the average best score of every token, or 0 when any token does not match
+func scorePatient(patient models.Patient, tokens []string) float64 {
+	firstName := utils.NormalizeText(patient.FirstName)
+	lastName := utils.NormalizeText(patient.LastName)
+	email := utils.NormalizeText(patient.Email)
+	address := utils.NormalizeText(strings.Join([]string{
+		patient.Address.Line1, patient.Address.Line2, patient.Address.City,
+		patient.Address.State, patient.Address.PostalCode, patient.Address.Country,
+	}, " "))
+	phone := utils.DigitsOnly(patient.Phone)
+	secondaryPhone := utils.DigitsOnly(patient.SecondaryPhone)
+
+	total := 0.0
+	for _, token := range tokens {
+		if token == patient.MRN {
+			total += 1.0
+			continue
+		}
+		best := max(
+			scoreField(firstName, token)*searchFieldWeights.FirstName,
+			scoreField(lastName, token)*searchFieldWeights.LastName,
+			scoreField(email, token)*searchFieldWeights.Contact,
+			scoreField(address, token)*searchFieldWeights.Address,
+			scorePhone(phone, token)*searchFieldWeights.Contact,
+			scorePhone(secondaryPhone, token)*searchFieldWeights.Contact,
+		)
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total / float64(len(tokens))
+}
+
+// scoreField scores how well a single normalized token matches a normalized field value
+func scoreField(field, token string) float64 {
+	if field == "" {
+		return 0
+	}
+
+	best := 0.0
+	for _, word := range strings.Fields(field) {
+		switch {
+		case word == token:
+			return 1.0
+		case strings.HasPrefix(word, token):
+			best = max(best, 0.8)
+		case strings.Contains(word, token):
+			best = max(best, 0.6)
+		default:
+			if allowed := allowedTypos(token); allowed > 0 {
+				if d := utils.Levenshtein(word, token); d <= allowed {
+					best = max(best, 0.5-0.1*float64(d-1))
+				}
+			}
+		}
+	}
+	return best
+}
+
+// scorePhone matches digit tokens against a phone number with formatting removed
+func scorePhone(digits, token string) float64 {
+	tokenDigits := utils.DigitsOnly(token)
+	if len(tokenDigits) < 3 || len(tokenDigits) != len(token) || digits == "" {
+		return 0
+	}
+	switch {
+	case digits == tokenDigits:
+		return 1.0
+	case strings.HasPrefix(digits, tokenDigits), strings.HasSuffix(digits, tokenDigits):
+		return 0.8
+	case strings.Contains(digits, tokenDigits):
+		return 0.6
+	}
+	return 0
+}
+
+// allowedTypos returns how many edits a token of this length may be off by
+func allowedTypos(token string) int {
+	switch n := len([]rune(token)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
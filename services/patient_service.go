@@ -4,21 +4,143 @@ import (
 	"errors"
 	"log"
 	"medical_app/models"
+	"time"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const (
+	// DefaultPatientPageSize is used when the caller does not ask for a page size
+	DefaultPatientPageSize = 20
+	// MaxPatientPageSize caps how many patients a single page may return
+	MaxPatientPageSize = 100
+)
+
+var (
+	// ErrInvalidPatientSort is returned when ListPatients is asked to sort by an unsupported field
+	ErrInvalidPatientSort = newError(KindValidation, "invalid_sort", "invalid sort field")
+	// ErrPatientVersionConflict is returned when a patient changed after the version the caller read
+	ErrPatientVersionConflict = newError(KindStale, "version_conflict", "patient was modified by another request")
+)
+
+// patientSortOrders maps the accepted sort keys to their ORDER BY clauses.
+// The id is always appended so that paging is stable when values tie.
+var patientSortOrders = map[string]string{
+	"last_name":   "last_name ASC, first_name ASC, id ASC",
+	"-last_name":  "last_name DESC, first_name DESC, id DESC",
+	"created_at":  "created_at ASC, id ASC",
+	"-created_at": "created_at DESC, id DESC",
+}
+
+// PatientListOptions holds the filters, sort order and paging used by ListPatients
+type PatientListOptions struct {
+	Status      string
+	Gender      string
+	DOBFrom     string // inclusive, "YYYY-MM-DD"
+	DOBTo       string // inclusive, "YYYY-MM-DD"
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	Sort        string // one of the patientSortOrders keys, defaults to "last_name"
+	Page        int    // 1-based
+	PageSize    int
+}
+
+// PatientPage is one page of a patient listing
+type PatientPage struct {
+	Patients []models.Patient
+	Total    int64
+	Page     int
+	PageSize int
+}
+
+// TotalPages returns the number of pages needed to show every matching patient
+func (p *PatientPage) TotalPages() int {
+	if p.PageSize == 0 {
+		return 0
+	}
+	return int((p.Total + int64(p.PageSize) - 1) / int64(p.PageSize))
+}
+
+// HasNext reports whether there is a page after this one
+func (p *PatientPage) HasNext() bool {
+	return p.Page < p.TotalPages()
+}
+
+// PatientService manages patient records: registration, changes, the status workflow, search, deletion and merges
+type PatientService interface {
+	CreatePatient(patient *models.Patient) error
+	GetAllPatients() ([]models.Patient, error)
+	ListPatients(opts PatientListOptions) (*PatientPage, error)
+	GetPatientByID(id uint) (*models.Patient, error)
+	UpdatePatient(patient *models.Patient) error
+	DeletePatient(id uint) error
+	UpdatePatientDoctorNotes(id, version uint, doctorNotes string, change StatusChange) (*models.Patient, *models.Patient, error)
+	PatchPatient(id, version uint, patch PatientPatch) (*models.Patient, *models.Patient, error)
+	ChangePatientStatus(id, version uint, change StatusChange) (*models.Patient, *models.Patient, error)
+	StatusHistory(patientID uint) (*models.Patient, []models.PatientStatusChange, error)
+	SearchPatients(term string, limit int) ([]PatientSearchResult, error)
+	FindDuplicates(candidate *models.Patient) ([]DuplicateMatch, error)
+	ReplaceEmergencyContacts(id, version uint, contacts []models.EmergencyContact) (*models.Patient, *models.Patient, error)
+	ListDeletedPatients(page, pageSize int) (*PatientPage, error)
+	RestorePatient(id uint) (*models.Patient, error)
+	PurgePatient(id uint) error
+	PurgeExpiredPatients(retention time.Duration) ([]uint, error)
+	MergePatients(targetID, version, sourceID uint, opts MergeOptions) (*MergeResult, error)
+	UnmergePatients(mergeID uint, unmergedBy string) (*MergeResult, error)
+	ListMerges(patientID uint) ([]models.PatientMerge, error)
+	ExportPatients(each func(*models.Patient) error) error
+	ImportPatient(patient *models.Patient) error
+}
+
 // PatientServiceImpl provides patient management related services
 type PatientServiceImpl struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Replica *gorm.DB // optional read replica for listing and searching, which may lag behind DB
 }
 
+var _ PatientService = (*PatientServiceImpl)(nil)
+
 func NewPatientService(db *gorm.DB) *PatientServiceImpl {
 	return &PatientServiceImpl{DB: db}
 }
 
-// creates a new patient record
+// reader is the database list and search queries run on: the replica when there is one
+func (s *PatientServiceImpl) reader() *gorm.DB {
+	if s.Replica != nil {
+		return s.Replica
+	}
+	return s.DB
+}
+
+// creates a new patient record with its emergency contacts. The fields are validated
+// (FieldErrors) and normalized, and a medical record number is assigned.
 func (s *PatientServiceImpl) CreatePatient(patient *models.Patient) error {
-	if err := s.DB.Create(patient).Error; err != nil {
+	if err := NormalizePatient(patient); err != nil {
+		return err
+	}
+	patient.Version = 1
+	if patient.Status == "" {
+		patient.Status = models.PatientRegistered
+	}
+	// A concurrent registration can take the same number between generateMRN's check and
+	// the insert; the unique index catches that and another number is drawn
+	var err error
+	for attempt := 0; attempt < mrnAttempts; attempt++ {
+		err = s.DB.Transaction(func(tx *gorm.DB) error {
+			mrn, err := generateMRN(tx)
+			if err != nil {
+				return err
+			}
+			patient.MRN = mrn
+			return tx.Create(patient).Error
+		})
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			break
+		}
+	}
+	if err != nil {
 		log.Printf("Error creating patient in DB: %v", err)
 		return err
 	}
@@ -28,27 +150,100 @@ func (s *PatientServiceImpl) CreatePatient(patient *models.Patient) error {
 //  retrieves all patient records
 func (s *PatientServiceImpl) GetAllPatients() ([]models.Patient, error) {
 	var patients []models.Patient
-	if err := s.DB.Find(&patients).Error; err != nil {
+	if err := s.reader().Find(&patients).Error; err != nil {
 		log.Printf("Error retrieving all patients from DB: %v", err)
 		return nil, err
 	}
 	return patients, nil
 }
 
-// retrieves a single patient record by ID
+// ListPatients returns a filtered, sorted page of patients together with the total match count
+func (s *PatientServiceImpl) ListPatients(opts PatientListOptions) (*PatientPage, error) {
+	if opts.Sort == "" {
+		opts.Sort = "last_name"
+	}
+	order, ok := patientSortOrders[opts.Sort]
+	if !ok {
+		return nil, ErrInvalidPatientSort
+	}
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PageSize < 1 {
+		opts.PageSize = DefaultPatientPageSize
+	}
+	if opts.PageSize > MaxPatientPageSize {
+		opts.PageSize = MaxPatientPageSize
+	}
+
+	query := s.reader().Model(&models.Patient{})
+	if opts.Status != "" {
+		query = query.Where("status = ?", opts.Status)
+	}
+	if opts.Gender != "" {
+		query = query.Where("gender = ?", opts.Gender)
+	}
+	if opts.DOBFrom != "" {
+		query = query.Where("birth_date >= ?", opts.DOBFrom)
+	}
+	if opts.DOBTo != "" {
+		query = query.Where("birth_date <= ?", opts.DOBTo)
+	}
+	if opts.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *opts.CreatedFrom)
+	}
+	if opts.CreatedTo != nil {
+		query = query.Where("created_at < ?", *opts.CreatedTo)
+	}
+	if opts.UpdatedFrom != nil {
+		query = query.Where("updated_at >= ?", *opts.UpdatedFrom)
+	}
+	if opts.UpdatedTo != nil {
+		query = query.Where("updated_at < ?", *opts.UpdatedTo)
+	}
+
+	// Share the filters between the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	page := &PatientPage{Page: opts.Page, PageSize: opts.PageSize}
+	if err := query.Count(&page.Total).Error; err != nil {
+		log.Printf("Error counting patients: %v", err)
+		return nil, err
+	}
+
+	page.Patients = []models.Patient{}
+	offset := (opts.Page - 1) * opts.PageSize
+	if err := query.Order(order).Limit(opts.PageSize).Offset(offset).Find(&page.Patients).Error; err != nil {
+		log.Printf("Error listing patients: %v", err)
+		return nil, err
+	}
+	return page, nil
+}
+
+// retrieves a single patient record by ID with its emergency contacts
 func (s *PatientServiceImpl) GetPatientByID(id uint) (*models.Patient, error) {
 	var patient models.Patient
-	if err := s.DB.First(&patient, id).Error; err != nil {
+	if err := s.DB.Preload("EmergencyContacts", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&patient, id).Error; err != nil {
 		return nil, err
 	}
 	return &patient, nil
 }
 
-// it updates an existing patient record
+// it updates an existing patient record. The update only applies if the stored version
+// still equals patient.Version, otherwise ErrPatientVersionConflict is returned. Status is
+// left alone; it only changes through ChangePatientStatus.
 func (s *PatientServiceImpl) UpdatePatient(patient *models.Patient) error {
-	if err := s.DB.Save(patient).Error; err != nil {
-		log.Printf("Error updating patient in DB: %v", err)
-		return err
+	version := patient.Version
+	patient.Version++
+	result := s.DB.Model(patient).Where("version = ?", version).Select("*").Omit("created_at", "status").Updates(patient)
+	if result.Error != nil {
+		patient.Version = version
+		log.Printf("Error updating patient in DB: %v", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		patient.Version = version
+		return ErrPatientVersionConflict
 	}
 	return nil
 }
@@ -62,18 +257,54 @@ func (s *PatientServiceImpl) DeletePatient(id uint) error {
 	return nil
 }
 
-//  updates only the doctor_notes field for a patient
-func (s *PatientServiceImpl) UpdatePatientDoctorNotes(id uint, doctorNotes, status string) error {
-	result := s.DB.Model(&models.Patient{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"doctor_notes": doctorNotes,
-		"status":       status,
+//  updates the doctor_notes field for a patient, returning the record before and after. A change.Status
+// other than the current one moves the patient through the status workflow in the same update.
+// A non-zero version must match the stored one.
+func (s *PatientServiceImpl) UpdatePatientDoctorNotes(id, version uint, doctorNotes string, change StatusChange) (*models.Patient, *models.Patient, error) {
+	before, after, err := s.updateVersioned(id, version, func(tx *gorm.DB, patient *models.Patient) (map[string]interface{}, error) {
+		updates := map[string]interface{}{"doctor_notes": doctorNotes}
+		if change.Status != "" && change.Status != patient.Status {
+			if err := applyStatusChange(tx, patient, change, updates); err != nil {
+				return nil, err
+			}
+		}
+		return updates, nil
 	})
-	if result.Error != nil {
-		log.Printf("Error updating doctor notes for patient ID %d: %v", id, result.Error)
-		return result.Error
+	if err != nil && !isExpectedStatusError(err) {
+		log.Printf("Error updating doctor notes for patient ID %d: %v", id, err)
 	}
-	if result.RowsAffected == 0 {
-		return errors.New("patient not found")
+	return before, after, err
+}
+
+// updateVersioned locks the patient, checks version (0 skips the check), applies the column
+// updates returned by change and bumps the version. It returns the record before and after.
+func (s *PatientServiceImpl) updateVersioned(id, version uint, change func(tx *gorm.DB, patient *models.Patient) (map[string]interface{}, error)) (*models.Patient, *models.Patient, error) {
+	var before, after models.Patient
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPatientNotFound
+			}
+			return err
+		}
+		if version != 0 && before.Version != version {
+			return ErrPatientVersionConflict
+		}
+		current := before
+		updates, err := change(tx, &current)
+		if err != nil {
+			return err
+		}
+		if len(updates) > 0 {
+			updates["version"] = gorm.Expr("version + 1")
+			if err := tx.Model(&models.Patient{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return tx.First(&after, id).Error
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	return &before, &after, nil
 }
\ No newline at end of file
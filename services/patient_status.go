@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"medical_app/models"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidPatientTransition is returned when the workflow has no transition between two statuses
+	ErrInvalidPatientTransition = newError(KindConflict, "invalid_patient_transition", "invalid patient status transition")
+	// ErrPatientTransitionForbidden is returned when the caller's permissions do not allow a transition
+	ErrPatientTransitionForbidden = newError(KindForbidden, "patient_transition_forbidden", "status transition not allowed for your role")
+)
+
+// Extra input a transition can require
+const (
+	requiresReason           = "reason"
+	requiresDischargeSummary = "discharge_summary"
+)
+
+// patientTransition is one allowed status change. Any of Permissions allows it.
+type patientTransition struct {
+	From        string
+	To          string
+	Permissions []string
+	Requires    string // requiresReason, requiresDischargeSummary or empty
+}
+
+// patientTransitions is the patient status workflow
+var patientTransitions = []patientTransition{
+	{models.PatientRegistered, models.PatientAdmitted, []string{models.PermPatientWrite, models.PermPatientClinical}, ""},
+	{models.PatientRegistered, models.PatientUnderTreatment, []string{models.PermPatientClinical}, ""},
+	{models.PatientRegistered, models.PatientDeceased, []string{models.PermPatientClinical}, requiresReason},
+	{models.PatientRegistered, models.PatientArchived, []string{models.PermPatientWrite}, requiresReason},
+
+	{models.PatientAdmitted, models.PatientUnderTreatment, []string{models.PermPatientClinical}, ""},
+	{models.PatientAdmitted, models.PatientDischarged, []string{models.PermPatientClinical}, requiresDischargeSummary},
+	{models.PatientAdmitted, models.PatientDeceased, []string{models.PermPatientClinical}, requiresReason},
+
+	{models.PatientUnderTreatment, models.PatientAdmitted, []string{models.PermPatientClinical}, ""},
+	{models.PatientUnderTreatment, models.PatientDischarged, []string{models.PermPatientClinical}, requiresDischargeSummary},
+	{models.PatientUnderTreatment, models.PatientDeceased, []string{models.PermPatientClinical}, requiresReason},
+
+	// Readmission
+	{models.PatientDischarged, models.PatientAdmitted, []string{models.PermPatientWrite, models.PermPatientClinical}, requiresReason},
+	{models.PatientDischarged, models.PatientUnderTreatment, []string{models.PermPatientClinical}, ""},
+	{models.PatientDischarged, models.PatientDeceased, []string{models.PermPatientClinical}, requiresReason},
+	{models.PatientDischarged, models.PatientArchived, []string{models.PermPatientWrite}, ""},
+
+	{models.PatientDeceased, models.PatientArchived, []string{models.PermPatientWrite}, ""},
+
+	// Reactivating an archived record
+	{models.PatientArchived, models.PatientRegistered, []string{models.PermPatientWrite}, requiresReason},
+}
+
+// StatusChange is a request to move a patient to another status
+type StatusChange struct {
+	Status           string
+	Reason           string
+	DischargeSummary string
+	ChangedBy        string   // Username recorded in the history
+	Permissions      []string // Permissions of the caller, checked against the transition
+}
+
+// PatientTransitionError explains why a status change was rejected. It wraps
+// ErrInvalidPatientTransition or ErrPatientTransitionForbidden.
+type PatientTransitionError struct {
+	From    string
+	To      string
+	Allowed []string // Statuses the caller may move the patient to instead
+	err     error
+}
+
+func (e *PatientTransitionError) Error() string {
+	return fmt.Sprintf("%v: %s to %s", e.err, e.From, e.To)
+}
+
+func (e *PatientTransitionError) Unwrap() error {
+	return e.err
+}
+
+// AllowedPatientTransitions returns the statuses a caller with the given permissions may
+// move a patient to from status
+func AllowedPatientTransitions(status string, permissions []string) []string {
+	allowed := []string{}
+	for _, t := range patientTransitions {
+		if t.From == status && anyPermission(permissions, t.Permissions) {
+			allowed = append(allowed, t.To)
+		}
+	}
+	return allowed
+}
+
+// ChangePatientStatus moves a patient through the status workflow and records the change
+// in the status history. A non-zero version must match the stored one.
+func (s *PatientServiceImpl) ChangePatientStatus(id, version uint, change StatusChange) (*models.Patient, *models.Patient, error) {
+	before, after, err := s.updateVersioned(id, version, func(tx *gorm.DB, patient *models.Patient) (map[string]interface{}, error) {
+		updates := map[string]interface{}{}
+		if err := applyStatusChange(tx, patient, change, updates); err != nil {
+			return nil, err
+		}
+		return updates, nil
+	})
+	if err != nil && !isExpectedStatusError(err) {
+		log.Printf("Error changing status of patient ID %d: %v", id, err)
+	}
+	return before, after, err
+}
+
+// StatusHistory returns a patient with its status changes, oldest first
+func (s *PatientServiceImpl) StatusHistory(patientID uint) (*models.Patient, []models.PatientStatusChange, error) {
+	patient, err := s.GetPatientByID(patientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrPatientNotFound
+		}
+		return nil, nil, err
+	}
+	history := []models.PatientStatusChange{}
+	if err := s.DB.Where("patient_id = ?", patientID).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+		log.Printf("Error loading status history of patient ID %d: %v", patientID, err)
+		return nil, nil, err
+	}
+	return patient, history, nil
+}
+
+// applyStatusChange checks the transition from the patient's current status, adds the new
+// status to updates and writes the history entry
+func applyStatusChange(tx *gorm.DB, patient *models.Patient, change StatusChange, updates map[string]interface{}) error {
+	change.Reason = strings.TrimSpace(change.Reason)
+	change.DischargeSummary = strings.TrimSpace(change.DischargeSummary)
+	if !slices.Contains(models.PatientStatuses, change.Status) {
+		return FieldErrors{"status": "must be one of " + strings.Join(models.PatientStatuses, ", ")}
+	}
+
+	var transition *patientTransition
+	for i := range patientTransitions {
+		if patientTransitions[i].From == patient.Status && patientTransitions[i].To == change.Status {
+			transition = &patientTransitions[i]
+			break
+		}
+	}
+	rejected := &PatientTransitionError{
+		From:    patient.Status,
+		To:      change.Status,
+		Allowed: AllowedPatientTransitions(patient.Status, change.Permissions),
+	}
+	switch {
+	case transition == nil:
+		rejected.err = ErrInvalidPatientTransition
+		return rejected
+	case !anyPermission(change.Permissions, transition.Permissions):
+		rejected.err = ErrPatientTransitionForbidden
+		return rejected
+	case transition.Requires == requiresReason && change.Reason == "":
+		return FieldErrors{"reason": "is required to move a patient from " + patient.Status + " to " + change.Status}
+	case transition.Requires == requiresDischargeSummary && change.DischargeSummary == "":
+		return FieldErrors{"discharge_summary": "is required to discharge a patient"}
+	}
+
+	updates["status"] = change.Status
+	return tx.Create(&models.PatientStatusChange{
+		PatientID:        patient.ID,
+		FromStatus:       patient.Status,
+		ToStatus:         change.Status,
+		Reason:           change.Reason,
+		DischargeSummary: change.DischargeSummary,
+		ChangedBy:        change.ChangedBy,
+	}).Error
+}
+
+// isExpectedStatusError reports whether err is a client error that does not need logging
+func isExpectedStatusError(err error) bool {
+	var fieldErrors FieldErrors
+	return errors.As(err, &fieldErrors) ||
+		errors.Is(err, ErrPatientNotFound) ||
+		errors.Is(err, ErrPatientVersionConflict) ||
+		errors.Is(err, ErrInvalidPatientTransition) ||
+		errors.Is(err, ErrPatientTransitionForbidden)
+}
+
+// anyPermission reports whether granted holds at least one of required
+func anyPermission(granted, required []string) bool {
+	for _, perm := range required {
+		if slices.Contains(granted, perm) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"medical_app/models"
+	"medical_app/utils"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrMRNTaken is returned when an imported patient's medical record number is already in use
+var ErrMRNTaken = newError(KindConflict, "mrn_taken", "medical record number already in use")
+
+// ExportPatients calls each for every patient that is not deleted, in ID order and with
+// their emergency contacts. It stops at the first error each returns.
+func (s *PatientServiceImpl) ExportPatients(each func(*models.Patient) error) error {
+	var batch []models.Patient
+	return s.DB.Preload("EmergencyContacts").FindInBatches(&batch, searchBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := each(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// ImportPatient creates a patient exported by ExportPatients, possibly from another
+// installation. Unlike CreatePatient it keeps the status, the doctor notes and the medical
+// record number, which must be valid and unused (ErrMRNTaken, e.g. when an export is
+// imported twice); a patient without one gets a new number. IDs and timestamps of the
+// export are not kept.
+func (s *PatientServiceImpl) ImportPatient(patient *models.Patient) error {
+	patient.Model = gorm.Model{}
+	if patient.Status != "" && !slices.Contains(models.PatientStatuses, patient.Status) {
+		return FieldErrors{"status": "must be one of " + strings.Join(models.PatientStatuses, ", ")}
+	}
+
+	mrn := strings.TrimSpace(patient.MRN)
+	if mrn == "" {
+		return s.CreatePatient(patient)
+	}
+	if !utils.ValidMRN(mrn) {
+		return FieldErrors{"mrn": "is not a valid medical record number"}
+	}
+	if err := NormalizePatient(patient); err != nil {
+		return err
+	}
+	patient.MRN = mrn
+	patient.Version = 1
+	if patient.Status == "" {
+		patient.Status = models.PatientRegistered
+	}
+	if err := s.DB.Create(patient).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrMRNTaken
+		}
+		log.Printf("Error importing patient in DB: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"medical_app/models"
+	"regexp"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrRoleNotFound is returned when no role has the requested name
+	ErrRoleNotFound = newError(KindNotFound, "role_not_found", "role not found")
+	// ErrRoleExists is returned when creating a role whose name is already in use
+	ErrRoleExists = newError(KindConflict, "role_exists", "role already exists")
+	// ErrInvalidRoleName is returned for role names that are not lower case identifiers
+	ErrInvalidRoleName = newError(KindValidation, "invalid_role_name", "role name must be 2-32 lower case letters, digits or underscores, starting with a letter")
+	// ErrUnknownPermission is returned when a role is given a permission outside models.Permissions
+	ErrUnknownPermission = newError(KindValidation, "unknown_permission", "unknown permission")
+	// ErrRoleBuiltIn is returned when deleting one of the built-in roles
+	ErrRoleBuiltIn = newError(KindConflict, "role_built_in", "built-in roles cannot be deleted")
+	// ErrRoleInUse is returned when deleting a role that users still have
+	ErrRoleInUse = newError(KindConflict, "role_in_use", "role is assigned to users")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`)
+
+// RoleService manages roles and their permissions
+type RoleService interface {
+	ListRoles() ([]models.Role, error)
+	GetRole(name string) (*models.Role, error)
+	CreateRole(name, description string, permissions []string) (*models.Role, error)
+	UpdateRole(name, description string, permissions []string) (*models.Role, error)
+	DeleteRole(name string) error
+	Permissions(role string) ([]string, error)
+}
+
+// RoleServiceImpl manages roles and the permissions they grant
+type RoleServiceImpl struct {
+	DB *gorm.DB
+}
+
+var _ RoleService = (*RoleServiceImpl)(nil)
+
+func NewRoleService(db *gorm.DB) *RoleServiceImpl {
+	return &RoleServiceImpl{DB: db}
+}
+
+// SeedBuiltInRoles creates the built-in roles that do not exist yet. Existing roles are
+// left alone so permission changes made by admins survive restarts.
+func (s *RoleServiceImpl) SeedBuiltInRoles() error {
+	for name, permissions := range models.BuiltInRoles {
+		role := models.Role{Name: name, BuiltIn: true, Permissions: rolePermissions(name, permissions)}
+		result := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Omit("Permissions").Create(&role)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := s.DB.Create(&role.Permissions).Error; err != nil {
+			return err
+		}
+		log.Printf("Created built-in role %q", name)
+	}
+	return nil
+}
+
+// ListRoles returns every role with its permissions, ordered by name
+func (s *RoleServiceImpl) ListRoles() ([]models.Role, error) {
+	roles := []models.Role{}
+	if err := s.DB.Preload("Permissions").Order("name ASC").Find(&roles).Error; err != nil {
+		log.Printf("Error listing roles: %v", err)
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetRole returns a role with its permissions
+func (s *RoleServiceImpl) GetRole(name string) (*models.Role, error) {
+	var role models.Role
+	if err := s.DB.Preload("Permissions").First(&role, "name = ?", name).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// CreateRole adds a custom role with the given permissions
+func (s *RoleServiceImpl) CreateRole(name, description string, permissions []string) (*models.Role, error) {
+	if !roleNamePattern.MatchString(name) {
+		return nil, ErrInvalidRoleName
+	}
+	if err := validatePermissions(permissions); err != nil {
+		return nil, err
+	}
+
+	role := models.Role{Name: name, Description: description, Permissions: rolePermissions(name, permissions)}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		exists, err := roleExists(tx, name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrRoleExists
+		}
+		if err := tx.Create(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrRoleExists
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// UpdateRole replaces a role's description and permissions. Taking user:admin away is
+// refused when it would leave no enabled admin.
+func (s *RoleServiceImpl) UpdateRole(name, description string, permissions []string) (*models.Role, error) {
+	if err := validatePermissions(permissions); err != nil {
+		return nil, err
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&role, "name = ?", name).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+
+		wasAdmin, err := roleHasPermission(tx, name, models.PermUserAdmin)
+		if err != nil {
+			return err
+		}
+		if wasAdmin && !slices.Contains(permissions, models.PermUserAdmin) {
+			var others int64
+			err := tx.Model(&models.User{}).
+				Where("role IN (?) AND role <> ? AND disabled = ?", rolesWithPermission(tx, models.PermUserAdmin), name, false).
+				Count(&others).Error
+			if err != nil {
+				return err
+			}
+			if others == 0 {
+				return ErrLastAdmin
+			}
+		}
+
+		if err := tx.Model(&role).Update("description", description).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_name = ?", name).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+		grants := rolePermissions(name, permissions)
+		return tx.Create(&grants).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetRole(name)
+}
+
+// DeleteRole removes a custom role that no user has
+func (s *RoleServiceImpl) DeleteRole(name string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.First(&role, "name = ?", name).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+		if role.BuiltIn {
+			return ErrRoleBuiltIn
+		}
+		var users int64
+		if err := tx.Model(&models.User{}).Where("role = ?", name).Count(&users).Error; err != nil {
+			return err
+		}
+		if users > 0 {
+			return ErrRoleInUse
+		}
+		if err := tx.Where("role_name = ?", name).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+}
+
+// Permissions returns the permissions granted to a role, none for an unknown role
+func (s *RoleServiceImpl) Permissions(role string) ([]string, error) {
+	permissions := []string{}
+	err := s.DB.Model(&models.RolePermission{}).Where("role_name = ?", role).Pluck("permission", &permissions).Error
+	return permissions, err
+}
+
+// roleExists reports whether a role with the given name exists
+func roleExists(db *gorm.DB, name string) (bool, error) {
+	var count int64
+	err := db.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+// roleHasPermission reports whether the role grants the permission
+func roleHasPermission(db *gorm.DB, role, permission string) (bool, error) {
+	var count int64
+	err := db.Model(&models.RolePermission{}).Where("role_name = ? AND permission = ?", role, permission).Count(&count).Error
+	return count > 0, err
+}
+
+// rolesWithPermission is a subquery selecting the names of roles granting the permission
+func rolesWithPermission(db *gorm.DB, permission string) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Model(&models.RolePermission{}).Select("role_name").Where("permission = ?", permission)
+}
+
+// validatePermissions checks every permission is known
+func validatePermissions(permissions []string) error {
+	for _, p := range permissions {
+		if !slices.Contains(models.Permissions, p) {
+			return ErrUnknownPermission
+		}
+	}
+	return nil
+}
+
+// rolePermissions builds the grant rows of a role, skipping duplicates
+func rolePermissions(role string, permissions []string) []models.RolePermission {
+	grants := make([]models.RolePermission, 0, len(permissions))
+	seen := map[string]bool{}
+	for _, p := range permissions {
+		if !seen[p] {
+			seen[p] = true
+			grants = append(grants, models.RolePermission{RoleName: role, Permission: p})
+		}
+	}
+	return grants
+}
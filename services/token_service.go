@@ -0,0 +1,231 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"medical_app/config"
+	"medical_app/models"
+	"medical_app/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = newError(KindUnauthorized, "invalid_refresh_token", "invalid or expired refresh token")
+	// ErrTokenRevoked is returned for an access token that was revoked by logout
+	ErrTokenRevoked = newError(KindUnauthorized, "token_revoked", "token has been revoked")
+	// ErrSessionUserInactive is returned when the token's user was deleted or disabled
+	ErrSessionUserInactive = newError(KindUnauthorized, "session_user_inactive", "user account is disabled or no longer exists")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again.
+	// The whole token family is revoked, since the token has most likely been stolen.
+	ErrRefreshTokenReused = newError(KindUnauthorized, "refresh_token_reused", "refresh token reuse detected, please log in again")
+)
+
+// TokenPair is what a successful login or refresh hands back to the client
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	AccessExpiresIn  time.Duration
+	RefreshExpiresAt time.Time
+}
+
+// TokenService issues, refreshes and revokes access and refresh tokens
+type TokenService interface {
+	IssueTokens(user *models.User) (*TokenPair, error)
+	Refresh(rawToken string) (*TokenPair, *models.User, error)
+	Logout(jti string, accessExpiresAt time.Time, rawRefreshToken string) error
+	RevokeAllForUser(userID uint) error
+	IsRevoked(jti string) (bool, error)
+	SessionUser(jti, username string) (*models.User, error)
+	PurgeExpired() error
+}
+
+// TokenServiceImpl issues, rotates and revokes access and refresh tokens
+type TokenServiceImpl struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+var _ TokenService = (*TokenServiceImpl)(nil)
+
+func NewTokenService(db *gorm.DB, cfg *config.Config) *TokenServiceImpl {
+	return &TokenServiceImpl{DB: db, Config: cfg}
+}
+
+// IssueTokens starts a new session for user with a fresh refresh token family
+func (s *TokenServiceImpl) IssueTokens(user *models.User) (*TokenPair, error) {
+	return s.issue(s.DB, user, utils.RandomToken(16))
+}
+
+// Refresh exchanges a refresh token for a new token pair, rotating the refresh token
+func (s *TokenServiceImpl) Refresh(rawToken string) (*TokenPair, *models.User, error) {
+	var pair *TokenPair
+	var user models.User
+	reused := false
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(rawToken)).
+			First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if token.UsedAt != nil {
+			// A rotated token came back: revoke every token of the family, then commit that
+			log.Printf("Refresh token reuse detected for user ID %d, revoking family %s", token.UserID, token.FamilyID)
+			reused = true
+			return revokeFamilies(tx, "family_id = ?", token.FamilyID)
+		}
+		if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+		if user.Disabled {
+			return ErrInvalidRefreshToken
+		}
+
+		now := time.Now()
+		if err := tx.Model(&token).Update("used_at", &now).Error; err != nil {
+			return err
+		}
+		pair, err = s.issue(tx, &user, token.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if reused {
+		return nil, nil, ErrRefreshTokenReused
+	}
+	return pair, &user, nil
+}
+
+// Logout revokes the access token with the given jti and, when given, the refresh token's family
+func (s *TokenServiceImpl) Logout(jti string, accessExpiresAt time.Time, rawRefreshToken string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if jti != "" {
+			revoked := models.RevokedToken{JTI: jti, ExpiresAt: accessExpiresAt}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+				return err
+			}
+		}
+		if rawRefreshToken == "" {
+			return nil
+		}
+		var token models.RefreshToken
+		err := tx.Where("token_hash = ?", hashToken(rawRefreshToken)).First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return revokeFamilies(tx, "family_id = ?", token.FamilyID)
+	})
+}
+
+// RevokeAllForUser ends every session of a user by revoking all of their refresh tokens.
+// Access tokens already handed out stay valid until they expire (AccessTokenTTL).
+func (s *TokenServiceImpl) RevokeAllForUser(userID uint) error {
+	return revokeFamilies(s.DB, "user_id = ?", userID)
+}
+
+// IsRevoked reports whether the access token with the given jti has been revoked
+func (s *TokenServiceImpl) IsRevoked(jti string) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SessionUser checks that an access token is still usable and returns its user as stored
+// now, so disabling an account or changing a role takes effect on the next request
+func (s *TokenServiceImpl) SessionUser(jti, username string) (*models.User, error) {
+	revoked, err := s.IsRevoked(jti)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	var user models.User
+	if err := s.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionUserInactive
+		}
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrSessionUserInactive
+	}
+	return &user, nil
+}
+
+// PurgeExpired removes revocation entries and refresh tokens that have expired anyway
+func (s *TokenServiceImpl) PurgeExpired() error {
+	now := time.Now()
+	if err := s.DB.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return s.DB.Unscoped().Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error
+}
+
+// issue creates an access token and a new refresh token in the given family
+func (s *TokenServiceImpl) issue(db *gorm.DB, user *models.User, familyID string) (*TokenPair, error) {
+	accessToken, err := utils.GenerateJWT(user.Username, user.Role, s.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	rawRefresh := utils.RandomToken(32)
+	refresh := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(rawRefresh),
+		ExpiresAt: time.Now().Add(s.Config.RefreshTokenTTL),
+	}
+	if err := db.Create(&refresh).Error; err != nil {
+		log.Printf("Error storing refresh token for user ID %d: %v", user.ID, err)
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     rawRefresh,
+		AccessExpiresIn:  s.Config.AccessTokenTTL,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// revokeFamilies marks every not yet revoked refresh token matching the condition as revoked
+func revokeFamilies(db *gorm.DB, query string, args ...interface{}) error {
+	now := time.Now()
+	return db.Model(&models.RefreshToken{}).
+		Where(query, args...).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", &now).Error
+}
+
+// hashToken returns the hex SHA-256 of a refresh token, which is what gets stored
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
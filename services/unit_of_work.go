@@ -0,0 +1,69 @@
+package services
+
+import (
+	"medical_app/config"
+
+	"gorm.io/gorm"
+)
+
+// Services holds one instance of every service, all working through the same database
+// handle: either the connection pool or the transaction of a unit of work
+type Services struct {
+	Appointments AppointmentService
+	Audit        AuditService
+	Auth         AuthService
+	Availability AvailabilityService
+	Fields       FieldVisibilityService
+	MFA          MFAService
+	Notes        EncounterNoteService
+	Patients     PatientService
+	Roles        RoleService
+	Tokens       TokenService
+	Users        UserService
+}
+
+// NewServices creates every service on db
+func NewServices(db *gorm.DB, cfg *config.Config) *Services {
+	return &Services{
+		Appointments: NewAppointmentService(db),
+		Audit:        NewAuditService(db),
+		Auth:         NewAuthService(db, cfg),
+		Availability: NewAvailabilityService(db),
+		Fields:       NewFieldVisibilityService(db),
+		MFA:          NewMFAService(db),
+		Notes:        NewEncounterNoteService(db),
+		Patients:     NewPatientService(db),
+		Roles:        NewRoleService(db),
+		Tokens:       NewTokenService(db, cfg),
+		Users:        NewUserService(db, cfg),
+	}
+}
+
+// UnitOfWork runs several service calls as one transaction. Controllers use it to
+// compose calls that must succeed or fail together, such as a change and its audit
+// entry; tests can replace it with a fake that hands out in-memory services.
+type UnitOfWork interface {
+	// Do calls fn with services bound to a new transaction. The transaction is committed
+	// when fn returns nil and rolled back when it returns an error or panics; Do returns
+	// fn's error.
+	Do(fn func(tx *Services) error) error
+}
+
+// GormUnitOfWork is the UnitOfWork of a GORM database. Transactions a service starts
+// itself become savepoints of the unit of work's transaction.
+type GormUnitOfWork struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+var _ UnitOfWork = (*GormUnitOfWork)(nil)
+
+func NewUnitOfWork(db *gorm.DB, cfg *config.Config) *GormUnitOfWork {
+	return &GormUnitOfWork{DB: db, Config: cfg}
+}
+
+func (u *GormUnitOfWork) Do(fn func(tx *Services) error) error {
+	return u.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(NewServices(tx, u.Config))
+	})
+}
@@ -1,17 +1,64 @@
 package services
 
 import (
+	"errors"
 	"log"
+	"medical_app/config"
 	"medical_app/models"
 	"medical_app/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
+
+var (
+	// ErrUserNotFound is returned when no user has the requested ID
+	ErrUserNotFound = newError(KindNotFound, "user_not_found", "user not found")
+	// ErrUsernameTaken is returned when creating a user whose username is already in use
+	ErrUsernameTaken = newError(KindConflict, "username_taken", "username already exists")
+	// ErrInvalidRole is returned for a role that does not exist
+	ErrInvalidRole = newError(KindValidation, "invalid_role", "invalid role")
+	// ErrLastAdmin is returned when a change would leave no enabled admin account
+	ErrLastAdmin = newError(KindConflict, "last_admin", "cannot remove the last enabled admin")
+	// ErrIncorrectPassword is returned when the current password given for a change is wrong
+	ErrIncorrectPassword = newError(KindValidation, "incorrect_password", "current password is incorrect")
+	// ErrPasswordReused is returned when a new password matches the current or a recent one
+	ErrPasswordReused = newError(KindValidation, "password_reused", "password was used recently, choose a different one")
+)
+
+// UserService manages user accounts and passwords
+type UserService interface {
+	PasswordPolicy() utils.PasswordPolicy
+	CreateUser(user *models.User) error
+	GetUserByUsername(username string) (*models.User, error)
+	ListUsersWithPermission(permission string) ([]models.User, error)
+	RegisterUser(user *models.User) error
+	ListUsers() ([]models.User, error)
+	GetUserByID(id uint) (*models.User, error)
+	SetUserDisabled(id uint, disabled bool) (*models.User, error)
+	ChangeUserRole(id uint, role string) (*models.User, error)
+	ResetPassword(id uint, password string) (*models.User, error)
+	ChangePassword(id uint, current, password string) (*models.User, error)
+	DeleteUser(id uint) error
+}
+
 type UserServiceImpl struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Config *config.Config
 }
 
-func NewUserService(db *gorm.DB) *UserServiceImpl {
-	return &UserServiceImpl{DB: db}
+var _ UserService = (*UserServiceImpl)(nil)
+
+func NewUserService(db *gorm.DB, cfg *config.Config) *UserServiceImpl {
+	return &UserServiceImpl{DB: db, Config: cfg}
+}
+
+// PasswordPolicy returns the configured rules for new passwords
+func (s *UserServiceImpl) PasswordPolicy() utils.PasswordPolicy {
+	return utils.PasswordPolicy{
+		MinLength:    s.Config.PasswordMinLength,
+		MinClasses:   s.Config.PasswordMinClasses,
+		RejectCommon: s.Config.PasswordRejectCommon,
+	}
 }
 
 // creates a new user
@@ -23,6 +70,9 @@ func (s *UserServiceImpl) CreateUser(user *models.User) error {
 	}
 	user.Password = hashedPassword
 	if err := s.DB.Create(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrUsernameTaken
+		}
 		log.Printf("Error creating user in DB: %v", err)
 		return err
 	}
@@ -36,4 +86,250 @@ func (s *UserServiceImpl) GetUserByUsername(username string) (*models.User, erro
 		return nil, err
 	}
 	return &user, nil
-}
\ No newline at end of file
+}
+// ListUsersWithPermission returns every enabled user whose role grants the permission, ordered by username
+func (s *UserServiceImpl) ListUsersWithPermission(permission string) ([]models.User, error) {
+	users := []models.User{}
+	err := s.DB.Where("role IN (?) AND disabled = ?", rolesWithPermission(s.DB, permission), false).
+		Order("username ASC").Find(&users).Error
+	if err != nil {
+		log.Printf("Error listing users with permission %s: %v", permission, err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// RegisterUser validates the role and password and creates a new user, failing if the
+// username is taken. The checks and the insert run in one transaction.
+func (s *UserServiceImpl) RegisterUser(user *models.User) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		users := &UserServiceImpl{DB: tx, Config: s.Config}
+		exists, err := roleExists(tx, user.Role)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrInvalidRole
+		}
+		if _, err := users.GetUserByUsername(user.Username); err == nil {
+			return ErrUsernameTaken
+		}
+		if err := users.PasswordPolicy().Check(user.Password, user.Username); err != nil {
+			return err
+		}
+		return users.CreateUser(user)
+	})
+}
+
+// ListUsers returns every user ordered by username
+func (s *UserServiceImpl) ListUsers() ([]models.User, error) {
+	users := []models.User{}
+	if err := s.DB.Order("username ASC").Find(&users).Error; err != nil {
+		log.Printf("Error listing users: %v", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUserByID retrieves a user by ID
+func (s *UserServiceImpl) GetUserByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := s.DB.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUserDisabled disables or re-enables a user's account
+func (s *UserServiceImpl) SetUserDisabled(id uint, disabled bool) (*models.User, error) {
+	return s.changeUser(id, func(user *models.User) bool {
+		user.Disabled = disabled
+		return disabled
+	})
+}
+
+// ChangeUserRole gives a user a different role
+func (s *UserServiceImpl) ChangeUserRole(id uint, role string) (*models.User, error) {
+	exists, err := roleExists(s.DB, role)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrInvalidRole
+	}
+	isAdmin, err := roleHasPermission(s.DB, role, models.PermUserAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return s.changeUser(id, func(user *models.User) bool {
+		user.Role = role
+		return !isAdmin
+	})
+}
+
+// ResetPassword replaces a user's password on behalf of an admin. The user has to
+// choose a new one at their next login.
+func (s *UserServiceImpl) ResetPassword(id uint, password string) (*models.User, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.PasswordPolicy().Check(password, user.Username); err != nil {
+		return nil, err
+	}
+	return s.setPassword(id, password, true)
+}
+
+// ChangePassword replaces the user's own password after checking the current one
+func (s *UserServiceImpl) ChangePassword(id uint, current, password string) (*models.User, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !utils.CheckPasswordHash(current, user.Password) {
+		return nil, ErrIncorrectPassword
+	}
+	if err := s.PasswordPolicy().Check(password, user.Username); err != nil {
+		return nil, err
+	}
+	return s.setPassword(id, password, false)
+}
+
+// setPassword stores a new password, refusing the current and recent ones, and keeps
+// the old hash in the user's password history
+func (s *UserServiceImpl) setPassword(id uint, password string, mustChange bool) (*models.User, error) {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		return nil, err
+	}
+
+	var user *models.User
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = lockUser(tx, id); err != nil {
+			return err
+		}
+
+		var history []models.PasswordHistory
+		if err := tx.Where("user_id = ?", id).Order("id DESC").Find(&history).Error; err != nil {
+			return err
+		}
+		if utils.CheckPasswordHash(password, user.Password) {
+			return ErrPasswordReused
+		}
+		// The current password is the first entry in the history once it is replaced
+		keep := max(s.Config.PasswordHistory-1, 0)
+		for i, old := range history {
+			if i < keep && utils.CheckPasswordHash(password, old.Hash) {
+				return ErrPasswordReused
+			}
+		}
+
+		if keep > 0 {
+			if err := tx.Create(&models.PasswordHistory{UserID: id, Hash: user.Password}).Error; err != nil {
+				return err
+			}
+			if len(history) >= keep {
+				// Only the newest entries are needed, the one just added included
+				if err := tx.Where("user_id = ? AND id <= ?", id, history[keep-1].ID).Delete(&models.PasswordHistory{}).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		user.Password = hashedPassword
+		user.MustChangePassword = mustChange
+		if err := tx.Save(user).Error; err != nil {
+			log.Printf("Error updating password of user ID %d: %v", id, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DeleteUser removes a user's account
+func (s *UserServiceImpl) DeleteUser(id uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := ensureOtherAdmin(tx, user); err != nil {
+			return err
+		}
+		if err := tx.Delete(user).Error; err != nil {
+			log.Printf("Error deleting user ID %d: %v", id, err)
+			return err
+		}
+		return nil
+	})
+}
+
+// changeUser applies change to a user in a transaction. change reports whether the user
+// may stop being an enabled admin, in which case another enabled admin must remain.
+func (s *UserServiceImpl) changeUser(id uint, change func(*models.User) bool) (*models.User, error) {
+	var user *models.User
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = lockUser(tx, id); err != nil {
+			return err
+		}
+		before := *user
+		if losesAdmin := change(user); losesAdmin {
+			if err := ensureOtherAdmin(tx, &before); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(user).Error; err != nil {
+			log.Printf("Error updating user ID %d: %v", id, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// lockUser loads a user for update
+func lockUser(tx *gorm.DB, id uint) (*models.User, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ensureOtherAdmin returns ErrLastAdmin when user is the only enabled user with the user:admin permission left
+func ensureOtherAdmin(tx *gorm.DB, user *models.User) error {
+	if user.Disabled {
+		return nil
+	}
+	isAdmin, err := roleHasPermission(tx, user.Role, models.PermUserAdmin)
+	if err != nil || !isAdmin {
+		return err
+	}
+	var others int64
+	err = tx.Model(&models.User{}).
+		Where("role IN (?) AND disabled = ? AND id <> ?", rolesWithPermission(tx, models.PermUserAdmin), false, user.ID).
+		Count(&others).Error
+	if err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
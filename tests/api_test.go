@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"encoding/json"
+	"medical_app/controllers"
+	"medical_app/models"
+	"medical_app/routes"
+	"medical_app/services"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter wires the services, controllers and routes on the test database as the
+// server does
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	authService := services.NewAuthService(testDB, testConfig)
+	userService := services.NewUserService(testDB, testConfig)
+	patientService := services.NewPatientService(testDB)
+	appointmentService := services.NewAppointmentService(testDB)
+	availabilityService := services.NewAvailabilityService(testDB)
+	noteService := services.NewEncounterNoteService(testDB)
+	auditService := services.NewAuditService(testDB)
+	fieldService := services.NewFieldVisibilityService(testDB)
+	roleService := services.NewRoleService(testDB)
+	tokenService := services.NewTokenService(testDB, testConfig)
+	mfaService := services.NewMFAService(testDB)
+	unitOfWork := services.NewUnitOfWork(testDB, testConfig)
+
+	router := gin.New()
+	routes.SetupRoutes(router,
+		controllers.NewAuthController(authService, userService, tokenService, mfaService, testConfig),
+		controllers.NewPatientController(unitOfWork, patientService, auditService, fieldService),
+		controllers.NewAppointmentController(appointmentService, userService, fieldService, auditService),
+		controllers.NewAvailabilityController(availabilityService, userService),
+		controllers.NewEncounterNoteController(unitOfWork, noteService, userService, auditService),
+		controllers.NewAuditController(auditService, fieldService),
+		controllers.NewUserController(userService, authService, tokenService),
+		controllers.NewMFAController(mfaService, userService),
+		controllers.NewRoleController(roleService, fieldService),
+		tokenService, roleService, testConfig)
+	return router
+}
+
+// loginAs creates a user with the role and returns it with an access token. The user is
+// deleted after the test, so it does not count in other tests (such as the last admin).
+func loginAs(t *testing.T, username, role string) (*models.User, string) {
+	t.Helper()
+	user := &models.User{Username: username, Password: "x", Role: role}
+	if err := testDB.Create(user).Error; err != nil {
+		t.Fatalf("Failed to create %s: %v", username, err)
+	}
+	t.Cleanup(func() { testDB.Unscoped().Delete(user) })
+	tokens, err := services.NewTokenService(testDB, testConfig).IssueTokens(user)
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+	return user, tokens.AccessToken
+}
+
+// apiRequest sends a request to the router with the token and headers (name, value pairs)
+func apiRequest(router *gin.Engine, method, path, token, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// problemOf checks that a response is a problem document with the status and code
+func problemOf(t *testing.T, w *httptest.ResponseRecorder, status int, code string) map[string]interface{} {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("Expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/problem+json") {
+		t.Errorf("Expected an application/problem+json response, got %q", contentType)
+	}
+	var problem map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Response is not JSON: %v", err)
+	}
+	if problem["code"] != code || problem["status"] != float64(status) {
+		t.Errorf("Expected code %q and status %d, got %v", code, status, problem)
+	}
+	return problem
+}
+
+// countReads returns how many read entries the audit log has for a patient on a route
+func countReads(t *testing.T, patientID uint, route string) int64 {
+	t.Helper()
+	var count int64
+	if err := testDB.Model(&models.AuditLog{}).Where("patient_id = ? AND action = ? AND route = ?", patientID, models.AuditRead, route).Count(&count).Error; err != nil {
+		t.Fatalf("failed to count audit entries: %v", err)
+	}
+	return count
+}
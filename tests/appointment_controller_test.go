@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"fmt"
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestAppointmentController_AuditsPatientReads tests that the patients embedded in
+// schedules are recorded in the audit log
+func TestAppointmentController_AuditsPatientReads(t *testing.T) {
+	router := newTestRouter(t)
+	_, token := loginAs(t, "appt-api-receptionist", models.RoleReceptionist)
+	doctor, doctorToken := loginAs(t, "appt-api-doctor", models.RoleDoctor)
+	patient := &models.Patient{FirstName: "Ida", LastName: "Schedule", Email: "appt-api@example.com"}
+	if err := services.NewPatientService(testDB).CreatePatient(patient); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+	start := time.Date(2031, 5, 6, 9, 0, 0, 0, time.Local)
+	appointment := &models.Appointment{PatientID: patient.ID, DoctorID: doctor.ID, StartTime: start, EndTime: start.Add(30 * time.Minute), Type: "consultation"}
+	if err := services.NewAppointmentService(testDB).BookAppointment(appointment); err != nil {
+		t.Fatalf("BookAppointment failed: %v", err)
+	}
+
+	// --- Schedule ---
+	w := apiRequest(router, http.MethodGet, fmt.Sprintf("/api/receptionist/appointments?doctor_id=%d&date=2031-05-06", doctor.ID), token, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if count := countReads(t, patient.ID, "/api/receptionist/appointments"); count != 1 {
+		t.Errorf("Expected the schedule to audit one read of the patient, got %d", count)
+	}
+
+	// --- The doctor's own schedule ---
+	w = apiRequest(router, http.MethodGet, "/api/doctor/appointments?date=2031-05-06", doctorToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if count := countReads(t, patient.ID, "/api/doctor/appointments"); count != 1 {
+		t.Errorf("Expected the doctor's schedule to audit one read of the patient, got %d", count)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"testing"
+	"time"
+)
+
+// TestAppointmentService_Booking tests booking, double-booking rejection, rescheduling and cancellation
+func TestAppointmentService_Booking(t *testing.T) {
+	appointmentService := services.NewAppointmentService(testDB)
+	patientService := services.NewPatientService(testDB)
+
+	doctor := &models.User{Username: "appt-doctor", Password: "x", Role: "doctor"}
+	if err := testDB.Create(doctor).Error; err != nil {
+		t.Fatalf("Failed to create doctor: %v", err)
+	}
+	patient := &models.Patient{FirstName: "Ada", LastName: "Visit", Email: "appt-1@example.com", Status: "active"}
+	if err := patientService.CreatePatient(patient); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+
+	start := time.Date(2030, 3, 4, 9, 0, 0, 0, time.UTC)
+	book := func(from, to time.Time) (*models.Appointment, error) {
+		appointment := &models.Appointment{PatientID: patient.ID, DoctorID: doctor.ID, StartTime: from, EndTime: to, Type: "consultation"}
+		return appointment, appointmentService.BookAppointment(appointment)
+	}
+
+	// --- Book ---
+	first, err := book(start, start.Add(30*time.Minute))
+	if err != nil {
+		t.Fatalf("BookAppointment failed: %v", err)
+	}
+	if first.State != models.AppointmentBooked {
+		t.Errorf("Expected state booked, got %s", first.State)
+	}
+
+	// --- Overlapping booking is rejected, back-to-back is fine ---
+	if _, err := book(start.Add(15*time.Minute), start.Add(45*time.Minute)); !errors.Is(err, services.ErrDoubleBooking) {
+		t.Errorf("Expected ErrDoubleBooking, got %v", err)
+	}
+	second, err := book(start.Add(30*time.Minute), start.Add(60*time.Minute))
+	if err != nil {
+		t.Fatalf("Back-to-back BookAppointment failed: %v", err)
+	}
+
+	// --- Reschedule into an occupied slot is rejected ---
+	if _, err := appointmentService.RescheduleAppointment(second.ID, start, start.Add(30*time.Minute)); !errors.Is(err, services.ErrDoubleBooking) {
+		t.Errorf("Expected ErrDoubleBooking on reschedule, got %v", err)
+	}
+
+	// --- Cancelling frees the slot ---
+	if _, err := appointmentService.CancelAppointment(first.ID, "patient called"); err != nil {
+		t.Fatalf("CancelAppointment failed: %v", err)
+	}
+	if _, err := appointmentService.RescheduleAppointment(second.ID, start, start.Add(30*time.Minute)); err != nil {
+		t.Errorf("Reschedule into cancelled slot failed: %v", err)
+	}
+
+	// --- State transitions ---
+	if _, err := appointmentService.UpdateAppointmentState(second.ID, models.AppointmentCompleted); !errors.Is(err, services.ErrInvalidAppointmentTransition) {
+		t.Errorf("Expected ErrInvalidAppointmentTransition, got %v", err)
+	}
+	for _, state := range []string{models.AppointmentCheckedIn, models.AppointmentInProgress, models.AppointmentCompleted} {
+		if _, err := appointmentService.UpdateAppointmentState(second.ID, state); err != nil {
+			t.Fatalf("UpdateAppointmentState(%s) failed: %v", state, err)
+		}
+	}
+
+	// --- Schedule ---
+	schedule, err := appointmentService.GetDoctorSchedule(doctor.ID, start.Truncate(24*time.Hour), start.Truncate(24*time.Hour).AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("GetDoctorSchedule failed: %v", err)
+	}
+	if len(schedule) != 2 || schedule[0].Patient == nil || schedule[0].Patient.FirstName != "Ada" {
+		t.Errorf("Unexpected schedule: %+v", schedule)
+	}
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestAuditController_FiltersChanges tests that the audit log shows the changes of
+// patient fields with the caller's field visibility, in searches and exports
+func TestAuditController_FiltersChanges(t *testing.T) {
+	router := newTestRouter(t)
+	_, token := loginAs(t, "audit-api-admin", models.RoleAdmin)
+
+	before := &models.Patient{FirstName: "Vera", LastName: "Visible", Phone: "+15550001234", DoctorNotes: "Diabetic"}
+	before.ID = 4343
+	after := *before
+	after.Phone = "+15550009876"
+	after.DoctorNotes = "Diabetic, insulin since May"
+	after.LastName = "Visibly"
+	actor := services.AuditActor{Username: "audit-api-receptionist", Role: models.RoleReceptionist}
+	if err := services.NewAuditService(testDB).Record(actor, models.AuditUpdate, before.ID, before, &after); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	check := func(name, changes string) {
+		var diff map[string]services.FieldChange
+		if err := json.Unmarshal([]byte(changes), &diff); err != nil {
+			t.Fatalf("%s: changes are not JSON: %v", name, err)
+		}
+		if _, ok := diff["DoctorNotes"]; ok {
+			t.Errorf("%s: expected doctor notes to be hidden from admins, got %v", name, diff)
+		}
+		if diff["Phone"].Before != "********1234" || diff["Phone"].After != "********9876" {
+			t.Errorf("%s: expected the phone number to be masked, got %v", name, diff["Phone"])
+		}
+		if diff["LastName"].After != "Visibly" {
+			t.Errorf("%s: expected the last name to be shown, got %v", name, diff["LastName"])
+		}
+	}
+
+	// --- Search ---
+	w := apiRequest(router, http.MethodGet, fmt.Sprintf("/api/admin/audit?patient_id=%d", before.ID), token, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var page struct {
+		Entries []models.AuditLog `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || len(page.Entries) != 1 {
+		t.Fatalf("Expected one entry, got %s (%v)", w.Body.String(), err)
+	}
+	check("search", page.Entries[0].Changes)
+
+	// --- Export ---
+	w = apiRequest(router, http.MethodGet, fmt.Sprintf("/api/admin/audit/export?patient_id=%d", before.ID), token, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var exported models.AuditLog
+	if err := json.Unmarshal([]byte(strings.TrimSpace(w.Body.String())), &exported); err != nil {
+		t.Fatalf("Expected one JSON line, got %s (%v)", w.Body.String(), err)
+	}
+	check("export", exported.Changes)
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"medical_app/db"
+	"medical_app/models"
+	"medical_app/services"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAuditService_HashChain tests field-level diffs, immutability and tamper detection
+func TestAuditService_HashChain(t *testing.T) {
+	auditService := services.NewAuditService(testDB)
+	actor := services.AuditActor{Username: "audit-user", Role: "receptionist", IP: "10.0.0.1", Method: "PUT", Route: "/api/receptionist/patients/:id"}
+
+	before := &models.Patient{FirstName: "Al", LastName: "Audit", Email: "audit-1@example.com", Status: "active"}
+	before.ID = 4242
+	after := *before
+	after.LastName = "Audited"
+
+	// --- Field-level diff ---
+	if err := auditService.Record(actor, models.AuditUpdate, before.ID, before, &after); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := auditService.RecordReads(actor, []uint{before.ID, before.ID}); err != nil {
+		t.Fatalf("RecordReads failed: %v", err)
+	}
+
+	patientID := before.ID
+	entries, total, err := auditService.ListEntries(services.AuditQuery{PatientID: &patientID})
+	if err != nil {
+		t.Fatalf("ListEntries failed: %v", err)
+	}
+	if total != 3 || entries[2].Action != models.AuditUpdate {
+		t.Fatalf("Expected 3 entries with the update first, got %d: %+v", total, entries)
+	}
+	var changes map[string]services.FieldChange
+	if err := json.Unmarshal([]byte(entries[2].Changes), &changes); err != nil {
+		t.Fatalf("Changes are not valid JSON: %v", err)
+	}
+	if len(changes) != 1 || changes["LastName"].Before != "Audit" || changes["LastName"].After != "Audited" {
+		t.Errorf("Expected only the LastName change, got %+v", changes)
+	}
+
+	// --- Entries cannot be changed through GORM ---
+	if err := testDB.Model(&entries[0]).Update("actor", "someone-else").Error; !errors.Is(err, models.ErrAuditLogImmutable) {
+		t.Errorf("Expected ErrAuditLogImmutable on update, got %v", err)
+	}
+
+	// --- The chain detects changes made behind the application's back ---
+	report, err := auditService.VerifyChain()
+	if err != nil || !report.Valid {
+		t.Fatalf("Expected a valid chain, got %+v (%v)", report, err)
+	}
+	tampered := entries[1]
+	testDB.Exec("UPDATE audit_logs SET actor = ? WHERE id = ?", "someone-else", tampered.ID)
+	defer testDB.Exec("UPDATE audit_logs SET actor = ? WHERE id = ?", tampered.Actor, tampered.ID)
+
+	report, err = auditService.VerifyChain()
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if report.Valid || report.BrokenAt == nil || *report.BrokenAt != tampered.ID {
+		t.Errorf("Expected chain broken at entry %d, got %+v", tampered.ID, report)
+	}
+}
+
+// TestAuditService_ConcurrentUnitsOfWork tests that units of work recording several
+// entries at the same time, like a patient merge, keep the chain intact. It uses a
+// database file with a connection per transaction, so only the database serializes them.
+func TestAuditService_ConcurrentUnitsOfWork(t *testing.T) {
+	cfg := *testConfig
+	cfg.LogLevel = "error"
+	cfg.DBMaxOpenConns = 8
+	cfg.DBMaxIdleConns = 8
+	cfg.DBConnectAttempts = 1
+	cfg.DBConnectTimeout = time.Second
+	db, err := database.Open("sqlite:"+filepath.Join(t.TempDir(), "audit.db"), &cfg)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator failed: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	const workers, rounds, perUnit = 4, 5, 3
+	uow := services.NewUnitOfWork(db, &cfg)
+	errs := make(chan error, workers*rounds)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			actor := services.AuditActor{Username: fmt.Sprintf("worker-%d", w), Role: "doctor"}
+			for r := 0; r < rounds; r++ {
+				errs <- uow.Do(func(tx *services.Services) error {
+					for i := 0; i < perUnit; i++ {
+						if err := tx.Audit.Record(actor, models.AuditUpdate, uint(w*100+r+1), nil, nil); err != nil {
+							return err
+						}
+						time.Sleep(time.Millisecond) // let the other units of work try to append
+					}
+					return nil
+				})
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Unit of work failed: %v", err)
+		}
+	}
+
+	var entries []models.AuditLog
+	if err := db.Order("id").Find(&entries).Error; err != nil {
+		t.Fatalf("failed to load entries: %v", err)
+	}
+	if len(entries) != workers*rounds*perUnit {
+		t.Fatalf("Expected %d entries, got %d", workers*rounds*perUnit, len(entries))
+	}
+	for i := 0; i < len(entries); i += perUnit {
+		for _, e := range entries[i : i+perUnit] {
+			if e.Actor != entries[i].Actor || *e.PatientID != *entries[i].PatientID {
+				t.Fatalf("Expected the entries of a unit of work to be consecutive, got %+v", entries[i:i+perUnit])
+			}
+		}
+	}
+	report, err := services.NewAuditService(db).VerifyChain()
+	if err != nil || !report.Valid || report.Checked != int64(len(entries)) {
+		t.Errorf("Expected a valid chain of %d entries, got %+v (%v)", len(entries), report, err)
+	}
+}
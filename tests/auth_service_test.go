@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/config"
+	"medical_app/models"
+	"medical_app/services"
+	"testing"
+	"time"
+)
+
+// TestAuthService_Lockout tests failed-attempt counting, backoff, lockout and admin unlock
+func TestAuthService_Lockout(t *testing.T) {
+	cfg := *testConfig
+	cfg.LoginMaxFailures = 3
+	cfg.LoginBackoffBase = time.Nanosecond // keep backoff out of the way of the lockout checks
+	authService := services.NewAuthService(testDB, &cfg)
+	userService := services.NewUserService(testDB, testConfig)
+
+	if err := userService.CreateUser(&models.User{Username: "lock-user", Password: "rightpass", Role: models.RoleDoctor}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	// --- Failures lock the username, unknown usernames behave the same ---
+	for _, username := range []string{"lock-user", "lock-ghost"} {
+		for i := 0; i < cfg.LoginMaxFailures; i++ {
+			if _, err := authService.Login(username, "wrongpass", "10.1.0.1"); !errors.Is(err, services.ErrInvalidCredentials) {
+				t.Fatalf("%s attempt %d: expected ErrInvalidCredentials, got %v", username, i+1, err)
+			}
+		}
+		var throttled *services.LoginThrottledError
+		if _, err := authService.Login(username, "rightpass", "10.1.0.1"); !errors.As(err, &throttled) || !throttled.Locked {
+			t.Errorf("%s: expected a lockout even with the right password, got %v", username, err)
+		}
+	}
+
+	// --- Admin unlock ---
+	if err := authService.UnlockUser("lock-user"); err != nil {
+		t.Fatalf("UnlockUser failed: %v", err)
+	}
+	if _, err := authService.Login("lock-user", "rightpass", "10.1.0.2"); err != nil {
+		t.Errorf("Expected login to work after unlock, got %v", err)
+	}
+}
+
+// TestAuthService_UnknownUserTiming tests that a login with an unknown username takes
+// about as long as one with a wrong password, so usernames cannot be told by timing
+func TestAuthService_UnknownUserTiming(t *testing.T) {
+	cfg := *testConfig
+	cfg.LoginMaxFailures = 100
+	cfg.LoginMaxIPFailures = 100
+	cfg.LoginBackoffBase = time.Nanosecond
+	authService := services.NewAuthService(testDB, &cfg)
+	if err := services.NewUserService(testDB, testConfig).CreateUser(&models.User{Username: "timing-user", Password: "rightpass", Role: models.RoleDoctor}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	elapsed := func(username string) time.Duration {
+		started := time.Now()
+		for i := 0; i < 3; i++ {
+			if _, err := authService.Login(username, "wrongpass", "10.5.0.1"); !errors.Is(err, services.ErrInvalidCredentials) {
+				t.Fatalf("%s: expected ErrInvalidCredentials, got %v", username, err)
+			}
+		}
+		return time.Since(started)
+	}
+	known, unknown := elapsed("timing-user"), elapsed("timing-ghost")
+	if unknown < known/2 {
+		t.Errorf("Expected an unknown username to take about as long as a wrong password, got %s and %s", unknown, known)
+	}
+}
+
+// TestAuthService_MFAFailuresSurviveLogin tests that wrong second factor codes lock the
+// username even when the password is entered again in between
+func TestAuthService_MFAFailuresSurviveLogin(t *testing.T) {
+	cfg := *testConfig
+	cfg.LoginMaxFailures = 3
+	cfg.LoginBackoffBase = time.Nanosecond
+	authService := services.NewAuthService(testDB, &cfg)
+	userService := services.NewUserService(testDB, testConfig)
+
+	user := &models.User{Username: "mfa-lock-user", Password: "rightpass", Role: models.RoleDoctor}
+	if err := userService.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	testDB.Model(user).Update("mfa_enabled", true)
+
+	wrongCode := func(*models.User) error { return services.ErrInvalidMFACode }
+	for i := 0; i < cfg.LoginMaxFailures; i++ {
+		if _, err := authService.Login("mfa-lock-user", "rightpass", "10.4.0.1"); err != nil {
+			t.Fatalf("Attempt %d: expected the password to be accepted, got %v", i+1, err)
+		}
+		if _, err := authService.SecondFactor("mfa-lock-user", "10.4.0.1", wrongCode); !errors.Is(err, services.ErrInvalidMFACode) && !errors.Is(err, services.ErrLoginThrottled) {
+			t.Fatalf("Attempt %d: expected ErrInvalidMFACode, got %v", i+1, err)
+		}
+	}
+	var throttled *services.LoginThrottledError
+	if _, err := authService.Login("mfa-lock-user", "rightpass", "10.4.0.1"); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Errorf("Expected the username to be locked after %d wrong codes, got %v", cfg.LoginMaxFailures, err)
+	}
+}
+
+// TestAuthService_Backoff tests that repeated failures from one IP are delayed
+func TestAuthService_Backoff(t *testing.T) {
+	cfg := config.Config{
+		LoginMaxFailures:     100,
+		LoginMaxIPFailures:   100,
+		LoginLockoutDuration: time.Hour,
+		LoginBackoffBase:     time.Minute,
+	}
+	authService := services.NewAuthService(testDB, &cfg)
+
+	for i := 0; i < 3; i++ {
+		if _, err := authService.Login("backoff-user", "wrong", "10.2.0.1"); !errors.Is(err, services.ErrInvalidCredentials) {
+			t.Fatalf("Attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+	}
+
+	var throttled *services.LoginThrottledError
+	if _, err := authService.Login("other-user", "wrong", "10.2.0.1"); !errors.As(err, &throttled) || throttled.Locked {
+		t.Fatalf("Expected a backoff delay for the IP, got %v", err)
+	}
+	if throttled.RetryAfter <= 0 || throttled.RetryAfter > time.Minute {
+		t.Errorf("Expected a retry delay of at most a minute, got %v", throttled.RetryAfter)
+	}
+}
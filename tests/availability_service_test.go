@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"testing"
+	"time"
+)
+
+// TestAvailabilityService_FreeSlots tests slot generation around exceptions and booked appointments
+func TestAvailabilityService_FreeSlots(t *testing.T) {
+	availabilityService := services.NewAvailabilityService(testDB)
+	appointmentService := services.NewAppointmentService(testDB)
+
+	doctor := &models.User{Username: "slots-doctor", Password: "x", Role: "doctor"}
+	if err := testDB.Create(doctor).Error; err != nil {
+		t.Fatalf("Failed to create doctor: %v", err)
+	}
+	patient := &models.Patient{FirstName: "Sam", LastName: "Slot", Email: "slots-1@example.com", Status: "active"}
+	if err := testDB.Create(patient).Error; err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	// --- Invalid weekly hours are rejected ---
+	overlapping := []models.DoctorAvailability{
+		{Weekday: int(time.Monday), StartTime: "09:00", EndTime: "12:00"},
+		{Weekday: int(time.Monday), StartTime: "11:00", EndTime: "13:00"},
+	}
+	if err := availabilityService.SetWeeklyAvailability(doctor.ID, overlapping); !errors.Is(err, services.ErrInvalidAvailability) {
+		t.Errorf("Expected ErrInvalidAvailability for overlapping hours, got %v", err)
+	}
+
+	// Mondays 09:00-11:00 in 30 minute slots
+	weekly := []models.DoctorAvailability{{Weekday: int(time.Monday), StartTime: "09:00", EndTime: "11:00", SlotMinutes: 30}}
+	if err := availabilityService.SetWeeklyAvailability(doctor.ID, weekly); err != nil {
+		t.Fatalf("SetWeeklyAvailability failed: %v", err)
+	}
+
+	monday := time.Date(2030, 3, 4, 0, 0, 0, 0, time.UTC) // a Monday
+	at := func(hour, minute int) time.Time {
+		return monday.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	// Block 09:30-10:00 with leave and book 10:00-10:30
+	if err := availabilityService.AddException(&models.AvailabilityException{DoctorID: doctor.ID, StartTime: at(9, 30), EndTime: at(10, 0), Reason: "meeting"}); err != nil {
+		t.Fatalf("AddException failed: %v", err)
+	}
+	appointment := &models.Appointment{PatientID: patient.ID, DoctorID: doctor.ID, StartTime: at(10, 0), EndTime: at(10, 30), Type: "consultation"}
+	if err := appointmentService.BookAppointment(appointment); err != nil {
+		t.Fatalf("BookAppointment failed: %v", err)
+	}
+
+	slots, err := availabilityService.FreeSlots(doctor.ID, monday, monday.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("FreeSlots failed: %v", err)
+	}
+	if len(slots) != 2 || !slots[0].Start.Equal(at(9, 0)) || !slots[1].Start.Equal(at(10, 30)) {
+		t.Errorf("Expected free slots at 09:00 and 10:30, got %+v", slots)
+	}
+
+	if _, err := availabilityService.FreeSlots(doctor.ID, monday, monday.AddDate(0, 2, 0)); !errors.Is(err, services.ErrInvalidSlotRange) {
+		t.Errorf("Expected ErrInvalidSlotRange for a two month range, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const configTestSecret = "0123456789abcdef0123456789abcdef"
+
+// writeConfigFile writes a file into a temporary directory and returns its path
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// settingOf finds a setting by key in the redacted listing
+func settingOf(cfg *config.Config, key string) config.Setting {
+	for _, s := range cfg.Settings() {
+		if s.Key == key {
+			return s
+		}
+	}
+	return config.Setting{}
+}
+
+// TestConfig_Precedence tests that the config file overrides the defaults and the
+// environment overrides the file, in YAML and TOML
+func TestConfig_Precedence(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+server:
+  port: 9090
+  cors_allowed_origins: [https://portal.example.com, http://localhost:5173]
+database:
+  url: sqlite:medical.db
+  max_open_conns: 10
+auth:
+  jwt_secret: ` + configTestSecret + `
+  access_token_ttl: 5m
+features:
+  patient_purge: false
+`,
+		"config.toml": `
+[server]
+port = 9090
+cors_allowed_origins = ["https://portal.example.com", "http://localhost:5173"]
+
+[database]
+url = "sqlite:medical.db"
+max_open_conns = 10
+
+[auth]
+jwt_secret = "` + configTestSecret + `"
+access_token_ttl = "5m"
+
+[features]
+patient_purge = false
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := writeConfigFile(t, name, content)
+			t.Setenv("ACCESS_TOKEN_TTL", "10m")
+
+			cfg, err := config.Load(path)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if cfg.Port != "9090" || cfg.DBMaxOpenConns != 10 || cfg.FeaturePatientPurge {
+				t.Errorf("Expected the file's settings, got port %s, %d connections, purge %v", cfg.Port, cfg.DBMaxOpenConns, cfg.FeaturePatientPurge)
+			}
+			if want := []string{"https://portal.example.com", "http://localhost:5173"}; !reflect.DeepEqual(cfg.CORSAllowedOrigins, want) {
+				t.Errorf("Expected origins %v, got %v", want, cfg.CORSAllowedOrigins)
+			}
+			if cfg.AccessTokenTTL != 10*time.Minute {
+				t.Errorf("Expected the environment to override the file, got %s", cfg.AccessTokenTTL)
+			}
+			if cfg.RefreshTokenTTL != 7*24*time.Hour || cfg.LogLevel != "info" {
+				t.Errorf("Expected defaults for unset settings, got %s and %q", cfg.RefreshTokenTTL, cfg.LogLevel)
+			}
+			if source := settingOf(cfg, "auth.access_token_ttl").Source; source != "env ACCESS_TOKEN_TTL" {
+				t.Errorf("Expected the TTL to come from the environment, got %q", source)
+			}
+			if source := settingOf(cfg, "server.port").Source; source != "file "+path {
+				t.Errorf("Expected the port to come from the file, got %q", source)
+			}
+		})
+	}
+}
+
+// TestConfig_Validation tests that every problem is reported at once
+func TestConfig_Validation(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: 70000
+  cors_allowed_origins: ["*", "portal.example.com"]
+  read_timeout: soon
+database:
+  max_open_conns: 2
+  max_idle_conns: 4
+log:
+  level: verbose
+sever:
+  port: 8080
+`)
+	t.Setenv("JWT_SECRET", "short")
+	t.Setenv("PASSWORD_HISTORY", "0")
+
+	_, err := config.Load(path)
+	var invalid *config.ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	for _, want := range []string{
+		"server.read_timeout in " + path + ": must be a duration",
+		"sever.port in " + path + ": unknown setting",
+		"database.url (DATABASE_URL): required",
+		"auth.jwt_secret (JWT_SECRET): must be at least 32 characters",
+		"server.port (PORT): must be a port number",
+		"log.level (LOG_LEVEL): must be one of",
+		"server.cors_allowed_origins (CORS_ALLOWED_ORIGINS): * cannot be combined",
+		`server.cors_allowed_origins (CORS_ALLOWED_ORIGINS): "portal.example.com" is not an origin`,
+		"database.max_idle_conns (DB_MAX_IDLE_CONNS): must not exceed",
+		"password.history (PASSWORD_HISTORY): must be a positive integer",
+	} {
+		found := false
+		for _, problem := range invalid.Problems {
+			found = found || strings.HasPrefix(problem, want)
+		}
+		if !found {
+			t.Errorf("Expected a problem %q, got:\n%s", want, err)
+		}
+	}
+
+	if _, err := config.Load(writeConfigFile(t, "config.ini", "port = 1")); err == nil {
+		t.Errorf("Expected an unknown file format to fail")
+	}
+}
+
+// TestConfig_SecretFiles tests reading secrets from files named in the environment or
+// the config file
+func TestConfig_SecretFiles(t *testing.T) {
+	secretPath := writeConfigFile(t, "jwt_secret", configTestSecret+"\n")
+	t.Setenv("DATABASE_URL", "memory:")
+
+	// --- From the environment ---
+	t.Setenv("JWT_SECRET_FILE", secretPath)
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.JWTSecret != configTestSecret {
+		t.Errorf("Expected the secret from the file without its line break, got %q", cfg.JWTSecret)
+	}
+	t.Setenv("JWT_SECRET", configTestSecret)
+	if _, err := config.Load(""); err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Errorf("Expected JWT_SECRET and JWT_SECRET_FILE together to fail, got %v", err)
+	}
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := config.Load(""); err == nil || !strings.Contains(err.Error(), "failed to read secret file") {
+		t.Errorf("Expected a missing secret file to fail, got %v", err)
+	}
+	t.Setenv("JWT_SECRET_FILE", "")
+
+	// --- From the config file ---
+	path := writeConfigFile(t, "config.yaml", "auth:\n  jwt_secret_file: "+secretPath+"\n")
+	cfg, err = config.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.JWTSecret != configTestSecret {
+		t.Errorf("Expected the secret from the file named in the config, got %q", cfg.JWTSecret)
+	}
+	path = writeConfigFile(t, "config.yaml", "server:\n  port_file: "+secretPath+"\n")
+	if _, err := config.Load(path); err == nil || !strings.Contains(err.Error(), "server.port_file in "+path+": unknown setting") {
+		t.Errorf("Expected _file to be refused for settings that are not secret, got %v", err)
+	}
+}
+
+// TestConfig_Redacted tests that the settings listing hides secrets
+func TestConfig_Redacted(t *testing.T) {
+	t.Setenv("JWT_SECRET", configTestSecret)
+	t.Setenv("ADMIN_PASSWORD", "Admin-Password-1")
+	t.Setenv("DATABASE_URL", "postgres://app:hunter2@db:5432/medical?sslmode=disable")
+	t.Setenv("DATABASE_REPLICA_URL", "host=replica user=app password=hunter2 dbname=medical")
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	var dump strings.Builder
+	if err := cfg.Dump(&dump); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	for _, secret := range []string{configTestSecret, "Admin-Password-1", "hunter2"} {
+		if strings.Contains(dump.String(), secret) {
+			t.Errorf("Expected %q to be redacted:\n%s", secret, dump.String())
+		}
+	}
+	if value := settingOf(cfg, "database.url").Value; value != "postgres://app:REDACTED@db:5432/medical?sslmode=disable" {
+		t.Errorf("Expected only the password to be hidden, got %q", value)
+	}
+	if value := settingOf(cfg, "database.replica_url").Value; value != "host=replica user=app password=REDACTED dbname=medical" {
+		t.Errorf("Expected only the password to be hidden, got %q", value)
+	}
+	if value := settingOf(cfg, "auth.jwt_secret").Value; value != "REDACTED" {
+		t.Errorf("Expected the JWT secret to be hidden, got %q", value)
+	}
+	if value := settingOf(cfg, "server.port").Value; value != "8080" {
+		t.Errorf("Expected other settings to be shown, got %q", value)
+	}
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"medical_app/config"
+	"medical_app/db"
+	"medical_app/models"
+	"medical_app/services"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+)
+
+// TestDatabase_Dialector tests choosing the driver from the database URL scheme
+func TestDatabase_Dialector(t *testing.T) {
+	cases := []struct {
+		url, driver, dsn string
+	}{
+		{"postgres://app:secret@db:5432/medical?sslmode=disable", "postgres", "postgres://app:secret@db:5432/medical?sslmode=disable"},
+		{"postgresql://db/medical", "postgres", "postgresql://db/medical"},
+		{"host=db user=app password=a:b dbname=medical", "postgres", "host=db user=app password=a:b dbname=medical"},
+		{"sqlite:data/medical.db", "sqlite", "data/medical.db?_busy_timeout=5000&_txlock=immediate"},
+		{"sqlite:///var/lib/medical.db?_foreign_keys=on", "sqlite", "/var/lib/medical.db?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate"},
+		{"sqlite://medical.db?_busy_timeout=100&_txlock=deferred", "sqlite", "medical.db?_busy_timeout=100&_txlock=deferred"},
+		{"sqlite::memory:", "sqlite", "file:medical_app?mode=memory&cache=shared"},
+		{"memory:", "sqlite", "file:medical_app?mode=memory&cache=shared"},
+	}
+	for _, c := range cases {
+		dialector, err := database.Dialector(c.url)
+		if err != nil {
+			t.Errorf("Dialector(%q) failed: %v", c.url, err)
+			continue
+		}
+		dsn := ""
+		switch d := dialector.(type) {
+		case *postgres.Dialector:
+			dsn = d.DSN
+		case *sqlite.Dialector:
+			dsn = d.DSN
+		}
+		if dialector.Name() != c.driver || dsn != c.dsn {
+			t.Errorf("Dialector(%q) = %s %q, expected %s %q", c.url, dialector.Name(), dsn, c.driver, c.dsn)
+		}
+	}
+
+	for _, url := range []string{"mysql://db/medical", "sqlite:", "sqlite://?cache=shared"} {
+		if _, err := database.Dialector(url); err == nil {
+			t.Errorf("Expected Dialector(%q) to fail", url)
+		}
+	}
+}
+
+// TestDatabase_Open tests the connection pool settings and the retries at startup
+func TestDatabase_Open(t *testing.T) {
+	cfg := &config.Config{
+		DBMaxOpenConns:    7,
+		DBMaxIdleConns:    3,
+		DBConnMaxLifetime: time.Minute,
+		DBConnectTimeout:  time.Second,
+		DBConnectAttempts: 3,
+		DBConnectBackoff:  time.Millisecond,
+	}
+
+	// --- The pool is configured ---
+	memory, err := database.Open("memory:", cfg)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	sqlDB, _ := memory.DB()
+	defer sqlDB.Close()
+	if stats := sqlDB.Stats(); stats.MaxOpenConnections != 7 {
+		t.Errorf("Expected at most 7 open connections, got %d", stats.MaxOpenConnections)
+	}
+
+	// --- An unreachable database is retried, then reported ---
+	started := time.Now()
+	_, err = database.Open("postgres://app@127.0.0.1:1/medical?sslmode=disable", cfg)
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("Expected an error after 3 attempts, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Expected the retries to back off briefly, took %s", elapsed)
+	}
+}
+
+// TestDatabase_ReadReplica tests that listing and searching patients use the read replica
+func TestDatabase_ReadReplica(t *testing.T) {
+	replica := openMigrationDB(t, "replica")
+	migrator, err := database.NewMigrator(replica)
+	if err != nil {
+		t.Fatalf("NewMigrator failed: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	// Only on the replica, as if it had not been deleted there yet
+	onReplica := &models.Patient{FirstName: "Rep", LastName: "Lica", Phone: "555-010-2424"}
+	if err := services.NewPatientService(replica).CreatePatient(onReplica); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+
+	patientService := services.NewPatientService(testDB)
+	patientService.Replica = replica
+	results, err := patientService.SearchPatients("Lica", 5)
+	if err != nil || len(results) != 1 || results[0].Patient.MRN != onReplica.MRN {
+		t.Errorf("Expected search to find the replica's patient, got %+v (%v)", results, err)
+	}
+	page, err := patientService.ListPatients(services.PatientListOptions{})
+	if err != nil || page.Total != 1 {
+		t.Errorf("Expected the listing to come from the replica, got %+v (%v)", page, err)
+	}
+	if stored, err := patientService.GetPatientByID(onReplica.ID); err == nil && stored.MRN == onReplica.MRN {
+		t.Errorf("Expected single patients to be read from the primary")
+	}
+}
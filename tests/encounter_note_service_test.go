@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"testing"
+)
+
+// TestEncounterNoteService_History tests drafts, signing and append-only amendments
+func TestEncounterNoteService_History(t *testing.T) {
+	noteService := services.NewEncounterNoteService(testDB)
+
+	author := &models.User{Username: "notes-doctor", Password: "x", Role: "doctor"}
+	colleague := &models.User{Username: "notes-colleague", Password: "x", Role: "doctor"}
+	for _, u := range []*models.User{author, colleague} {
+		if err := testDB.Create(u).Error; err != nil {
+			t.Fatalf("Failed to create doctor: %v", err)
+		}
+	}
+	patient := &models.Patient{FirstName: "Nora", LastName: "Notes", Email: "notes-1@example.com", Status: "active"}
+	if err := testDB.Create(patient).Error; err != nil {
+		t.Fatalf("Failed to create patient: %v", err)
+	}
+
+	// --- Drafts are private to their author ---
+	draft, err := noteService.CreateNote(patient.ID, author, services.SOAPContent{Subjective: "Headache for 3 days"}, nil, false)
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	if _, err := noteService.GetNote(draft.ID, colleague.ID); !errors.Is(err, services.ErrNoteNotFound) {
+		t.Errorf("Expected colleague not to see the draft, got %v", err)
+	}
+	if _, err := noteService.UpdateDraft(draft.ID, author, services.SOAPContent{Subjective: "Headache for 3 days", Plan: "Ibuprofen"}); err != nil {
+		t.Fatalf("UpdateDraft failed: %v", err)
+	}
+
+	// --- Signed notes are immutable ---
+	signed, err := noteService.SignNote(draft.ID, author)
+	if err != nil {
+		t.Fatalf("SignNote failed: %v", err)
+	}
+	if signed.State != models.NoteSigned || signed.SignedAt == nil {
+		t.Errorf("Expected signed note with timestamp, got %+v", signed)
+	}
+	if _, err := noteService.UpdateDraft(draft.ID, author, services.SOAPContent{Plan: "changed"}); !errors.Is(err, services.ErrNoteSigned) {
+		t.Errorf("Expected ErrNoteSigned when editing a signed note, got %v", err)
+	}
+
+	// --- Amendments are new notes; the original is preserved ---
+	if _, err := noteService.AmendNote(draft.ID, colleague, services.SOAPContent{Plan: "Paracetamol"}, "", true); !errors.Is(err, services.ErrAmendmentReasonRequired) {
+		t.Errorf("Expected ErrAmendmentReasonRequired, got %v", err)
+	}
+	amendment, err := noteService.AmendNote(draft.ID, colleague, services.SOAPContent{Plan: "Paracetamol"}, "NSAID allergy", true)
+	if err != nil {
+		t.Fatalf("AmendNote failed: %v", err)
+	}
+	if amendment.AmendsID == nil || *amendment.AmendsID != draft.ID || amendment.AuthorID != colleague.ID {
+		t.Errorf("Amendment not linked to original: %+v", amendment)
+	}
+
+	notes, err := noteService.ListNotes(patient.ID, colleague.ID)
+	if err != nil {
+		t.Fatalf("ListNotes failed: %v", err)
+	}
+	if len(notes) != 2 || notes[0].ID != amendment.ID || notes[1].Plan != "Ibuprofen" {
+		t.Errorf("Expected amendment followed by untouched original, got %+v", notes)
+	}
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"testing"
+)
+
+// TestProblemResponses tests that errors are answered as problem details with the status
+// and code of their kind, and invalid fields listed by their JSON names
+func TestProblemResponses(t *testing.T) {
+	router := newTestRouter(t)
+	_, token := loginAs(t, "problem-api-receptionist", models.RoleReceptionist)
+	patient := &models.Patient{FirstName: "Pia", LastName: "Problem", Email: "problem-api@example.com"}
+	path := createAPIPatient(t, patient)
+
+	cases := []struct {
+		name         string
+		method, path string
+		token, body  string
+		status       int
+		code         string
+		field        string
+	}{
+		{"missing token", http.MethodGet, "/api/patients", "", "", http.StatusUnauthorized, "missing_token", ""},
+		{"invalid id", http.MethodGet, "/api/patients/abc", token, "", http.StatusBadRequest, "invalid_id", ""},
+		{"not found", http.MethodGet, "/api/patients/999999", token, "", http.StatusNotFound, services.ErrPatientNotFound.Code, ""},
+		{"malformed body", http.MethodPost, "/api/receptionist/patients", token, `{"first_name": `, http.StatusBadRequest, "invalid_body", ""},
+		{"wrong type", http.MethodPost, "/api/receptionist/patients", token, `{"first_name": 5, "last_name": "Lee", "phone": "5550100200"}`, http.StatusBadRequest, services.ErrInvalidFields.Code, "first_name"},
+		{"invalid value", http.MethodPost, "/api/receptionist/patients", token, `{"first_name": "Ann", "last_name": "Lee", "phone": "12"}`, http.StatusBadRequest, services.ErrInvalidFields.Code, "phone"},
+		{"empty patch", http.MethodPatch, path, token, `{}`, http.StatusBadRequest, services.ErrEmptyPatch.Code, ""},
+	}
+	for _, tc := range cases {
+		w := apiRequest(router, tc.method, tc.path, tc.token, tc.body, "If-Match", `"1"`)
+		problem := problemOf(t, w, tc.status, tc.code)
+		if problem["type"] != "about:blank" || problem["title"] != http.StatusText(tc.status) || problem["instance"] != tc.path {
+			t.Errorf("%s: expected the problem members, got %v", tc.name, problem)
+		}
+		if tc.field != "" {
+			fields, _ := problem["fields"].(map[string]interface{})
+			if _, ok := fields[tc.field]; !ok {
+				t.Errorf("%s: expected %s in the invalid fields, got %v", tc.name, tc.field, problem["fields"])
+			}
+		}
+	}
+}
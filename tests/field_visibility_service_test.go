@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"testing"
+)
+
+// TestFieldVisibilityService tests per-role patient field visibility and masking
+func TestFieldVisibilityService(t *testing.T) {
+	fieldService := services.NewFieldVisibilityService(testDB)
+	patient := &models.Patient{FirstName: "Vera", LastName: "Lind", Phone: "5550199", Address: models.Address{City: "Bergen"}, DoctorNotes: "Allergic to penicillin", Status: "active"}
+	patient.ID = 42
+	appointment := &models.Appointment{PatientID: 42, Patient: patient, Type: models.AppointmentTypes[0]}
+
+	// --- Receptionists see demographics but no clinical notes ---
+	view, err := fieldService.PatientView(models.RoleReceptionist)
+	if err != nil {
+		t.Fatalf("PatientView failed: %v", err)
+	}
+	fields := view.Patient(patient)
+	if _, ok := fields["DoctorNotes"]; ok {
+		t.Error("Expected DoctorNotes to be hidden from receptionists")
+	}
+	if fields["FirstName"] != "Vera" || fields["Phone"] != "5550199" || fields["ID"] != float64(42) {
+		t.Errorf("Expected demographics to be visible, got %v", fields)
+	}
+	nested, _ := view.Appointment(appointment)["Patient"].(map[string]interface{})
+	if _, ok := nested["DoctorNotes"]; ok || nested["LastName"] != "Lind" {
+		t.Errorf("Expected the appointment's patient to be filtered too, got %v", nested)
+	}
+
+	// --- Doctors see clinical fields ---
+	view, _ = fieldService.PatientView(models.RoleDoctor)
+	if view.Patient(patient)["DoctorNotes"] != "Allergic to penicillin" {
+		t.Error("Expected DoctorNotes to be visible to doctors")
+	}
+
+	// --- Admins get masked contact details ---
+	view, _ = fieldService.PatientView(models.RoleAdmin)
+	fields = view.Patient(patient)
+	if got := fields["Phone"]; got != "***0199" {
+		t.Errorf("Expected a masked phone, got %v", got)
+	}
+	if address, _ := fields["Address"].(map[string]interface{}); address["City"] != "**rgen" || address["Line1"] != "" {
+		t.Errorf("Expected the address parts to be masked one by one, got %v", fields["Address"])
+	}
+
+	// --- Rules can be changed per role ---
+	if err := fieldService.SetRules(models.RoleReceptionist, map[string]string{"address": models.FieldHidden}); err != nil {
+		t.Fatalf("SetRules failed: %v", err)
+	}
+	rules, _ := fieldService.Rules(models.RoleReceptionist)
+	if rules["address"] != models.FieldHidden || rules["first_name"] != models.FieldVisible {
+		t.Errorf("Unexpected receptionist rules %v", rules)
+	}
+	if err := fieldService.SetRules(models.RoleReceptionist, map[string]string{"address": models.FieldVisible}); err != nil {
+		t.Fatalf("SetRules failed: %v", err)
+	}
+	if err := fieldService.SetRules(models.RoleReceptionist, map[string]string{"blood_type": models.FieldHidden}); !errors.Is(err, services.ErrUnknownField) {
+		t.Errorf("Expected ErrUnknownField, got %v", err)
+	}
+	if err := fieldService.SetRules(models.RoleReceptionist, map[string]string{"address": "blurred"}); !errors.Is(err, services.ErrInvalidFieldAccess) {
+		t.Errorf("Expected ErrInvalidFieldAccess, got %v", err)
+	}
+	if err := fieldService.SetRules("nurse", map[string]string{"address": models.FieldHidden}); !errors.Is(err, services.ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"medical_app/utils"
+	"testing"
+	"time"
+)
+
+// currentTOTP returns the code an authenticator app would show right now
+func currentTOTP(t *testing.T, secret string) string {
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatalf("TOTPCode failed: %v", err)
+	}
+	return code
+}
+
+// TestTOTP checks the code generator against the RFC 6238 SHA-1 test vector
+func TestTOTP(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // base32 of "12345678901234567890"
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Unix(59, 0)))
+	if err != nil {
+		t.Fatalf("TOTPCode failed: %v", err)
+	}
+	if code != "287082" {
+		t.Errorf("Expected code 287082, got %s", code)
+	}
+
+	now := time.Unix(1111111109, 0)
+	current, _ := utils.TOTPCode(secret, utils.TOTPStep(now))
+	step, ok := utils.ValidateTOTP(secret, current, now, 0)
+	if !ok {
+		t.Fatal("Expected the current code to be valid")
+	}
+	if _, ok := utils.ValidateTOTP(secret, current, now, step); ok {
+		t.Error("Expected a replayed code to be rejected")
+	}
+}
+
+// TestMFAService tests enrollment, verification, recovery codes and the role policy
+func TestMFAService(t *testing.T) {
+	mfaService := services.NewMFAService(testDB)
+	userService := services.NewUserService(testDB, testConfig)
+	authService := services.NewAuthService(testDB, testConfig)
+
+	if err := userService.RegisterUser(&models.User{Username: "mfa-user", Password: "Second-Factor-9", Role: models.RoleReceptionist}); err != nil {
+		t.Fatalf("RegisterUser failed: %v", err)
+	}
+	reload := func() *models.User {
+		user, err := userService.GetUserByUsername("mfa-user")
+		if err != nil {
+			t.Fatalf("GetUserByUsername failed: %v", err)
+		}
+		return user
+	}
+
+	// --- Enrollment ---
+	if step, _ := mfaService.LoginStep(reload()); step != services.LoginComplete {
+		t.Errorf("Expected no MFA step before enrollment, got %q", step)
+	}
+	enrollment, err := mfaService.BeginEnrollment(reload())
+	if err != nil {
+		t.Fatalf("BeginEnrollment failed: %v", err)
+	}
+	if _, err := mfaService.ConfirmEnrollment(reload(), "000000"); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Errorf("Expected ErrInvalidMFACode for a wrong code, got %v", err)
+	}
+	recoveryCodes, err := mfaService.ConfirmEnrollment(reload(), currentTOTP(t, enrollment.Secret))
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment failed: %v", err)
+	}
+	if len(recoveryCodes) != 10 {
+		t.Errorf("Expected 10 recovery codes, got %d", len(recoveryCodes))
+	}
+	if step, _ := mfaService.LoginStep(reload()); step != services.LoginNeedsMFA {
+		t.Errorf("Expected the MFA step after enrollment, got %q", step)
+	}
+
+	// --- Verification: the enrollment code cannot be replayed, recovery codes work once ---
+	if err := mfaService.Verify(reload(), currentTOTP(t, enrollment.Secret)); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Errorf("Expected a replayed TOTP code to be rejected, got %v", err)
+	}
+	if err := mfaService.Verify(reload(), recoveryCodes[0]); err != nil {
+		t.Errorf("Expected a recovery code to be accepted, got %v", err)
+	}
+	if err := mfaService.Verify(reload(), recoveryCodes[0]); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Errorf("Expected a used recovery code to be rejected, got %v", err)
+	}
+
+	// --- Login second factor ---
+	user, err := authService.SecondFactor("mfa-user", "10.3.0.1", func(user *models.User) error {
+		return mfaService.Verify(user, recoveryCodes[1])
+	})
+	if err != nil || user.Username != "mfa-user" {
+		t.Errorf("Expected SecondFactor to succeed, got %v", err)
+	}
+	if _, err := authService.SecondFactor("mfa-user", "10.3.0.1", func(user *models.User) error {
+		return mfaService.Verify(user, "123456")
+	}); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Errorf("Expected ErrInvalidMFACode from SecondFactor, got %v", err)
+	}
+
+	// --- Policy: required MFA cannot be disabled ---
+	if err := mfaService.SetPolicy(models.RoleReceptionist, true); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+	if err := mfaService.Disable(reload(), recoveryCodes[2]); !errors.Is(err, services.ErrMFARequired) {
+		t.Errorf("Expected ErrMFARequired, got %v", err)
+	}
+	if err := mfaService.SetPolicy("nurse", true); !errors.Is(err, services.ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+
+	// --- Admin reset: the role still requires MFA, so the next login asks for enrollment ---
+	if err := mfaService.Reset(reload().ID); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if step, _ := mfaService.LoginStep(reload()); step != services.LoginNeedsEnrollment {
+		t.Errorf("Expected the enrollment step after a reset, got %q", step)
+	}
+	if err := mfaService.SetPolicy(models.RoleReceptionist, false); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+}
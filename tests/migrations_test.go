@@ -0,0 +1,276 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/db"
+	"medical_app/models"
+	"medical_app/utils"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// schemaModels are the models whose tables the migrations create
+var schemaModels = []interface{}{&models.User{}, &models.Patient{}, &models.Appointment{}, &models.DoctorAvailability{}, &models.AvailabilityException{}, &models.EncounterNote{}, &models.AuditLog{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.LoginThrottle{}, &models.RecoveryCode{}, &models.MFAPolicy{}, &models.PasswordHistory{}, &models.Role{}, &models.RolePermission{}, &models.FieldVisibility{}, &models.PatientStatusChange{}, &models.PatientMerge{}, &models.EmergencyContact{}}
+
+// openMigrationDB opens an empty database of its own for a migration test
+func openMigrationDB(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+// TestMigrations_UpDown tests applying, rolling back and re-applying the migrations
+func TestMigrations_UpDown(t *testing.T) {
+	db := openMigrationDB(t, "migrations-updown")
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator failed: %v", err)
+	}
+
+	// --- An empty database is behind ---
+	if err := migrator.CheckSchema(); !errors.Is(err, database.ErrSchemaBehind) {
+		t.Errorf("Expected ErrSchemaBehind on an empty database, got %v", err)
+	}
+
+	// --- Up applies everything ---
+	ran, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(ran) != migrator.Latest() {
+		t.Errorf("Expected %d migrations to run, got %d", migrator.Latest(), len(ran))
+	}
+	if err := migrator.CheckSchema(); err != nil {
+		t.Errorf("Expected the schema to be current, got %v", err)
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied", status.Version)
+		}
+	}
+	if ran, err := migrator.Up(); err != nil || len(ran) != 0 {
+		t.Errorf("Expected a second Up to do nothing, got %d migrations (%v)", len(ran), err)
+	}
+
+	// --- Down rolls back, To goes back up ---
+	if _, err := migrator.Down(migrator.Latest()); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if version, _ := migrator.Version(); version != 0 {
+		t.Errorf("Expected version 0 after rolling back everything, got %d", version)
+	}
+	if db.Migrator().HasTable(&models.Patient{}) {
+		t.Errorf("Expected the patients table to be dropped")
+	}
+	if _, err := migrator.To(migrator.Latest()); err != nil {
+		t.Fatalf("To failed: %v", err)
+	}
+	if _, err := migrator.To(migrator.Latest() + 1); err == nil {
+		t.Errorf("Expected an error for an unknown version")
+	}
+
+	// --- A schema migrated by a newer version is refused ---
+	db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future', CURRENT_TIMESTAMP)", migrator.Latest()+1)
+	if err := migrator.CheckSchema(); !errors.Is(err, database.ErrSchemaAhead) {
+		t.Errorf("Expected ErrSchemaAhead, got %v", err)
+	}
+}
+
+// baselinePatient and baselineUser are the models of the first version, whose tables
+// AutoMigrate created at startup
+type baselinePatient struct {
+	gorm.Model
+	FirstName   string `gorm:"not null"`
+	LastName    string `gorm:"not null"`
+	DOB         string
+	Gender      string
+	Contact     string `gorm:"unique"`
+	Address     string
+	DoctorNotes string `gorm:"type:text"`
+	Status      string `gorm:"default:'active'"`
+}
+
+func (baselinePatient) TableName() string { return "patients" }
+
+type baselineUser struct {
+	gorm.Model
+	Username string `gorm:"unique;not null"`
+	Password string `gorm:"not null"`
+	Role     string `gorm:"not null"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+// TestMigrations_AdoptBaselineSchema tests that a database set up by the first version is
+// recorded at version 1 and migrated to the latest, with its patients' free text fields
+// parsed, and rolled back again
+func TestMigrations_AdoptBaselineSchema(t *testing.T) {
+	db := openMigrationDB(t, "migrations-adopt")
+	if err := db.AutoMigrate(&baselineUser{}, &baselinePatient{}); err != nil {
+		t.Fatalf("AutoMigrate failed: %v", err)
+	}
+	legacy := []baselinePatient{
+		{FirstName: "Legacy", LastName: "One", DOB: "21/07/1969", Contact: "+1 (555) 201-0000", Address: "12 Main St, Apt 4, Springfield, IL 62704, USA", Gender: "M", Status: "active"},
+		{FirstName: "Legacy", LastName: "Two", DOB: "1980-02-29", Contact: "Old@Example.com", Address: "Flat 2", Gender: "Female", Status: "discharged"},
+		{FirstName: "Legacy", LastName: "Three", DOB: "03/04/1990", Contact: "ask at the desk", Gender: "unsure"},
+	}
+	if err := db.Create(&legacy).Error; err != nil {
+		t.Fatalf("Creating legacy patients failed: %v", err)
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator failed: %v", err)
+	}
+	if version, err := migrator.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected the existing schema at version 1, got %d (%v)", version, err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := migrator.CheckSchema(); err != nil {
+		t.Errorf("Expected the schema to be current, got %v", err)
+	}
+	for _, model := range schemaModels {
+		if !db.Migrator().HasTable(model) {
+			t.Errorf("Expected the table of %T to be created", model)
+		}
+	}
+	for _, column := range []string{"dob", "contact", "address"} {
+		if db.Migrator().HasColumn(&models.Patient{}, column) {
+			t.Errorf("Expected the legacy column %s to be dropped", column)
+		}
+	}
+	if !db.Migrator().HasIndex(&models.Patient{}, "idx_patients_mrn") {
+		t.Errorf("Expected the medical record number index")
+	}
+
+	// --- The free text fields are parsed ---
+	var patients []models.Patient
+	db.Order("id").Find(&patients)
+	if len(patients) != 3 {
+		t.Fatalf("Expected 3 patients, got %d", len(patients))
+	}
+	first, second, third := patients[0], patients[1], patients[2]
+	want := models.Address{Line1: "12 Main St", Line2: "Apt 4", City: "Springfield", State: "IL", PostalCode: "62704", Country: "USA"}
+	if first.DOB.String() != "1969-07-21" || first.Phone != "+15552010000" || first.Address != want || first.Gender != models.GenderMale {
+		t.Errorf("Unexpected first migrated patient: %+v", first)
+	}
+	if second.DOB.String() != "1980-02-29" || second.Email != "old@example.com" || second.Address.Line1 != "Flat 2" || second.Gender != models.GenderFemale {
+		t.Errorf("Unexpected second migrated patient: %+v", second)
+	}
+	if third.DOB != nil || third.Phone != "" || third.Gender != models.GenderUnknown {
+		t.Errorf("Unexpected third migrated patient: %+v", third)
+	}
+	for _, p := range patients {
+		if !utils.ValidMRN(p.MRN) || p.Version != 1 {
+			t.Errorf("Expected patient %d to get a medical record number, got %q", p.ID, p.MRN)
+		}
+	}
+	// "active" is from before the status workflow, "discharged" is part of it
+	if first.Status != models.PatientRegistered || second.Status != models.PatientDischarged {
+		t.Errorf("Expected the statuses registered and discharged, got %q and %q", first.Status, second.Status)
+	}
+
+	// --- An ambiguous date and a contact that is neither phone nor email are kept ---
+	var kept []struct{ Field, Value string }
+	db.Table("patient_legacy_values").Where("patient_id = ?", third.ID).Order("field").Find(&kept)
+	if len(kept) != 2 || kept[0].Field != "contact" || kept[0].Value != "ask at the desk" || kept[1].Field != "dob" || kept[1].Value != "03/04/1990" {
+		t.Errorf("Expected the unparsed values to be kept, got %+v", kept)
+	}
+
+	// --- Rolling back to the first version restores the free text fields ---
+	if _, err := migrator.To(1); err != nil {
+		t.Fatalf("To(1) failed: %v", err)
+	}
+	var restored []baselinePatient
+	db.Order("id").Find(&restored)
+	if len(restored) != 3 || restored[0].DOB != "1969-07-21" || restored[0].Contact != "+15552010000" || restored[0].Address != "12 Main St, Apt 4, Springfield, IL 62704, USA" ||
+		restored[1].Contact != "old@example.com" || restored[2].DOB != "03/04/1990" || restored[2].Contact != "ask at the desk" {
+		t.Errorf("Unexpected patients after rolling back: %+v", restored)
+	}
+	if db.Migrator().HasColumn(&models.Patient{}, "mrn") || db.Migrator().HasTable(&models.Appointment{}) {
+		t.Errorf("Expected the schema of the first version after rolling back")
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up after rolling back failed: %v", err)
+	}
+}
+
+// TestMigrations_RefuseUnknownSchema tests that a database without a schema version
+// whose tables are not those of the first version is not adopted
+func TestMigrations_RefuseUnknownSchema(t *testing.T) {
+	db := openMigrationDB(t, "migrations-unknown")
+	if err := db.AutoMigrate(schemaModels...); err != nil {
+		t.Fatalf("AutoMigrate failed: %v", err)
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator failed: %v", err)
+	}
+	if _, err := migrator.Version(); !errors.Is(err, database.ErrUnknownSchema) {
+		t.Errorf("Expected ErrUnknownSchema from Version, got %v", err)
+	}
+	if _, err := migrator.Up(); !errors.Is(err, database.ErrUnknownSchema) {
+		t.Errorf("Expected ErrUnknownSchema from Up, got %v", err)
+	}
+	if db.Migrator().HasTable("schema_migrations") {
+		t.Errorf("Expected no schema version to be recorded")
+	}
+}
+
+// TestMigrations_MatchModels tests that the migrated schema has a column for every model
+// field, so a model change without a migration is caught
+func TestMigrations_MatchModels(t *testing.T) {
+	for _, model := range schemaModels {
+		stmt := &gorm.Statement{DB: testDB}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("failed to parse %T: %v", model, err)
+		}
+		if !testDB.Migrator().HasTable(stmt.Schema.Table) {
+			t.Errorf("Table %s of %T is missing", stmt.Schema.Table, model)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !testDB.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("Column %s.%s of %T is missing", stmt.Schema.Table, field.DBName, model)
+			}
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			if !testDB.Migrator().HasIndex(model, index.Name) {
+				t.Errorf("Index %s of %T is missing", index.Name, model)
+			}
+		}
+	}
+
+	// Both dialects have the same migrations
+	postgres, err := database.LoadMigrations("postgres")
+	if err != nil {
+		t.Fatalf("LoadMigrations(postgres) failed: %v", err)
+	}
+	sqlite, err := database.LoadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("LoadMigrations(sqlite) failed: %v", err)
+	}
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("Expected as many postgres as sqlite migrations, got %d and %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Name != sqlite[i].Name {
+			t.Errorf("Migration %d is %s for postgres but %s for sqlite", i+1, postgres[i].Name, sqlite[i].Name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"fmt"
+	"medical_app/models"
+	"medical_app/services"
+	"net/http"
+	"testing"
+)
+
+// createAPIPatient registers a patient for a controller test and deletes it afterwards
+func createAPIPatient(t *testing.T, patient *models.Patient) string {
+	t.Helper()
+	if err := services.NewPatientService(testDB).CreatePatient(patient); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+	t.Cleanup(func() { testDB.Unscoped().Delete(patient) })
+	return fmt.Sprintf("/api/patients/%d", patient.ID)
+}
+
+// TestPatientController_FieldPermissions tests that a patch with fields the caller's role
+// may not change is refused as a whole, on PATCH and on the receptionist PUT
+func TestPatientController_FieldPermissions(t *testing.T) {
+	router := newTestRouter(t)
+	_, token := loginAs(t, "patch-api-receptionist", models.RoleReceptionist)
+	patient := &models.Patient{FirstName: "Fay", LastName: "Fields", Email: "patch-api@example.com", Address: models.Address{Line1: "1 Ward Road", City: "Bath"}}
+	path := createAPIPatient(t, patient)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPatch, path},
+		{http.MethodPut, fmt.Sprintf("/api/receptionist/patients/%d", patient.ID)},
+	} {
+		w := apiRequest(router, route.method, route.path, token, `{"last_name": "Changed", "doctor_notes": "Allergic"}`, "If-Match", `"1"`)
+		problem := problemOf(t, w, http.StatusForbidden, "forbidden_fields")
+		fields, _ := problem["fields"].(map[string]interface{})
+		if _, ok := fields["doctor_notes"]; !ok || len(fields) != 1 {
+			t.Errorf("%s: expected only doctor_notes to be reported, got %v", route.method, problem["fields"])
+		}
+	}
+	stored, _ := services.NewPatientService(testDB).GetPatientByID(patient.ID)
+	if stored.LastName != "Fields" || stored.DoctorNotes != "" || stored.Version != 1 {
+		t.Errorf("Expected a refused patch to change nothing, got %+v", stored)
+	}
+
+	// Allowed fields are set and fields sent as null are cleared
+	w := apiRequest(router, http.MethodPatch, path, token, `{"last_name": "Changed", "address": {"city": null}}`, "If-Match", `"1"`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	stored, _ = services.NewPatientService(testDB).GetPatientByID(patient.ID)
+	if stored.LastName != "Changed" || stored.Address.City != "" || stored.Address.Line1 != "1 Ward Road" {
+		t.Errorf("Unexpected patched patient: %+v", stored)
+	}
+}
+
+// TestPatientController_IfMatch tests that patient changes need the ETag of the version
+// the client saw and that a stale one is answered with the current patient
+func TestPatientController_IfMatch(t *testing.T) {
+	router := newTestRouter(t)
+	_, token := loginAs(t, "etag-api-receptionist", models.RoleReceptionist)
+	_, doctorToken := loginAs(t, "etag-api-doctor", models.RoleDoctor)
+	patient := &models.Patient{FirstName: "Eve", LastName: "Etag", Email: "etag-api@example.com"}
+	path := createAPIPatient(t, patient)
+
+	w := apiRequest(router, http.MethodGet, path, token, "")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"1"` {
+		t.Fatalf(`Expected 200 with ETag "1", got %d %q`, w.Code, w.Header().Get("ETag"))
+	}
+
+	problemOf(t, apiRequest(router, http.MethodPatch, path, token, `{"last_name": "First"}`), http.StatusPreconditionRequired, "if_match_required")
+	problemOf(t, apiRequest(router, http.MethodPatch, path, token, `{"last_name": "First"}`, "If-Match", "latest"), http.StatusBadRequest, "invalid_if_match")
+	notesPath := fmt.Sprintf("/api/doctor/patients/%d/notes", patient.ID)
+	problemOf(t, apiRequest(router, http.MethodPut, notesPath, doctorToken, `{"doctor_notes": "Stable"}`), http.StatusPreconditionRequired, "if_match_required")
+
+	w = apiRequest(router, http.MethodPatch, path, token, `{"last_name": "First"}`, "If-Match", `"1"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf(`Expected 200 with ETag "2", got %d %q: %s`, w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+
+	// A second client still holding version 1 gets the current patient to merge with
+	w = apiRequest(router, http.MethodPatch, path, token, `{"last_name": "Second"}`, "If-Match", `"1"`)
+	problem := problemOf(t, w, http.StatusPreconditionFailed, services.ErrPatientVersionConflict.Code)
+	if w.Header().Get("ETag") != `"2"` {
+		t.Errorf(`Expected the conflict to carry ETag "2", got %q`, w.Header().Get("ETag"))
+	}
+	current, _ := problem["patient"].(map[string]interface{})
+	if current["LastName"] != "First" {
+		t.Errorf("Expected the conflict to carry the current patient, got %v", problem["patient"])
+	}
+}
@@ -1,12 +1,17 @@
 package tests
 
 import (
+	"errors"
 	"medical_app/models"
 	"medical_app/services"
+	"medical_app/utils"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
-//  TestPatientService_CRUD tests patient CRUD operations
+// TestPatientService_CRUD tests patient CRUD operations
 func TestPatientService_CRUD(t *testing.T) {
 	patientService := services.NewPatientService(testDB)
 
@@ -14,8 +19,7 @@ func TestPatientService_CRUD(t *testing.T) {
 	patient := &models.Patient{
 		FirstName: "John",
 		LastName:  "Doe",
-		Contact:   "1234567890",
-		Status:    "active",
+		Phone:     "123-456-7890",
 	}
 	err := patientService.CreatePatient(patient)
 	if err != nil {
@@ -30,7 +34,7 @@ func TestPatientService_CRUD(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetPatientByID failed: %v", err)
 	}
-	if fetchedPatient.FirstName != "John" || fetchedPatient.Contact != "1234567890" {
+	if fetchedPatient.FirstName != "John" || fetchedPatient.Phone != "1234567890" || !utils.ValidMRN(fetchedPatient.MRN) {
 		t.Errorf("Fetched patient details mismatch")
 	}
 
@@ -44,6 +48,7 @@ func TestPatientService_CRUD(t *testing.T) {
 	}
 
 	// --- Update Patient ---
+	// Status is not changed by UpdatePatient, only through the status workflow
 	fetchedPatient.LastName = "Smith"
 	fetchedPatient.Status = "discharged"
 	err = patientService.UpdatePatient(fetchedPatient)
@@ -51,21 +56,21 @@ func TestPatientService_CRUD(t *testing.T) {
 		t.Fatalf("UpdatePatient failed: %v", err)
 	}
 	updatedPatient, _ := patientService.GetPatientByID(patient.ID)
-	if updatedPatient.LastName != "Smith" || updatedPatient.Status != "discharged" {
-		t.Errorf("Patient update failed. Expected Smith/discharged, got %s/%s", updatedPatient.LastName, updatedPatient.Status)
+	if updatedPatient.LastName != "Smith" || updatedPatient.Status != "registered" {
+		t.Errorf("Patient update failed. Expected Smith/registered, got %s/%s", updatedPatient.LastName, updatedPatient.Status)
 	}
 
 	// --- Update Patient Doctor Notes ---
-	err = patientService.UpdatePatientDoctorNotes(patient.ID, "Patient is recovering well.", "active")
+	doctor := services.StatusChange{Status: "under_treatment", ChangedBy: "doc", Permissions: []string{models.PermPatientClinical}}
+	_, _, err = patientService.UpdatePatientDoctorNotes(patient.ID, updatedPatient.Version, "Patient is recovering well.", doctor)
 	if err != nil {
 		t.Fatalf("UpdatePatientDoctorNotes failed: %v", err)
 	}
 	updatedPatientNotes, _ := patientService.GetPatientByID(patient.ID)
-	if updatedPatientNotes.DoctorNotes != "Patient is recovering well." || updatedPatientNotes.Status != "active" {
-		t.Errorf("Doctor notes update failed. Expected 'Patient is recovering well.'/active, got '%s'/%s", updatedPatientNotes.DoctorNotes, updatedPatientNotes.Status)
+	if updatedPatientNotes.DoctorNotes != "Patient is recovering well." || updatedPatientNotes.Status != "under_treatment" {
+		t.Errorf("Doctor notes update failed. Expected 'Patient is recovering well.'/under_treatment, got '%s'/%s", updatedPatientNotes.DoctorNotes, updatedPatientNotes.Status)
 	}
 
-
 	// --- Delete Patient ---
 	err = patientService.DeletePatient(patient.ID)
 	if err != nil {
@@ -75,4 +80,698 @@ func TestPatientService_CRUD(t *testing.T) {
 	if err == nil {
 		t.Errorf("Expected patient to be deleted, but it was found")
 	}
-}
\ No newline at end of file
+}
+
+// TestPatientService_ListPatients tests filtering, sorting and paging of the patient listing
+func TestPatientService_ListPatients(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	seed := []models.Patient{
+		{FirstName: "Ann", LastName: "Zimmer", DOB: date("1980-05-01"), Gender: "female", Email: "list-1@example.com", Status: "list-test"},
+		{FirstName: "Bob", LastName: "Adams", DOB: date("1990-01-15"), Gender: "male", Email: "list-2@example.com", Status: "list-test"},
+		{FirstName: "Cid", LastName: "Moore", DOB: date("2000-12-31"), Gender: "male", Email: "list-3@example.com", Status: "list-test"},
+	}
+	for i := range seed {
+		if err := patientService.CreatePatient(&seed[i]); err != nil {
+			t.Fatalf("CreatePatient failed: %v", err)
+		}
+	}
+
+	// --- Sorting and paging ---
+	page, err := patientService.ListPatients(services.PatientListOptions{Status: "list-test", Sort: "last_name", PageSize: 2})
+	if err != nil {
+		t.Fatalf("ListPatients failed: %v", err)
+	}
+	if page.Total != 3 || page.TotalPages() != 2 || !page.HasNext() {
+		t.Errorf("Expected 3 patients over 2 pages, got total %d over %d pages", page.Total, page.TotalPages())
+	}
+	if len(page.Patients) != 2 || page.Patients[0].LastName != "Adams" || page.Patients[1].LastName != "Moore" {
+		t.Errorf("Unexpected first page: %+v", page.Patients)
+	}
+
+	page, err = patientService.ListPatients(services.PatientListOptions{Status: "list-test", Sort: "last_name", Page: 2, PageSize: 2})
+	if err != nil {
+		t.Fatalf("ListPatients failed: %v", err)
+	}
+	if len(page.Patients) != 1 || page.Patients[0].LastName != "Zimmer" || page.HasNext() {
+		t.Errorf("Unexpected last page: %+v", page.Patients)
+	}
+
+	// --- Filters ---
+	page, err = patientService.ListPatients(services.PatientListOptions{Status: "list-test", Gender: "male", DOBFrom: "1995-01-01"})
+	if err != nil {
+		t.Fatalf("ListPatients failed: %v", err)
+	}
+	if page.Total != 1 || page.Patients[0].FirstName != "Cid" {
+		t.Errorf("Expected only Cid to match the gender/DOB filter, got %+v", page.Patients)
+	}
+
+	// --- Invalid sort ---
+	if _, err := patientService.ListPatients(services.PatientListOptions{Sort: "contact"}); err != services.ErrInvalidPatientSort {
+		t.Errorf("Expected ErrInvalidPatientSort, got %v", err)
+	}
+}
+
+// TestPatientService_SearchPatients tests prefix, phone and typo-tolerant patient search
+func TestPatientService_SearchPatients(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	seed := []models.Patient{
+		{FirstName: "Margaret", LastName: "Thornberry", Phone: "+1 (555) 201-7788", Address: models.Address{Line1: "12 Elm Street"}, Status: "active"},
+		{FirstName: "Marcus", LastName: "Thornton", Email: "marcus@example.com", Address: models.Address{Line1: "9 Oak Avenue", City: "Leeds"}, Status: "active"},
+	}
+	for i := range seed {
+		if err := patientService.CreatePatient(&seed[i]); err != nil {
+			t.Fatalf("CreatePatient failed: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name  string
+		term  string
+		first string
+	}{
+		{"prefix and case-insensitive", "THORNB", "Margaret"},
+		{"phone digits", "2017788", "Margaret"},
+		{"typo", "Thronton", "Marcus"},
+		{"typo at the start", "Nargaret", "Margaret"},
+		{"several words", "marcus oak", "Marcus"},
+		{"city", "leeds", "Marcus"},
+		{"medical record number", seed[1].MRN, "Marcus"},
+	}
+	for _, tc := range cases {
+		results, err := patientService.SearchPatients(tc.term, 5)
+		if err != nil {
+			t.Fatalf("%s: SearchPatients failed: %v", tc.name, err)
+		}
+		if len(results) == 0 || results[0].Patient.FirstName != tc.first {
+			t.Errorf("%s: expected %s ranked first, got %+v", tc.name, tc.first, results)
+		}
+	}
+
+	results, err := patientService.SearchPatients("zzzzzz", 5)
+	if err != nil {
+		t.Fatalf("SearchPatients failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results for unmatched term, got %d", len(results))
+	}
+}
+
+// TestPatientService_PatchPatient tests merge patch semantics and field validation
+func TestPatientService_PatchPatient(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	patient := &models.Patient{FirstName: "Patch", LastName: "Target", Email: "patch-1@example.com", Address: models.Address{Line1: "1 Old Road", City: "York"}, Status: "active"}
+	if err := patientService.CreatePatient(patient); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+	defer testDB.Unscoped().Delete(patient)
+
+	// Set one field, clear another with null, leave the rest alone
+	patch, err := services.ParsePatientPatch([]byte(`{"last_name": " Smith ", "address": {"line1": null}}`))
+	if err != nil {
+		t.Fatalf("ParsePatientPatch failed: %v", err)
+	}
+	before, after, err := patientService.PatchPatient(patient.ID, 0, patch)
+	if err != nil {
+		t.Fatalf("PatchPatient failed: %v", err)
+	}
+	if before.LastName != "Target" || before.Address.Line1 != "1 Old Road" {
+		t.Errorf("Expected the record before the patch, got %s/%s", before.LastName, before.Address.Line1)
+	}
+	if after.LastName != "Smith" || after.Address != (models.Address{City: "York"}) || after.FirstName != "Patch" || after.Email != "patch-1@example.com" {
+		t.Errorf("Unexpected patched patient: %+v", after)
+	}
+
+	// Values are normalized
+	patch, _ = services.ParsePatientPatch([]byte(`{"phone": "+44 (0)20 7946-0000", "email": null, "gender": "Female", "dob": "1990-03-04"}`))
+	if _, after, err = patientService.PatchPatient(patient.ID, 0, patch); err != nil {
+		t.Fatalf("PatchPatient failed: %v", err)
+	}
+	if after.Phone != "+4402079460000" || after.Email != "" || after.Gender != "female" || after.DOB.String() != "1990-03-04" {
+		t.Errorf("Unexpected normalized patient: %+v", after)
+	}
+
+	// Unknown fields and non-string values are rejected while parsing
+	_, err = services.ParsePatientPatch([]byte(`{"ID": 5, "gender": 1, "contact": "x", "address": {"street": "x"}, "emergency_contacts": []}`))
+	fieldErrors, ok := err.(services.FieldErrors)
+	if !ok || fieldErrors["ID"] == "" || fieldErrors["gender"] == "" || fieldErrors["contact"] == "" || fieldErrors["address.street"] == "" || fieldErrors["emergency_contacts"] == "" {
+		t.Errorf("Expected field errors for ID, gender, contact, address.street and emergency_contacts, got %v", err)
+	}
+	if domainErr := services.AsError(err); !errors.Is(err, services.ErrInvalidFields) || domainErr == nil || domainErr.Kind != services.KindValidation {
+		t.Errorf("Expected field errors to be a validation error, got %+v", domainErr)
+	}
+	if _, err := services.ParsePatientPatch([]byte(`[1, 2]`)); !errors.Is(err, services.ErrInvalidPatch) {
+		t.Errorf("Expected ErrInvalidPatch for a non-object body, got %v", err)
+	}
+	for _, body := range []string{`{}`, `{"address": {}}`} {
+		if _, err := services.ParsePatientPatch([]byte(body)); !errors.Is(err, services.ErrEmptyPatch) {
+			t.Errorf("Expected ErrEmptyPatch for %s, got %v", body, err)
+		}
+	}
+
+	// Required fields cannot be cleared and values are validated
+	invalid := services.PatientPatch{
+		"first_name":   nil,
+		"dob":          strPtr("03/04/1990"),
+		"email":        strPtr("not-an-email"),
+		"gender":       strPtr("F"),
+		"address.city": strPtr("Leeds"),
+	}
+	_, _, err = patientService.PatchPatient(patient.ID, 0, invalid)
+	fieldErrors, ok = err.(services.FieldErrors)
+	if !ok || len(fieldErrors) != 4 {
+		t.Errorf("Expected 4 field errors, got %v", err)
+	}
+	future := time.Now().AddDate(0, 0, 2).Format(models.DateLayout)
+	if _, _, err := patientService.PatchPatient(patient.ID, 0, services.PatientPatch{"dob": &future}); err == nil {
+		t.Error("Expected a date of birth in the future to be rejected")
+	}
+	// A patient keeps a phone number or an email address
+	if _, _, err := patientService.PatchPatient(patient.ID, 0, services.PatientPatch{"phone": nil}); err == nil {
+		t.Error("Expected clearing the last contact to be rejected")
+	}
+	// Status only changes through the status workflow
+	_, err = services.ParsePatientPatch([]byte(`{"status": "discharged"}`))
+	if fieldErrors, ok := err.(services.FieldErrors); !ok || fieldErrors["status"] == "" {
+		t.Errorf("Expected a field error for status, got %v", err)
+	}
+
+	if _, _, err := patientService.PatchPatient(999999, 0, services.PatientPatch{"gender": strPtr("female")}); !errors.Is(err, services.ErrPatientNotFound) {
+		t.Errorf("Expected ErrPatientNotFound, got %v", err)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func date(s string) *models.Date {
+	d, err := models.ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return &d
+}
+
+// TestPatientService_Versioning tests that stale updates are rejected
+func TestPatientService_Versioning(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	patient := &models.Patient{FirstName: "Version", LastName: "Check", Email: "version-1@example.com", Status: "active"}
+	if err := patientService.CreatePatient(patient); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+	defer testDB.Unscoped().Delete(patient)
+	if patient.Version != 1 {
+		t.Fatalf("Expected a new patient to have version 1, got %d", patient.Version)
+	}
+
+	// Two clients read version 1; the first write wins
+	_, after, err := patientService.PatchPatient(patient.ID, 1, services.PatientPatch{"gender": strPtr("female")})
+	if err != nil {
+		t.Fatalf("PatchPatient failed: %v", err)
+	}
+	if after.Version != 2 {
+		t.Errorf("Expected version 2 after the patch, got %d", after.Version)
+	}
+	if _, _, err := patientService.PatchPatient(patient.ID, 1, services.PatientPatch{"gender": strPtr("male")}); !errors.Is(err, services.ErrPatientVersionConflict) {
+		t.Errorf("Expected ErrPatientVersionConflict for a stale patch, got %v", err)
+	}
+	if _, _, err := patientService.UpdatePatientDoctorNotes(patient.ID, 1, "Stale note", services.StatusChange{}); !errors.Is(err, services.ErrPatientVersionConflict) {
+		t.Errorf("Expected ErrPatientVersionConflict for stale doctor notes, got %v", err)
+	}
+
+	_, after, err = patientService.UpdatePatientDoctorNotes(patient.ID, 2, "Fresh note", services.StatusChange{})
+	if err != nil {
+		t.Fatalf("UpdatePatientDoctorNotes failed: %v", err)
+	}
+	if after.Version != 3 || after.DoctorNotes != "Fresh note" || after.Gender != "female" {
+		t.Errorf("Unexpected patient after doctor notes update: %+v", after)
+	}
+
+	// Save-style updates are checked against the version they were read at
+	stale := *patient
+	stale.Address.Line1 = "Stale Street"
+	if err := patientService.UpdatePatient(&stale); !errors.Is(err, services.ErrPatientVersionConflict) {
+		t.Errorf("Expected ErrPatientVersionConflict for a stale UpdatePatient, got %v", err)
+	}
+	if stale.Version != 1 {
+		t.Errorf("Expected a failed update to keep the version, got %d", stale.Version)
+	}
+	after.Address.Line1 = "Fresh Street"
+	if err := patientService.UpdatePatient(after); err != nil {
+		t.Fatalf("UpdatePatient failed: %v", err)
+	}
+	stored, _ := patientService.GetPatientByID(patient.ID)
+	if stored.Version != 4 || stored.Address.Line1 != "Fresh Street" {
+		t.Errorf("Expected version 4 with the new address, got %d/%s", stored.Version, stored.Address.Line1)
+	}
+}
+
+// TestPatientService_StatusWorkflow tests allowed and rejected status transitions and the history
+func TestPatientService_StatusWorkflow(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	patient := &models.Patient{FirstName: "Wanda", LastName: "Flow", Email: "workflow-1@example.com"}
+	if err := patientService.CreatePatient(patient); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+	defer testDB.Unscoped().Delete(patient)
+	defer testDB.Where("patient_id = ?", patient.ID).Delete(&models.PatientStatusChange{})
+	if patient.Status != models.PatientRegistered {
+		t.Fatalf("Expected a new patient to be registered, got %s", patient.Status)
+	}
+
+	frontDesk := []string{models.PermPatientRead, models.PermPatientWrite}
+	clinician := []string{models.PermPatientRead, models.PermPatientClinical}
+	change := func(status string, perms []string, reason, summary string) (*models.Patient, error) {
+		_, after, err := patientService.ChangePatientStatus(patient.ID, 0, services.StatusChange{
+			Status: status, Reason: reason, DischargeSummary: summary, ChangedBy: "tester", Permissions: perms,
+		})
+		return after, err
+	}
+
+	// Front desk admits, the doctor starts treatment
+	if _, err := change(models.PatientAdmitted, frontDesk, "", ""); err != nil {
+		t.Fatalf("Admitting failed: %v", err)
+	}
+	if _, err := change(models.PatientUnderTreatment, frontDesk, "", ""); !errors.Is(err, services.ErrPatientTransitionForbidden) {
+		t.Errorf("Expected the front desk to be refused, got %v", err)
+	}
+	if _, err := change(models.PatientUnderTreatment, clinician, "", ""); err != nil {
+		t.Fatalf("Starting treatment failed: %v", err)
+	}
+
+	// No shortcut from treatment to archived, and the error says what is allowed
+	_, err := change(models.PatientArchived, frontDesk, "", "")
+	var transitionErr *services.PatientTransitionError
+	if !errors.As(err, &transitionErr) || !errors.Is(err, services.ErrInvalidPatientTransition) {
+		t.Fatalf("Expected a rejected transition, got %v", err)
+	}
+	if transitionErr.From != models.PatientUnderTreatment || len(transitionErr.Allowed) != 0 {
+		t.Errorf("Unexpected transition error: %+v", transitionErr)
+	}
+	if _, err := change("on_leave", clinician, "", ""); err == nil {
+		t.Errorf("Expected an unknown status to fail")
+	}
+
+	// Discharging needs a summary
+	_, err = change(models.PatientDischarged, clinician, "", "")
+	if fieldErrors, ok := err.(services.FieldErrors); !ok || fieldErrors["discharge_summary"] == "" {
+		t.Errorf("Expected a discharge_summary field error, got %v", err)
+	}
+	after, err := change(models.PatientDischarged, clinician, "", "Recovered, follow up in 2 weeks")
+	if err != nil {
+		t.Fatalf("Discharging failed: %v", err)
+	}
+	if after.Status != models.PatientDischarged || after.Version != 4 {
+		t.Errorf("Expected discharged at version 4, got %s at %d", after.Status, after.Version)
+	}
+
+	allowed := services.AllowedPatientTransitions(models.PatientDischarged, frontDesk)
+	if len(allowed) != 2 {
+		t.Errorf("Expected the front desk to readmit or archive a discharged patient, got %v", allowed)
+	}
+
+	_, history, err := patientService.StatusHistory(patient.ID)
+	if err != nil {
+		t.Fatalf("StatusHistory failed: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 history entries, got %d", len(history))
+	}
+	if history[0].FromStatus != models.PatientRegistered || history[2].ToStatus != models.PatientDischarged ||
+		history[2].DischargeSummary == "" || history[2].ChangedBy != "tester" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+	if _, _, err := patientService.StatusHistory(999999); !errors.Is(err, services.ErrPatientNotFound) {
+		t.Errorf("Expected ErrPatientNotFound, got %v", err)
+	}
+}
+
+// TestPatientService_DeletedPatients tests listing, restoring and purging soft-deleted patients
+func TestPatientService_DeletedPatients(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	first := &models.Patient{FirstName: "Del", LastName: "First", Email: "trash-1@example.com"}
+	if err := patientService.CreatePatient(first); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+	defer testDB.Unscoped().Delete(&models.Patient{}, first.ID)
+	if err := patientService.DeletePatient(first.ID); err != nil {
+		t.Fatalf("DeletePatient failed: %v", err)
+	}
+
+	second := &models.Patient{
+		FirstName: "Del", LastName: "Second", Email: "trash-1@example.com",
+		EmergencyContacts: []models.EmergencyContact{{Name: "Kin", Phone: "555 0101 99"}},
+	}
+	if err := patientService.CreatePatient(second); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+	defer testDB.Unscoped().Delete(&models.Patient{}, second.ID)
+
+	page, err := patientService.ListDeletedPatients(1, services.MaxPatientPageSize)
+	if err != nil {
+		t.Fatalf("ListDeletedPatients failed: %v", err)
+	}
+	found := false
+	for _, p := range page.Patients {
+		found = found || p.ID == first.ID
+		if p.ID == second.ID {
+			t.Errorf("Expected only deleted patients to be listed")
+		}
+	}
+	if !found {
+		t.Errorf("Expected the deleted patient to be listed")
+	}
+
+	if err := patientService.DeletePatient(second.ID); err != nil {
+		t.Fatalf("DeletePatient failed: %v", err)
+	}
+	restored, err := patientService.RestorePatient(first.ID)
+	if err != nil {
+		t.Fatalf("RestorePatient failed: %v", err)
+	}
+	if restored.DeletedAt.Valid || restored.Version != 2 {
+		t.Errorf("Expected a restored patient at version 2, got %+v", restored)
+	}
+	if _, err := patientService.RestorePatient(first.ID); !errors.Is(err, services.ErrPatientNotDeleted) {
+		t.Errorf("Expected ErrPatientNotDeleted, got %v", err)
+	}
+	if err := patientService.PurgePatient(first.ID); !errors.Is(err, services.ErrPatientNotDeleted) {
+		t.Errorf("Expected purging an active patient to fail, got %v", err)
+	}
+
+	// Purging removes the patient and their records
+	testDB.Create(&models.PatientStatusChange{PatientID: second.ID, FromStatus: "registered", ToStatus: "admitted", ChangedBy: "tester"})
+	if err := patientService.PurgePatient(second.ID); err != nil {
+		t.Fatalf("PurgePatient failed: %v", err)
+	}
+	var patients, history, contacts int64
+	testDB.Unscoped().Model(&models.Patient{}).Where("id = ?", second.ID).Count(&patients)
+	testDB.Model(&models.PatientStatusChange{}).Where("patient_id = ?", second.ID).Count(&history)
+	testDB.Model(&models.EmergencyContact{}).Where("patient_id = ?", second.ID).Count(&contacts)
+	if patients != 0 || history != 0 || contacts != 0 {
+		t.Errorf("Expected the purged patient, their history and emergency contacts to be gone")
+	}
+	if err := patientService.PurgePatient(second.ID); !errors.Is(err, services.ErrPatientNotFound) {
+		t.Errorf("Expected ErrPatientNotFound, got %v", err)
+	}
+
+	// Only deletions older than the retention period are purged by the job
+	if err := patientService.DeletePatient(first.ID); err != nil {
+		t.Fatalf("DeletePatient failed: %v", err)
+	}
+	purged, err := patientService.PurgeExpiredPatients(24 * time.Hour)
+	if err != nil || slices.Contains(purged, first.ID) {
+		t.Errorf("Expected a fresh deletion to be kept, got %v, %v", purged, err)
+	}
+	testDB.Unscoped().Model(&models.Patient{}).Where("id = ?", first.ID).Update("deleted_at", time.Now().Add(-48*time.Hour))
+	purged, err = patientService.PurgeExpiredPatients(24 * time.Hour)
+	if err != nil || !slices.Contains(purged, first.ID) {
+		t.Errorf("Expected the expired deletion to be purged, got %v, %v", purged, err)
+	}
+}
+
+// TestPatientService_FindDuplicates tests duplicate scoring on name, DOB, phone and email
+func TestPatientService_FindDuplicates(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	existing := []*models.Patient{
+		{FirstName: "Katherine", LastName: "Oduya", DOB: date("1984-03-12"), Phone: "+44 7700 900123"},
+		{FirstName: "Katherine", LastName: "Oduya", DOB: date("1991-07-30"), Email: "dup-other@example.com"},
+	}
+	for _, p := range existing {
+		if err := patientService.CreatePatient(p); err != nil {
+			t.Fatalf("CreatePatient failed: %v", err)
+		}
+		defer testDB.Unscoped().Delete(p)
+	}
+
+	cases := []struct {
+		name      string
+		candidate models.Patient
+		want      []uint
+	}{
+		{"same person, new phone", models.Patient{FirstName: "Katherine", LastName: "Oduya", DOB: date("1984-03-12"), Phone: "555-0000"}, []uint{existing[0].ID}},
+		{"typo in the name", models.Patient{FirstName: "Katherin", LastName: "Oduyа", DOB: date("1984-03-12")}, []uint{existing[0].ID}},
+		{"names swapped, day and month swapped", models.Patient{FirstName: "Oduya", LastName: "Katherine", DOB: date("1984-12-03")}, []uint{existing[0].ID}},
+		{"same phone formatted differently", models.Patient{FirstName: "Kate", LastName: "Oduya", DOB: date("1984-03-12"), Phone: "07700900123"}, []uint{existing[0].ID}},
+		{"same name, different birthday", models.Patient{FirstName: "Katherine", LastName: "Oduya", DOB: date("1970-01-01"), Phone: "555-1111"}, nil},
+		{"someone else", models.Patient{FirstName: "Peter", LastName: "Nguyen", DOB: date("1984-03-12")}, nil},
+	}
+	for _, tc := range cases {
+		matches, err := patientService.FindDuplicates(&tc.candidate)
+		if err != nil {
+			t.Fatalf("%s: FindDuplicates failed: %v", tc.name, err)
+		}
+		got := []uint{}
+		for _, m := range matches {
+			if m.Patient.ID == existing[0].ID || m.Patient.ID == existing[1].ID {
+				got = append(got, m.Patient.ID)
+			}
+		}
+		if !slices.Equal(got, tc.want) && !(len(got) == 0 && len(tc.want) == 0) {
+			t.Errorf("%s: expected matches %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	// An existing patient is not reported as its own duplicate
+	matches, err := patientService.FindDuplicates(existing[0])
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+	for _, m := range matches {
+		if m.Patient.ID == existing[0].ID {
+			t.Errorf("Expected the patient itself to be skipped")
+		}
+	}
+}
+
+// TestPatientService_MergePatients tests merging two records and undoing the merge
+func TestPatientService_MergePatients(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	target := &models.Patient{FirstName: "Mona", LastName: "Merge", DOB: date("1975-05-05"), Email: "merge-1@example.com", DoctorNotes: "Asthma"}
+	source := &models.Patient{
+		FirstName: "Mona", LastName: "Merge", Email: "merge-2@example.com", Phone: "5550123456", DoctorNotes: "Penicillin allergy",
+		Address:           models.Address{Line1: "4 New Street", City: "Hull"},
+		EmergencyContacts: []models.EmergencyContact{{Name: "Kin", Email: "kin@example.com"}},
+	}
+	for _, p := range []*models.Patient{target, source} {
+		if err := patientService.CreatePatient(p); err != nil {
+			t.Fatalf("CreatePatient failed: %v", err)
+		}
+		defer testDB.Unscoped().Delete(&models.Patient{}, p.ID)
+	}
+	history := &models.PatientStatusChange{PatientID: source.ID, FromStatus: "registered", ToStatus: "admitted", ChangedBy: "tester"}
+	testDB.Create(history)
+	defer testDB.Delete(history)
+
+	if _, err := patientService.MergePatients(target.ID, 0, target.ID, services.MergeOptions{}); !errors.Is(err, services.ErrMergeSamePatient) {
+		t.Errorf("Expected ErrMergeSamePatient, got %v", err)
+	}
+	if _, err := patientService.MergePatients(target.ID, 7, source.ID, services.MergeOptions{}); !errors.Is(err, services.ErrPatientVersionConflict) {
+		t.Errorf("Expected ErrPatientVersionConflict, got %v", err)
+	}
+
+	// The target keeps its values, fills gaps from the source and takes the source's contact on request
+	result, err := patientService.MergePatients(target.ID, 1, source.ID, services.MergeOptions{UseSource: []string{"email"}, MergedBy: "steward"})
+	if err != nil {
+		t.Fatalf("MergePatients failed: %v", err)
+	}
+	merged := result.Target
+	if merged.DOB.String() != "1975-05-05" || merged.Address.City != "Hull" || merged.Email != "merge-2@example.com" || merged.Phone != "5550123456" || merged.Version != 2 {
+		t.Errorf("Unexpected merged patient: %+v", merged)
+	}
+	if !strings.Contains(merged.DoctorNotes, "Asthma") || !strings.Contains(merged.DoctorNotes, "Penicillin allergy") {
+		t.Errorf("Expected the doctor notes to be combined, got %q", merged.DoctorNotes)
+	}
+	if _, err := patientService.GetPatientByID(source.ID); err == nil {
+		t.Errorf("Expected the source to be deleted")
+	}
+	var moved models.PatientStatusChange
+	testDB.First(&moved, history.ID)
+	if moved.PatientID != target.ID {
+		t.Errorf("Expected the status history to move to the target")
+	}
+	var contacts int64
+	testDB.Model(&models.EmergencyContact{}).Where("patient_id = ?", target.ID).Count(&contacts)
+	if contacts != 1 {
+		t.Errorf("Expected the emergency contact to move to the target, got %d", contacts)
+	}
+	if _, err := patientService.RestorePatient(source.ID); !errors.Is(err, services.ErrPatientMerged) {
+		t.Errorf("Expected ErrPatientMerged when restoring a merged patient, got %v", err)
+	}
+
+	// The source is kept by the purges while the merge can be undone
+	if err := patientService.PurgePatient(source.ID); !errors.Is(err, services.ErrPatientMerged) {
+		t.Errorf("Expected ErrPatientMerged when purging a merged patient, got %v", err)
+	}
+	testDB.Unscoped().Model(&models.Patient{}).Where("id = ?", source.ID).Update("deleted_at", time.Now().Add(-48*time.Hour))
+	if purged, err := patientService.PurgeExpiredPatients(24 * time.Hour); err != nil || slices.Contains(purged, source.ID) {
+		t.Errorf("Expected the merged patient to be kept by the retention purge, got %v, %v", purged, err)
+	}
+
+	// Edits after the merge survive the unmerge
+	if _, _, err := patientService.PatchPatient(target.ID, 0, services.PatientPatch{"address.line1": strPtr("5 Later Road")}); err != nil {
+		t.Fatalf("PatchPatient failed: %v", err)
+	}
+
+	merges, err := patientService.ListMerges(source.ID)
+	if err != nil || len(merges) != 1 || merges[0].MergedBy != "steward" {
+		t.Fatalf("Expected one merge for the source, got %v, %v", merges, err)
+	}
+	undone, err := patientService.UnmergePatients(result.Merge.ID, "steward")
+	if err != nil {
+		t.Fatalf("UnmergePatients failed: %v", err)
+	}
+	if undone.Target.Email != "merge-1@example.com" || undone.Target.Phone != "" || undone.Target.DoctorNotes != "Asthma" ||
+		undone.Target.Address != (models.Address{Line1: "5 Later Road"}) {
+		t.Errorf("Unexpected target after unmerge: %+v", undone.Target)
+	}
+	if undone.Source.Email != "merge-2@example.com" || undone.Source.DeletedAt.Valid {
+		t.Errorf("Expected the source to be restored, got %+v", undone.Source)
+	}
+	testDB.First(&moved, history.ID)
+	if moved.PatientID != source.ID {
+		t.Errorf("Expected the status history to return to the source")
+	}
+	if _, err := patientService.UnmergePatients(result.Merge.ID, "steward"); !errors.Is(err, services.ErrMergeUndone) {
+		t.Errorf("Expected ErrMergeUndone, got %v", err)
+	}
+	if _, err := patientService.UnmergePatients(999999, "steward"); !errors.Is(err, services.ErrMergeNotFound) {
+		t.Errorf("Expected ErrMergeNotFound, got %v", err)
+	}
+	testDB.Where("id = ?", result.Merge.ID).Delete(&models.PatientMerge{})
+}
+
+// TestPatientService_Demographics tests validation of typed demographics, emergency contacts
+// and medical record numbers
+func TestPatientService_Demographics(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	invalid := &models.Patient{
+		FirstName: "Dee", LastName: "Mographic", Gender: "f", Email: "dee@", DOB: date("1850-01-01"),
+		EmergencyContacts: []models.EmergencyContact{{Relationship: "sister"}},
+	}
+	err := patientService.CreatePatient(invalid)
+	fieldErrors, ok := err.(services.FieldErrors)
+	if !ok || fieldErrors["gender"] == "" || fieldErrors["email"] == "" || fieldErrors["dob"] == "" ||
+		fieldErrors["emergency_contacts[0].name"] == "" || fieldErrors["emergency_contacts[0].phone"] == "" {
+		t.Errorf("Expected field errors for gender, email, dob and the emergency contact, got %v", err)
+	}
+	if err := patientService.CreatePatient(&models.Patient{FirstName: "No", LastName: "Contact"}); err == nil {
+		t.Error("Expected a patient without phone or email to be rejected")
+	}
+
+	patient := &models.Patient{
+		FirstName: "Dee", LastName: "Mographic", Email: " Dee@Example.com ", Gender: "Other",
+		EmergencyContacts: []models.EmergencyContact{{Name: "Sis", Relationship: "sister", Phone: "(555) 010-2030"}},
+	}
+	if err := patientService.CreatePatient(patient); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+	defer testDB.Unscoped().Delete(&models.Patient{}, patient.ID)
+	defer testDB.Where("patient_id = ?", patient.ID).Delete(&models.EmergencyContact{})
+	if patient.Email != "dee@example.com" || patient.Gender != models.GenderOther || patient.EmergencyContacts[0].Phone != "5550102030" {
+		t.Errorf("Expected normalized fields, got %+v", patient)
+	}
+	if !utils.ValidMRN(patient.MRN) || utils.ValidMRN(patient.MRN[:8]+string('0'+(patient.MRN[8]-'0'+1)%10)) {
+		t.Errorf("Expected a medical record number with a check digit, got %q", patient.MRN)
+	}
+	results, _ := patientService.SearchPatients(patient.MRN, 5)
+	if len(results) == 0 || results[0].Patient.ID != patient.ID {
+		t.Errorf("Expected to find the patient by medical record number")
+	}
+
+	// Emergency contacts are replaced as a whole and bump the version
+	before, after, err := patientService.ReplaceEmergencyContacts(patient.ID, 1, []models.EmergencyContact{
+		{Name: "Mum", Relationship: "mother", Email: "mum@example.com"},
+		{Name: "Dad", Relationship: "father", Phone: "+1 555 010 9999"},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceEmergencyContacts failed: %v", err)
+	}
+	if len(before.EmergencyContacts) != 1 || len(after.EmergencyContacts) != 2 || after.Version != 2 {
+		t.Errorf("Unexpected emergency contacts before/after: %+v / %+v", before.EmergencyContacts, after.EmergencyContacts)
+	}
+	stored, _ := patientService.GetPatientByID(patient.ID)
+	if len(stored.EmergencyContacts) != 2 || stored.EmergencyContacts[1].Phone != "+15550109999" {
+		t.Errorf("Expected the new emergency contacts to be stored, got %+v", stored.EmergencyContacts)
+	}
+	if _, _, err := patientService.ReplaceEmergencyContacts(patient.ID, 1, nil); !errors.Is(err, services.ErrPatientVersionConflict) {
+		t.Errorf("Expected ErrPatientVersionConflict, got %v", err)
+	}
+	if _, _, err := patientService.ReplaceEmergencyContacts(patient.ID, 0, []models.EmergencyContact{{Name: "Nobody"}}); err == nil {
+		t.Error("Expected an emergency contact without phone or email to be rejected")
+	}
+}
+
+// TestPatientService_ExportImport tests exporting patients and importing them with their medical record numbers
+func TestPatientService_ExportImport(t *testing.T) {
+	patientService := services.NewPatientService(testDB)
+
+	patient := &models.Patient{
+		FirstName: "Ex", LastName: "Port", Phone: "555-010-7070", DOB: date("1990-04-01"),
+		EmergencyContacts: []models.EmergencyContact{{Name: "Im Port", Phone: "555-010-7071"}},
+	}
+	if err := patientService.CreatePatient(patient); err != nil {
+		t.Fatalf("CreatePatient failed: %v", err)
+	}
+
+	// --- Export includes the emergency contacts ---
+	var exported *models.Patient
+	err := patientService.ExportPatients(func(p *models.Patient) error {
+		if p.ID == patient.ID {
+			copied := *p
+			exported = &copied
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportPatients failed: %v", err)
+	}
+	if exported == nil || exported.MRN != patient.MRN || len(exported.EmergencyContacts) != 1 {
+		t.Fatalf("Expected the patient with their emergency contact in the export, got %+v", exported)
+	}
+
+	// --- The same record cannot be imported twice ---
+	again := *exported
+	if err := patientService.ImportPatient(&again); !errors.Is(err, services.ErrMRNTaken) {
+		t.Errorf("Expected ErrMRNTaken, got %v", err)
+	}
+
+	// --- Elsewhere the medical record number and status are kept ---
+	testDB.Where("patient_id = ?", patient.ID).Delete(&models.EmergencyContact{})
+	testDB.Unscoped().Delete(&models.Patient{}, patient.ID)
+	moved := *exported
+	moved.Status = models.PatientAdmitted
+	if err := patientService.ImportPatient(&moved); err != nil {
+		t.Fatalf("ImportPatient failed: %v", err)
+	}
+	defer testDB.Unscoped().Delete(&models.Patient{}, moved.ID)
+	defer testDB.Where("patient_id = ?", moved.ID).Delete(&models.EmergencyContact{})
+	stored, err := patientService.GetPatientByID(moved.ID)
+	if err != nil {
+		t.Fatalf("GetPatientByID failed: %v", err)
+	}
+	if moved.ID == patient.ID || stored.MRN != patient.MRN || stored.Status != models.PatientAdmitted || len(stored.EmergencyContacts) != 1 {
+		t.Errorf("Expected a new patient with the exported MRN, status and contact, got %+v", stored)
+	}
+
+	// --- Invalid numbers are rejected, missing ones assigned ---
+	invalid := models.Patient{FirstName: "In", LastName: "Valid", Phone: "555-010-7072", MRN: "123"}
+	var fieldErrors services.FieldErrors
+	if err := patientService.ImportPatient(&invalid); !errors.As(err, &fieldErrors) || fieldErrors["mrn"] == "" {
+		t.Errorf("Expected a field error for the MRN, got %v", err)
+	}
+	unnumbered := models.Patient{FirstName: "No", LastName: "Number", Phone: "555-010-7073"}
+	if err := patientService.ImportPatient(&unnumbered); err != nil || !utils.ValidMRN(unnumbered.MRN) {
+		t.Errorf("Expected a new MRN for a patient without one, got %q (%v)", unnumbered.MRN, err)
+	}
+	testDB.Unscoped().Delete(&models.Patient{}, unnumbered.ID)
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"slices"
+	"testing"
+)
+
+// TestRoleService tests custom roles, permission lookups and the guards on built-in and admin roles
+func TestRoleService(t *testing.T) {
+	roleService := services.NewRoleService(testDB)
+	userService := services.NewUserService(testDB, testConfig)
+
+	// --- Built-in roles are seeded ---
+	permissions, err := roleService.Permissions(models.RoleReceptionist)
+	if err != nil || !slices.Contains(permissions, models.PermPatientWrite) || slices.Contains(permissions, models.PermNotesWrite) {
+		t.Errorf("Unexpected receptionist permissions %v (err %v)", permissions, err)
+	}
+	if err := roleService.SeedBuiltInRoles(); err != nil {
+		t.Errorf("Seeding twice should be a no-op, got %v", err)
+	}
+
+	// --- Custom role ---
+	if _, err := roleService.CreateRole("Lab Tech", "", nil); !errors.Is(err, services.ErrInvalidRoleName) {
+		t.Errorf("Expected ErrInvalidRoleName, got %v", err)
+	}
+	if _, err := roleService.CreateRole("lab_tech", "", []string{"patient:fly"}); !errors.Is(err, services.ErrUnknownPermission) {
+		t.Errorf("Expected ErrUnknownPermission, got %v", err)
+	}
+	role, err := roleService.CreateRole("lab_tech", "Laboratory technician", []string{models.PermPatientRead, models.PermPatientRead})
+	if err != nil {
+		t.Fatalf("CreateRole failed: %v", err)
+	}
+	if got := role.PermissionNames(); len(got) != 1 || got[0] != models.PermPatientRead {
+		t.Errorf("Expected duplicate permissions to be merged, got %v", got)
+	}
+	if _, err := roleService.CreateRole("lab_tech", "", nil); !errors.Is(err, services.ErrRoleExists) {
+		t.Errorf("Expected ErrRoleExists, got %v", err)
+	}
+
+	tech := &models.User{Username: "role-tech", Password: "Microscope-Slide-8", Role: "lab_tech"}
+	if err := userService.RegisterUser(tech); err != nil {
+		t.Fatalf("RegisterUser with a custom role failed: %v", err)
+	}
+	if _, err := roleService.UpdateRole("lab_tech", "Lab", []string{models.PermPatientRead, models.PermNotesRead}); err != nil {
+		t.Fatalf("UpdateRole failed: %v", err)
+	}
+	if permissions, _ := roleService.Permissions("lab_tech"); !slices.Contains(permissions, models.PermNotesRead) {
+		t.Errorf("Expected updated permissions, got %v", permissions)
+	}
+
+	// --- Deleting ---
+	if err := roleService.DeleteRole("lab_tech"); !errors.Is(err, services.ErrRoleInUse) {
+		t.Errorf("Expected ErrRoleInUse, got %v", err)
+	}
+	if err := userService.DeleteUser(tech.ID); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if err := roleService.DeleteRole("lab_tech"); err != nil {
+		t.Errorf("DeleteRole failed: %v", err)
+	}
+	if err := roleService.DeleteRole(models.RoleDoctor); !errors.Is(err, services.ErrRoleBuiltIn) {
+		t.Errorf("Expected ErrRoleBuiltIn, got %v", err)
+	}
+
+	// --- user:admin cannot be taken from the only admins ---
+	admin := &models.User{Username: "role-admin", Password: "Keyring-Vault-31", Role: models.RoleAdmin}
+	if err := userService.RegisterUser(admin); err != nil {
+		t.Fatalf("RegisterUser failed: %v", err)
+	}
+	defer testDB.Unscoped().Delete(admin) // leave no admin behind for the other tests
+	if _, err := roleService.UpdateRole(models.RoleAdmin, "", []string{models.PermAuditRead}); !errors.Is(err, services.ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin, got %v", err)
+	}
+}
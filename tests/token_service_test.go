@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"medical_app/utils"
+	"testing"
+)
+
+// TestTokenService_RotationAndReuse tests refresh token rotation, reuse detection and revocation
+func TestTokenService_RotationAndReuse(t *testing.T) {
+	tokenService := services.NewTokenService(testDB, testConfig)
+
+	user := &models.User{Username: "token-user", Password: "x", Role: "receptionist"}
+	if err := testDB.Create(user).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	first, err := tokenService.IssueTokens(user)
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+	claims, err := utils.ValidateJWT(first.AccessToken, testConfig)
+	if err != nil || claims.ID == "" {
+		t.Fatalf("Expected a valid access token with a jti, got %+v (%v)", claims, err)
+	}
+
+	// --- Rotation ---
+	second, refreshedUser, err := tokenService.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if refreshedUser.ID != user.ID || second.RefreshToken == first.RefreshToken {
+		t.Errorf("Expected a new refresh token for the same user")
+	}
+
+	// --- Reusing the rotated token kills the whole family ---
+	if _, _, err := tokenService.Refresh(first.RefreshToken); !errors.Is(err, services.ErrRefreshTokenReused) {
+		t.Errorf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, _, err := tokenService.Refresh(second.RefreshToken); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("Expected the newest token of the family to be revoked too, got %v", err)
+	}
+
+	// --- Logout revokes the access token ---
+	if err := tokenService.Logout(claims.ID, claims.ExpiresAt.Time, ""); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	revoked, err := tokenService.IsRevoked(claims.ID)
+	if err != nil || !revoked {
+		t.Errorf("Expected access token to be revoked, got %v (%v)", revoked, err)
+	}
+	// Logging out twice is harmless
+	if err := tokenService.Logout(claims.ID, claims.ExpiresAt.Time, ""); err != nil {
+		t.Errorf("Second Logout failed: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"errors"
+	"medical_app/models"
+	"medical_app/services"
+	"testing"
+)
+
+// TestUnitOfWork tests that the calls of a unit of work are committed or rolled back together
+func TestUnitOfWork(t *testing.T) {
+	unitOfWork := services.NewUnitOfWork(testDB, testConfig)
+	patientService := services.NewPatientService(testDB)
+	auditService := services.NewAuditService(testDB)
+	actor := services.AuditActor{Username: "uow-user", Role: "receptionist", Method: "POST", Route: "/api/receptionist/patients"}
+
+	createAndAudit := func(tx *services.Services, lastName string) (*models.Patient, error) {
+		patient := &models.Patient{FirstName: "Unit", LastName: lastName, Phone: "555-010-0021"}
+		if err := tx.Patients.CreatePatient(patient); err != nil {
+			return nil, err
+		}
+		return patient, tx.Audit.Record(actor, models.AuditCreate, patient.ID, nil, patient)
+	}
+	auditEntries := func(patientID uint) int64 {
+		_, total, err := auditService.ListEntries(services.AuditQuery{PatientID: &patientID})
+		if err != nil {
+			t.Fatalf("ListEntries failed: %v", err)
+		}
+		return total
+	}
+
+	// --- Committed when fn returns nil ---
+	var committed *models.Patient
+	err := unitOfWork.Do(func(tx *services.Services) (err error) {
+		committed, err = createAndAudit(tx, "Committed")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if _, err := patientService.GetPatientByID(committed.ID); err != nil {
+		t.Errorf("Expected the committed patient to exist, got %v", err)
+	}
+	if total := auditEntries(committed.ID); total != 1 {
+		t.Errorf("Expected 1 audit entry for the committed patient, got %d", total)
+	}
+
+	// --- Rolled back when fn returns an error, which Do returns ---
+	boom := errors.New("boom")
+	var failed *models.Patient
+	err = unitOfWork.Do(func(tx *services.Services) (err error) {
+		if failed, err = createAndAudit(tx, "Failed"); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Expected Do to return the error of fn, got %v", err)
+	}
+	if _, err := patientService.GetPatientByID(failed.ID); err == nil {
+		t.Errorf("Expected the patient to be rolled back, but it was found")
+	}
+	if total := auditEntries(failed.ID); total != 0 {
+		t.Errorf("Expected the audit entry to be rolled back, got %d entries", total)
+	}
+
+	// --- Rolled back when fn panics, and the panic goes on ---
+	var panicked *models.Patient
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected the panic to be propagated")
+			}
+		}()
+		unitOfWork.Do(func(tx *services.Services) (err error) {
+			if panicked, err = createAndAudit(tx, "Panicked"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+	if _, err := patientService.GetPatientByID(panicked.ID); err == nil {
+		t.Errorf("Expected the patient to be rolled back after a panic, but it was found")
+	}
+
+	// --- A transaction a service starts itself joins the unit of work ---
+	user := &models.User{Username: "uow-user", Password: "Correct-Horse-42", Role: models.RoleReceptionist}
+	err = unitOfWork.Do(func(tx *services.Services) error {
+		if err := tx.Users.RegisterUser(user); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Expected Do to return the error of fn, got %v", err)
+	}
+	if _, err := services.NewUserService(testDB, testConfig).GetUserByUsername(user.Username); err == nil {
+		t.Errorf("Expected the registration to be rolled back with the unit of work")
+	}
+}
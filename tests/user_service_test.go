@@ -1,12 +1,16 @@
 package tests
 
 import (
+	"errors"
 	"log"
+	"medical_app/config"
+	"medical_app/db"
 	"medical_app/models"
 	"medical_app/services"
 	"medical_app/utils"
 	"os"
 	"testing"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -14,18 +18,42 @@ import (
 
 var testDB *gorm.DB
 
+// testConfig is the configuration shared by services that need one
+var testConfig = &config.Config{
+	JWTSecret:            "test-secret",
+	AccessTokenTTL:       15 * time.Minute,
+	RefreshTokenTTL:      time.Hour,
+	LoginMaxFailures:     5,
+	LoginMaxIPFailures:   50,
+	LoginLockoutDuration: 15 * time.Minute,
+	LoginBackoffBase:     time.Second,
+	PasswordMinLength:    12,
+	PasswordMinClasses:   3,
+	PasswordRejectCommon: true,
+	PasswordHistory:      3,
+}
+
 func TestMain(m *testing.M) {
 	// Setup: Initialize in-memory SQLite for testing
 	var err error
-	testDB, err = gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	testDB, err = gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Fatalf("failed to connect to test database: %v", err)
 	}
 
-	// AutoMigrate the models for testing
-	err = testDB.AutoMigrate(&models.User{}, &models.Patient{})
+	// Create the schema with the migrations, as in production
+	migrator, err := database.NewMigrator(testDB)
 	if err != nil {
-		log.Fatalf("failed to auto migrate models: %v", err)
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		log.Fatalf("failed to migrate: %v", err)
+	}
+	if err := services.NewRoleService(testDB).SeedBuiltInRoles(); err != nil {
+		log.Fatalf("failed to seed roles: %v", err)
+	}
+	if err := services.NewFieldVisibilityService(testDB).SeedBuiltInRules(); err != nil {
+		log.Fatalf("failed to seed field visibility: %v", err)
 	}
 
 	// Run tests
@@ -40,7 +68,7 @@ func TestMain(m *testing.M) {
 
 // TestUserService_CreateUser tests the CreateUser method of UserServiceImpl
 func TestUserService_CreateUser(t *testing.T) {
-	userService := services.NewUserService(testDB)
+	userService := services.NewUserService(testDB, testConfig)
 
 	user := &models.User{
 		Username: "testuser",
@@ -65,11 +93,20 @@ func TestUserService_CreateUser(t *testing.T) {
 	if !utils.CheckPasswordHash("password123", fetchedUser.Password) {
 		t.Errorf("Password hashing/checking failed")
 	}
+
+	// The unique index catches a taken username even without the check in RegisterUser
+	err = userService.CreateUser(&models.User{Username: "testuser", Password: "password123", Role: "doctor"})
+	if !errors.Is(err, services.ErrUsernameTaken) {
+		t.Fatalf("Expected ErrUsernameTaken for a duplicate username, got %v", err)
+	}
+	if domainErr := services.AsError(err); domainErr == nil || domainErr.Kind != services.KindConflict || domainErr.Code != "username_taken" {
+		t.Errorf("Expected a conflict with code username_taken, got %+v", domainErr)
+	}
 }
 
 // TestUserService_GetUserByUsername tests the GetUserByUsername method
 func TestUserService_GetUserByUsername(t *testing.T) {
-	userService := services.NewUserService(testDB)
+	userService := services.NewUserService(testDB, testConfig)
 
 	// Ensure a user exists for fetching
 	hashedPass, _ := utils.HashPassword("anotherpass")
@@ -89,4 +126,118 @@ func TestUserService_GetUserByUsername(t *testing.T) {
 	if err == nil {
 		t.Errorf("Expected error for non-existent user, got none")
 	}
-}  
\ No newline at end of file
+}  
+// TestUserService_Management tests registration, role changes, disabling and the last-admin guard
+func TestUserService_Management(t *testing.T) {
+	userService := services.NewUserService(testDB, testConfig)
+
+	admin := &models.User{Username: "mgmt-admin", Password: "Admin-pass-2024", Role: models.RoleAdmin}
+	if err := userService.RegisterUser(admin); err != nil {
+		t.Fatalf("RegisterUser failed: %v", err)
+	}
+	if err := userService.RegisterUser(&models.User{Username: "mgmt-admin", Password: "x", Role: models.RoleDoctor}); !errors.Is(err, services.ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+	if err := userService.RegisterUser(&models.User{Username: "mgmt-nurse", Password: "x", Role: "nurse"}); !errors.Is(err, services.ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+
+	// --- The only admin cannot be demoted, disabled or deleted ---
+	if _, err := userService.ChangeUserRole(admin.ID, models.RoleDoctor); !errors.Is(err, services.ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin on demotion, got %v", err)
+	}
+	if _, err := userService.SetUserDisabled(admin.ID, true); !errors.Is(err, services.ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin on disable, got %v", err)
+	}
+	if err := userService.DeleteUser(admin.ID); !errors.Is(err, services.ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin on delete, got %v", err)
+	}
+
+	// --- With a second admin it works ---
+	clerk := &models.User{Username: "mgmt-clerk", Password: "Clerk-pass-2024", Role: models.RoleReceptionist}
+	if err := userService.RegisterUser(clerk); err != nil {
+		t.Fatalf("RegisterUser failed: %v", err)
+	}
+	if _, err := userService.ChangeUserRole(clerk.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("ChangeUserRole failed: %v", err)
+	}
+	disabled, err := userService.SetUserDisabled(admin.ID, true)
+	if err != nil || !disabled.Disabled {
+		t.Fatalf("SetUserDisabled failed: %v", err)
+	}
+
+	authService := services.NewAuthService(testDB, testConfig)
+	if _, err := authService.Login("mgmt-admin", "Admin-pass-2024", "10.0.0.8"); !errors.Is(err, services.ErrAccountDisabled) {
+		t.Errorf("Expected ErrAccountDisabled for a disabled login, got %v", err)
+	}
+
+	// --- Password reset ---
+	if _, err := userService.ResetPassword(clerk.ID, "New-clerk-pass-7"); err != nil {
+		t.Fatalf("ResetPassword failed: %v", err)
+	}
+	if _, err := authService.Login("mgmt-clerk", "New-clerk-pass-7", "10.0.0.8"); err != nil {
+		t.Errorf("Login with reset password failed: %v", err)
+	}
+}
+
+// TestUserService_Passwords tests the password policy, self-service changes, reuse and forced changes
+func TestUserService_Passwords(t *testing.T) {
+	userService := services.NewUserService(testDB, testConfig)
+	policy := userService.PasswordPolicy()
+
+	// --- Policy ---
+	for _, weak := range []string{"password", "Password123!", "short-1A", "alllowercaseletters", "Pw-pwd-user-99"} {
+		var policyErr *utils.PasswordPolicyError
+		if err := policy.Check(weak, "pwd-user"); !errors.As(err, &policyErr) {
+			t.Errorf("Expected %q to be rejected, got %v", weak, err)
+		}
+	}
+	if err := policy.Check("Quiet-Harbor-42", "pwd-user"); err != nil {
+		t.Errorf("Expected a strong password to pass, got %v", err)
+	}
+	if generated := utils.GeneratePassword(20); policy.Check(generated, "pwd-user") != nil {
+		t.Errorf("Expected a generated password to pass the policy: %q", generated)
+	}
+	if err := userService.RegisterUser(&models.User{Username: "pwd-weak", Password: "password", Role: models.RoleDoctor}); err == nil {
+		t.Error("Expected RegisterUser to reject a weak password")
+	}
+
+	// --- Self-service change ---
+	user := &models.User{Username: "pwd-user", Password: "Quiet-Harbor-42", Role: models.RoleDoctor}
+	if err := userService.RegisterUser(user); err != nil {
+		t.Fatalf("RegisterUser failed: %v", err)
+	}
+	if _, err := userService.ChangePassword(user.ID, "wrong", "Amber-Lantern-17"); !errors.Is(err, services.ErrIncorrectPassword) {
+		t.Errorf("Expected ErrIncorrectPassword, got %v", err)
+	}
+	if _, err := userService.ChangePassword(user.ID, "Quiet-Harbor-42", "Quiet-Harbor-42"); !errors.Is(err, services.ErrPasswordReused) {
+		t.Errorf("Expected ErrPasswordReused for the current password, got %v", err)
+	}
+	if _, err := userService.ChangePassword(user.ID, "Quiet-Harbor-42", "Amber-Lantern-17"); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	// --- History: the last PasswordHistory passwords cannot be reused ---
+	if _, err := userService.ChangePassword(user.ID, "Amber-Lantern-17", "Quiet-Harbor-42"); !errors.Is(err, services.ErrPasswordReused) {
+		t.Errorf("Expected ErrPasswordReused for a previous password, got %v", err)
+	}
+	if _, err := userService.ChangePassword(user.ID, "Amber-Lantern-17", "Copper-Meadow-58"); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+	if _, err := userService.ChangePassword(user.ID, "Copper-Meadow-58", "Velvet-Canyon-33"); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+	if _, err := userService.ChangePassword(user.ID, "Velvet-Canyon-33", "Quiet-Harbor-42"); err != nil {
+		t.Errorf("Expected a password older than the history to be allowed again, got %v", err)
+	}
+
+	// --- Admin reset forces a change, changing it clears the flag ---
+	reset, err := userService.ResetPassword(user.ID, "Temporary-Pass-61")
+	if err != nil || !reset.MustChangePassword {
+		t.Fatalf("Expected ResetPassword to set MustChangePassword, got %v", err)
+	}
+	changed, err := userService.ChangePassword(user.ID, "Temporary-Pass-61", "Silent-Orchard-26")
+	if err != nil || changed.MustChangePassword {
+		t.Errorf("Expected ChangePassword to clear MustChangePassword, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"medical_app/models"
+	"medical_app/services"
+	"medical_app/utils"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// runCreateUser creates a user account. The password is read from stdin with
+// -password-stdin, so it does not end up in the shell history; otherwise one is generated
+// and printed. Unless -must-change=false the user has to change it at the first login.
+func runCreateUser(args []string) error {
+	flags := newFlagSet("create-user")
+	username := flags.String("username", "", "username of the new account")
+	role := flags.String("role", "", "role of the new account, e.g. doctor")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin instead of generating one")
+	mustChange := flags.Bool("must-change", true, "require a password change at the first login")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" || *role == "" {
+		flags.Usage()
+		return errors.New("-username and -role are required")
+	}
+
+	cfg, db, err := openDatabase(true)
+	if err != nil {
+		return err
+	}
+	if err := seedBuiltIns(db); err != nil {
+		return err
+	}
+	password, generated, err := readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{Username: *username, Password: password, Role: *role, MustChangePassword: *mustChange}
+	if err := services.NewUserService(db, cfg).RegisterUser(user); err != nil {
+		return err
+	}
+	fmt.Printf("Created user %q with role %s\n", user.Username, user.Role)
+	if generated {
+		fmt.Printf("Password: %s\n", password)
+	}
+	return nil
+}
+
+// runResetPassword sets a new password for a user, who has to change it at the next
+// login, and ends their sessions. The password is read or generated as for create-user.
+func runResetPassword(args []string) error {
+	flags := newFlagSet("reset-password")
+	username := flags.String("username", "", "username of the account")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin instead of generating one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		flags.Usage()
+		return errors.New("-username is required")
+	}
+
+	cfg, db, err := openDatabase(true)
+	if err != nil {
+		return err
+	}
+	userService := services.NewUserService(db, cfg)
+	user, err := userService.GetUserByUsername(*username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("user %q not found", *username)
+	}
+	if err != nil {
+		return err
+	}
+	password, generated, err := readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	if _, err := userService.ResetPassword(user.ID, password); err != nil {
+		return err
+	}
+	if err := services.NewTokenService(db, cfg).RevokeAllForUser(user.ID); err != nil {
+		return fmt.Errorf("password was reset, but the sessions could not be ended: %w", err)
+	}
+	fmt.Printf("Reset the password of %q; it has to be changed at the next login\n", user.Username)
+	if generated {
+		fmt.Printf("Password: %s\n", password)
+	}
+	return nil
+}
+
+// readPassword reads a password from the first line of stdin, or generates one
+func readPassword(fromStdin bool) (password string, generated bool, err error) {
+	if !fromStdin {
+		return utils.GeneratePassword(20), true, nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", false, errors.New("no password on stdin")
+	}
+	password = strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", false, errors.New("no password on stdin")
+	}
+	return password, false, nil
+}
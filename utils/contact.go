@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// NormalizePhone strips the formatting of a phone number, keeping a leading "+". The
+// number needs 7 to 15 digits (the E.164 maximum).
+func NormalizePhone(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	for _, r := range value {
+		if !strings.ContainsRune("+0123456789 -().", r) {
+			return "", errors.New("must be a phone number of digits, spaces, dashes, dots and parentheses")
+		}
+	}
+	digits := DigitsOnly(value)
+	if len(digits) < 7 || len(digits) > 15 {
+		return "", errors.New("must have between 7 and 15 digits")
+	}
+	if strings.HasPrefix(value, "+") {
+		return "+" + digits, nil
+	}
+	return digits, nil
+}
+
+// NormalizeEmail checks that value is a plain email address and lowercases it
+func NormalizeEmail(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	parsed, err := mail.ParseAddress(value)
+	if err != nil || parsed.Address != value || !strings.Contains(value[strings.LastIndex(value, "@"):], ".") {
+		return "", errors.New("must be a valid email address")
+	}
+	return strings.ToLower(value), nil
+}
@@ -1,26 +1,41 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"time"
 	"medical_app/config" 
 
 	"github.com/golang-jwt/jwt/v5"
 )
+// MFA token purposes. Such tokens only carry a user between the password step and the
+// second factor and are never accepted as access tokens.
+const (
+	PurposeMFAVerify = "mfa_verify" // the user has MFA and must enter a code
+	PurposeMFAEnroll = "mfa_enroll" // the user's role requires MFA and it is not set up yet
+)
+
+// mfaTokenTTL is how long the user has to complete the second login step
+const mfaTokenTTL = 5 * time.Minute
+
 // defines the JWT claims structure
 type Claims struct {
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	Purpose  string `json:"purpose,omitempty"` // empty for access tokens
 	jwt.RegisteredClaims
 }
 
-// generates a new JWT token for a given user
+// generates a new short-lived access token for a given user. Every token gets a unique
+// jti so it can be revoked before it expires.
 func GenerateJWT(username string, role string, cfg *config.Config) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token valid for 24 hours
+	expirationTime := time.Now().Add(cfg.AccessTokenTTL)
 	claims := &Claims{
 		Username: username,
 		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        RandomToken(16),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -36,6 +51,34 @@ func GenerateJWT(username string, role string, cfg *config.Config) (string, erro
 	return tokenString, nil
 }
 
+// generates a short-lived token proving the password step of a login succeeded
+func GenerateMFAToken(username string, purpose string, cfg *config.Config) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		Username: username,
+		Purpose:  purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        RandomToken(16),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWTSecret))
+}
+
+// validates an MFA token and checks it was issued for the expected purpose
+func ValidateMFAToken(tokenString string, purpose string, cfg *config.Config) (*Claims, error) {
+	claims, err := ValidateJWT(tokenString, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
 //  validates a JWT token and returns its claims
 func ValidateJWT(tokenString string, cfg *config.Config) (*Claims, error) {
 	claims := &Claims{}
@@ -55,4 +98,14 @@ func ValidateJWT(tokenString string, cfg *config.Config) (*Claims, error) {
 	}
 
 	return claims, nil
-}
\ No newline at end of file
+}
+
+// RandomToken returns n random bytes, hex encoded
+func RandomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
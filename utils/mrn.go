@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// mrnDigits is the length of a medical record number without its check digit
+const mrnDigits = 8
+
+// RandomMRN draws a random medical record number: mrnDigits random digits followed by a
+// Luhn check digit, so that a mistyped number is caught instead of opening another
+// patient's record. The caller checks that it is unused.
+func RandomMRN() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(9*pow10(mrnDigits-1)))
+	if err != nil {
+		return "", err
+	}
+	number := fmt.Sprintf("%d", n.Int64()+pow10(mrnDigits-1))
+	return number + string(rune('0'+luhnCheckDigit(number))), nil
+}
+
+// ValidMRN reports whether mrn is a well-formed medical record number with a correct check digit
+func ValidMRN(mrn string) bool {
+	if len(mrn) != mrnDigits+1 || DigitsOnly(mrn) != mrn {
+		return false
+	}
+	return int(mrn[mrnDigits]-'0') == luhnCheckDigit(mrn[:mrnDigits])
+}
+
+// luhnCheckDigit returns the Luhn check digit of a string of digits
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
@@ -1,9 +1,83 @@
 package utils
 
 import (
+	"bufio"
+	"crypto/rand"
+	_ "embed"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+// commonPasswordList is a bundled list of common and breached passwords, checked offline
+//
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = loadCommonPasswords(commonPasswordList)
+
+// PasswordPolicy describes what a new password has to look like
+type PasswordPolicy struct {
+	MinLength    int  // minimum number of characters
+	MinClasses   int  // minimum number of character classes: lower case, upper case, digits, symbols
+	RejectCommon bool // reject passwords found in the bundled common password list
+}
+
+// PasswordPolicyError lists every rule a password breaks
+type PasswordPolicyError struct {
+	Problems []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Problems, "; ")
+}
+
+// Check returns a *PasswordPolicyError when password breaks the policy
+func (p PasswordPolicy) Check(password, username string) error {
+	var problems []string
+	if len([]rune(password)) < p.MinLength {
+		problems = append(problems, "must be at least "+strconv.Itoa(p.MinLength)+" characters long")
+	}
+	if classes := characterClasses(password); classes < p.MinClasses {
+		problems = append(problems, "must contain at least "+strconv.Itoa(p.MinClasses)+" of: lower case letters, upper case letters, digits, symbols")
+	}
+	lower := strings.ToLower(password)
+	if len(username) >= 3 && strings.Contains(lower, strings.ToLower(username)) {
+		problems = append(problems, "must not contain the username")
+	}
+	if p.RejectCommon && isCommonPassword(lower) {
+		problems = append(problems, "is too common, choose a less predictable password")
+	}
+	if problems != nil {
+		return &PasswordPolicyError{Problems: problems}
+	}
+	return nil
+}
+
+// GeneratePassword returns a random password of the given length with all four character classes
+func GeneratePassword(length int) string {
+	classes := []string{"abcdefghijkmnopqrstuvwxyz", "ABCDEFGHJKLMNPQRSTUVWXYZ", "23456789", "!@#$%^&*-_=+"}
+	all := strings.Join(classes, "")
+	b := make([]byte, length)
+	for i := range b {
+		// The first characters cover every class, the rest are drawn from all of them
+		set := all
+		if i < len(classes) {
+			set = classes[i]
+		}
+		b[i] = set[randomInt(len(set))]
+	}
+	// Shuffle so the class characters are not always in front
+	for i := len(b) - 1; i > 0; i-- {
+		j := randomInt(i + 1)
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
 // It hashes a plain-text password using bcrypt
 func HashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -17,4 +91,55 @@ func HashPassword(password string) (string, error) {
 func CheckPasswordHash(password, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
-}
\ No newline at end of file
+}
+
+// isCommonPassword checks a lower case password against the list, also with the digits
+// and symbols people typically add at the end removed ("Password123!" -> "password")
+func isCommonPassword(lower string) bool {
+	if _, ok := commonPasswords[lower]; ok {
+		return true
+	}
+	base := strings.TrimRightFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	_, ok := commonPasswords[base]
+	return ok && base != ""
+}
+
+// characterClasses counts the character classes used in s
+func characterClasses(s string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+func loadCommonPasswords(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return passwords
+}
+
+func randomInt(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return int(v.Int64())
+}
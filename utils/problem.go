@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// AbortWithProblem ends the request with an RFC 7807 problem details body. The type is
+// about:blank, so the title is the status text; code is a stable machine-readable error
+// code and extensions (such as the invalid "fields") are added as further members.
+func AbortWithProblem(c *gin.Context, status int, code, detail string, extensions gin.H) {
+	body := gin.H{}
+	for key, value := range extensions {
+		body[key] = value
+	}
+	body["type"] = "about:blank"
+	body["title"] = http.StatusText(status)
+	body["status"] = status
+	body["detail"] = detail
+	body["instance"] = c.Request.URL.Path
+	body["code"] = code
+
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(status, body)
+}